@@ -183,6 +183,11 @@ type Scope interface {
 	NewStopWatchVec(name, description string, scale time.Duration, labelNames ...string) (*StopWatchVec, error)
 	MustNewStopWatchVec(name, description string, scale time.Duration, labelNames ...string) *StopWatchVec
 
+	// RegisterCollector registers a custom prometheus.Collector, e.g. to publish statistics computed by a library.
+	// Use NewScopedMetricName to name the metrics it describes so that they belong to the current Scope.
+	RegisterCollector(collector prometheus.Collector) error
+	MustRegisterCollector(collector prometheus.Collector)
+
 	// NewSubScope creates a new subScope in case nesting is desired for metrics. This is generally useful in creating
 	// Scoped and SubScoped metrics
 	NewSubScope(name string) Scope
@@ -389,6 +394,14 @@ func (m metricsScope) MustNewStopWatchVec(name, description string, scale time.D
 	return s
 }
 
+func (m metricsScope) RegisterCollector(collector prometheus.Collector) error {
+	return prometheus.Register(collector)
+}
+
+func (m metricsScope) MustRegisterCollector(collector prometheus.Collector) {
+	panicIfError(m.RegisterCollector(collector))
+}
+
 func (m metricsScope) CurrentScope() string {
 	return m.scope
 }
@@ -124,6 +124,14 @@ func TestMetricsScope(t *testing.T) {
 		})
 	})
 
+	t.Run("Collector", func(t *testing.T) {
+		c := prometheus.NewGauge(prometheus.GaugeOpts{Name: s.NewScopedMetricName("xcol"), Help: description})
+		s.MustRegisterCollector(c)
+		assert.Panics(t, func() {
+			s.MustRegisterCollector(c)
+		})
+	})
+
 }
 
 func TestStopWatch_Start(t *testing.T) {
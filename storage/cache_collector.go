@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/coocood/freecache"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+// freecacheCollector publishes the statistics freecache computes internally. The collector outlives the cache it
+// reports on: metrics are created once per DataStore while the cache is recreated whenever the config is refreshed.
+type freecacheCollector struct {
+	lock  sync.RWMutex
+	cache *freecache.Cache
+
+	entries       *prometheus.Desc
+	evacuations   *prometheus.Desc
+	expirations   *prometheus.Desc
+	overwrites    *prometheus.Desc
+	hitRate       *prometheus.Desc
+	avgAccessTime *prometheus.Desc
+}
+
+// setCache switches the collector to report on cache. A nil cache stops reporting.
+func (c *freecacheCollector) setCache(cache *freecache.Cache) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cache = cache
+}
+
+func (c *freecacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entries
+	ch <- c.evacuations
+	ch <- c.expirations
+	ch <- c.overwrites
+	ch <- c.hitRate
+	ch <- c.avgAccessTime
+}
+
+func (c *freecacheCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.RLock()
+	cache := c.cache
+	c.lock.RUnlock()
+	if cache == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(cache.EntryCount()))
+	ch <- prometheus.MustNewConstMetric(c.evacuations, prometheus.CounterValue, float64(cache.EvacuateCount()))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(cache.ExpiredCount()))
+	ch <- prometheus.MustNewConstMetric(c.overwrites, prometheus.CounterValue, float64(cache.OverwriteCount()))
+	ch <- prometheus.MustNewConstMetric(c.hitRate, prometheus.GaugeValue, cache.HitRate())
+	ch <- prometheus.MustNewConstMetric(c.avgAccessTime, prometheus.GaugeValue, float64(cache.AverageAccessTime()))
+}
+
+func newFreecacheCollector(scope promutils.Scope) *freecacheCollector {
+	newDesc := func(name, description string) *prometheus.Desc {
+		return prometheus.NewDesc(scope.NewScopedMetricName(name), description, nil, nil)
+	}
+
+	c := &freecacheCollector{
+		entries:       newDesc("cache_entries", "Number of entries in the in-memory cache"),
+		evacuations:   newDesc("cache_evacuations", "Number of entries evicted from the in-memory cache to make room for new ones"),
+		expirations:   newDesc("cache_expirations", "Number of expired entries removed from the in-memory cache"),
+		overwrites:    newDesc("cache_overwrites", "Number of in-memory cache entries overwritten with a new value"),
+		hitRate:       newDesc("cache_hit_rate", "Ratio of in-memory cache lookups that found an entry"),
+		avgAccessTime: newDesc("cache_avg_access_time", "Average unix timestamp (in seconds) at which in-memory cache entries were last accessed"),
+	}
+
+	scope.MustRegisterCollector(c)
+	return c
+}
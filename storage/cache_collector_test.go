@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coocood/freecache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+func TestFreecacheCollector(t *testing.T) {
+	scope := promutils.NewTestScope()
+	c := newFreecacheCollector(scope)
+	assert.Equal(t, 0, testutil.CollectAndCount(c))
+
+	cache := freecache.NewCache(512 * 1024)
+	c.setCache(cache)
+	assert.NoError(t, cache.Set([]byte("k1"), []byte("v1"), neverExpire))
+	assert.NoError(t, cache.Set([]byte("k1"), []byte("v2"), neverExpire))
+	_, err := cache.Get([]byte("k1"))
+	assert.NoError(t, err)
+	_, err = cache.Get([]byte("k2"))
+	assert.Error(t, err)
+
+	assert.Equal(t, 6, testutil.CollectAndCount(c))
+	expected := `
+# HELP {{scope}}cache_entries Number of entries in the in-memory cache
+# TYPE {{scope}}cache_entries gauge
+{{scope}}cache_entries 1
+# HELP {{scope}}cache_hit_rate Ratio of in-memory cache lookups that found an entry
+# TYPE {{scope}}cache_hit_rate gauge
+{{scope}}cache_hit_rate 0.5
+# HELP {{scope}}cache_overwrites Number of in-memory cache entries overwritten with a new value
+# TYPE {{scope}}cache_overwrites counter
+{{scope}}cache_overwrites 1
+`
+	expected = strings.ReplaceAll(expected, "{{scope}}", scope.CurrentScope())
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		scope.NewScopedMetricName("cache_entries"), scope.NewScopedMetricName("cache_hit_rate"),
+		scope.NewScopedMetricName("cache_overwrites")))
+
+	c.setCache(nil)
+	assert.Equal(t, 0, testutil.CollectAndCount(c))
+}
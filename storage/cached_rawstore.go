@@ -3,7 +3,9 @@ package storage
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"io"
+	"math"
 	"runtime/debug"
 	"time"
 
@@ -19,43 +21,135 @@ import (
 
 const neverExpire = 0
 
-// TODO Freecache has bunch of metrics it calculates. Lets write a prom collector to publish these metrics
 type cacheMetrics struct {
 	CacheHit        prometheus.Counter
 	CacheMiss       prometheus.Counter
 	CacheWriteError prometheus.Counter
+	CacheStale      prometheus.Counter
 	FetchLatency    promutils.StopWatch
+
+	DiskCacheHit        prometheus.Counter
+	DiskCacheMiss       prometheus.Counter
+	DiskCacheEvict      prometheus.Counter
+	DiskCacheWriteError prometheus.Counter
+
+	// Statistics calculated by freecache itself.
+	CacheStats *freecacheCollector
 }
 
 type cachedRawStore struct {
 	RawStore
 	cache   *freecache.Cache
 	metrics *cacheMetrics
+	// etagCheck enables revalidating cached entries against the Etag of the underlying object once they are older than
+	// stalenessWindow.
+	etagCheck       bool
+	stalenessWindow time.Duration
 }
 
-// Head gets metadata about the reference. This should generally be a lightweight operation.
-func (s *cachedRawStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+// cacheEntryHeaderSize is the size of the header preceding the data of each cache entry: the time the entry was last
+// validated (unix nanos) followed by the length of its Etag.
+const cacheEntryHeaderSize = 8 + 1
+
+func encodeCacheEntry(data []byte, etag string, validatedAt time.Time) []byte {
+	if len(etag) > math.MaxUint8 {
+		// Entries without an Etag are never considered valid once stale.
+		etag = ""
+	}
+
+	entry := make([]byte, cacheEntryHeaderSize, cacheEntryHeaderSize+len(etag)+len(data))
+	binary.BigEndian.PutUint64(entry, uint64(validatedAt.UnixNano()))
+	entry[8] = byte(len(etag))
+	entry = append(entry, etag...)
+	return append(entry, data...)
+}
+
+func decodeCacheEntry(entry []byte) (data []byte, etag string, validatedAt time.Time, ok bool) {
+	if len(entry) < cacheEntryHeaderSize || len(entry) < cacheEntryHeaderSize+int(entry[8]) {
+		return nil, "", time.Time{}, false
+	}
+
+	validatedAt = time.Unix(0, int64(binary.BigEndian.Uint64(entry)))
+	etagEnd := cacheEntryHeaderSize + int(entry[8])
+	return entry[etagEnd:], string(entry[cacheEntryHeaderSize:etagEnd]), validatedAt, true
+}
+
+// get returns the cached data for the reference. If the Etag check is enabled, stale entries are revalidated against
+// the underlying store and evicted if the object has changed.
+func (s *cachedRawStore) get(ctx context.Context, reference DataReference) (data []byte, etag string, found bool) {
 	key := []byte(reference)
-	if oRaw, err := s.cache.Get(key); err == nil {
-		s.metrics.CacheHit.Inc()
-		// Found, Cache hit
-		size := int64(len(oRaw))
-		// return size in metadata
-		return StowMetadata{exists: true, size: size}, nil
+	entry, err := s.cache.Get(key)
+	if err != nil {
+		return nil, "", false
 	}
-	s.metrics.CacheMiss.Inc()
+
+	data, etag, validatedAt, ok := decodeCacheEntry(entry)
+	if !ok {
+		s.cache.Del(key)
+		return nil, "", false
+	}
+
+	if !s.etagCheck || time.Since(validatedAt) <= s.stalenessWindow {
+		return data, etag, true
+	}
+
+	md, err := s.RawStore.Head(ctx, reference)
+	if err != nil || !md.Exists() || len(etag) == 0 || md.Etag() != etag {
+		s.metrics.CacheStale.Inc()
+		s.cache.Del(key)
+		return nil, "", false
+	}
+
+	// Best effort, failing to record the validation only causes another check.
+	_ = s.cache.Set(key, encodeCacheEntry(data, etag, time.Now()), neverExpire)
+	return data, etag, true
+}
+
+// set caches data for the reference. If the Etag check is enabled and etag is unknown, it's looked up in the
+// underlying store.
+func (s *cachedRawStore) set(ctx context.Context, reference DataReference, data []byte, etag string) error {
+	if s.etagCheck && len(etag) == 0 {
+		if md, err := s.RawStore.Head(ctx, reference); err == nil {
+			etag = md.Etag()
+		}
+	}
+
+	return s.cache.Set([]byte(reference), encodeCacheEntry(data, etag, time.Now()), neverExpire)
+}
+
+// invalidate evicts the reference from the cache and reports whether it was cached.
+func (s *cachedRawStore) invalidate(reference DataReference) bool {
+	return s.cache.Del([]byte(reference))
+}
+
+// Head gets metadata about the reference from the underlying store, since cached entries don't hold the metadata of
+// the objects.
+func (s *cachedRawStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
 	return s.RawStore.Head(ctx, reference)
 }
 
 // ReadRaw retrieves a byte array from the Blob store or an error
 func (s *cachedRawStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
-	key := []byte(reference)
-	if oRaw, err := s.cache.Get(key); err == nil {
+	if data, _, found := s.get(ctx, reference); found {
 		// Found, Cache hit
 		s.metrics.CacheHit.Inc()
-		return ioutils.NewBytesReadCloser(oRaw), nil
+		return ioutils.NewBytesReadCloser(data), nil
 	}
 	s.metrics.CacheMiss.Inc()
+	if skipsDownloadLimit(ctx) {
+		// The caller streams the object, which may be larger than the download limit, so it isn't buffered to be cached.
+		return s.RawStore.ReadRaw(ctx, reference)
+	}
+
+	// Look up the Etag before reading so that, if the object changes in between, the entry is only ever older than
+	// its recorded Etag and gets evicted on the next check.
+	var etag string
+	if s.etagCheck {
+		if md, err := s.RawStore.Head(ctx, reference); err == nil {
+			etag = md.Etag()
+		}
+	}
+
 	reader, err := s.RawStore.ReadRaw(ctx, reference)
 	if err != nil {
 		return nil, err
@@ -73,7 +167,7 @@ func (s *cachedRawStore) ReadRaw(ctx context.Context, reference DataReference) (
 		return nil, err
 	}
 
-	err = s.cache.Set(key, b, 0)
+	err = s.cache.Set([]byte(reference), encodeCacheEntry(b, etag, time.Now()), neverExpire)
 	if err != nil {
 		logger.Debugf(ctx, "Failed to Cache the metadata")
 		err = errors.Wrapf(ErrFailedToWriteCache, err, "Failed to Cache the metadata")
@@ -82,17 +176,38 @@ func (s *cachedRawStore) ReadRaw(ctx context.Context, reference DataReference) (
 	return ioutils.NewBytesReadCloser(b), err
 }
 
-// WriteRaw stores a raw byte array.
+// ReadRawRange serves the range from the cache if the full object has been cached. Otherwise, it reads the range from
+// the underlying store without caching it, since only full-object reads are cached.
+func (s *cachedRawStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	if data, _, found := s.get(ctx, reference); found {
+		s.metrics.CacheHit.Inc()
+		start, end, err := rangeBounds(int64(len(data)), offset, length)
+		if err != nil {
+			return nil, err
+		}
+
+		return ioutils.NewBytesReadCloser(data[start:end]), nil
+	}
+
+	s.metrics.CacheMiss.Inc()
+	return s.RawStore.ReadRawRange(ctx, reference, offset, length)
+}
+
+// WriteRaw stores a raw byte array and writes it through to the cache. If the data can't be cached, any previously
+// cached version is evicted.
 func (s *cachedRawStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
 	var buf bytes.Buffer
 	teeReader := io.TeeReader(raw, &buf)
 	err := s.RawStore.WriteRaw(ctx, reference, size, opts, teeReader)
 	if err != nil {
+		// The state of the object is unknown after a failed write.
+		s.invalidate(reference)
 		return err
 	}
 
-	err = s.cache.Set([]byte(reference), buf.Bytes(), neverExpire)
+	err = s.set(ctx, reference, buf.Bytes(), "")
 	if err != nil {
+		s.invalidate(reference)
 		s.metrics.CacheWriteError.Inc()
 		err = errors.Wrapf(ErrFailedToWriteCache, err, "Failed to Cache the metadata")
 	}
@@ -100,16 +215,46 @@ func (s *cachedRawStore) WriteRaw(ctx context.Context, reference DataReference,
 	return err
 }
 
-// Delete removes the referenced data from the cache as well as underlying store.
+// OpenWriter evicts the reference from the cache, since it's about to be overwritten, and opens a writer on the
+// underlying store. The reference is evicted again when the writer is closed in case it was read in the meantime.
+func (s *cachedRawStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	s.invalidate(reference)
+	w, err := s.RawStore.OpenWriter(ctx, reference, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return invalidatingWriter{WriteCloser: w, invalidate: func() { s.invalidate(reference) }}, nil
+}
+
+// CopyRaw copies source to destination and evicts destination from the cache.
+func (s *cachedRawStore) CopyRaw(ctx context.Context, source, destination DataReference, opts Options) error {
+	err := s.RawStore.CopyRaw(ctx, source, destination, opts)
+	s.invalidate(destination)
+	return err
+}
+
+// Delete removes the referenced data from the underlying store as well as the cache.
 func (s *cachedRawStore) Delete(ctx context.Context, reference DataReference) error {
-	key := []byte(reference)
-	if deleted := s.cache.Del(key); deleted {
+	err := s.RawStore.Delete(ctx, reference)
+	if deleted := s.invalidate(reference); deleted {
 		s.metrics.CacheHit.Inc()
 	} else {
 		s.metrics.CacheMiss.Inc()
 	}
 
-	return s.RawStore.Delete(ctx, reference)
+	return err
+}
+
+// invalidatingWriter evicts a reference from the cache once the underlying writer is closed.
+type invalidatingWriter struct {
+	io.WriteCloser
+	invalidate func()
+}
+
+func (w invalidatingWriter) Close() error {
+	defer w.invalidate()
+	return w.WriteCloser.Close()
 }
 
 func newCacheMetrics(scope promutils.Scope) *cacheMetrics {
@@ -118,6 +263,14 @@ func newCacheMetrics(scope promutils.Scope) *cacheMetrics {
 		CacheHit:        scope.MustNewCounter("cache_hit", "Number of times metadata was found in cache"),
 		CacheMiss:       scope.MustNewCounter("cache_miss", "Number of times metadata was not found in cache and remote fetch was required"),
 		CacheWriteError: scope.MustNewCounter("cache_write_err", "Failed to write to cache"),
+		CacheStale:      scope.MustNewCounter("cache_stale", "Number of cached entries evicted because the underlying object changed"),
+
+		DiskCacheHit:        scope.MustNewCounter("disk_cache_hit", "Number of times data was found in the disk cache"),
+		DiskCacheMiss:       scope.MustNewCounter("disk_cache_miss", "Number of times data was not found in the disk cache"),
+		DiskCacheEvict:      scope.MustNewCounter("disk_cache_evict", "Number of entries evicted from the disk cache to stay under its size limit"),
+		DiskCacheWriteError: scope.MustNewCounter("disk_cache_write_err", "Failed to write to the disk cache"),
+
+		CacheStats: newFreecacheCollector(scope),
 	}
 }
 
@@ -127,11 +280,16 @@ func newCachedRawStore(cfg *Config, store RawStore, metrics *cacheMetrics) RawSt
 		if cfg.Cache.TargetGCPercent > 0 {
 			debug.SetGCPercent(cfg.Cache.TargetGCPercent)
 		}
+		cache := freecache.NewCache(cfg.Cache.MaxSizeMegabytes * 1024 * 1024)
+		metrics.CacheStats.setCache(cache)
 		return &cachedRawStore{
-			RawStore: store,
-			cache:    freecache.NewCache(cfg.Cache.MaxSizeMegabytes * 1024 * 1024),
-			metrics:  metrics,
+			RawStore:        store,
+			cache:           cache,
+			metrics:         metrics,
+			etagCheck:       cfg.Cache.EtagCheck,
+			stalenessWindow: cfg.Cache.StalenessWindow.Duration,
 		}
 	}
+	metrics.CacheStats.setCache(nil)
 	return store
 }
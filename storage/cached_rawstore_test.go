@@ -9,9 +9,11 @@ import (
 	"math/rand"
 	"runtime/debug"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/flyteorg/flytestdlib/config"
 	"github.com/flyteorg/flytestdlib/ioutils"
 )
 
@@ -54,10 +56,13 @@ func dummyCacheStore(t *testing.T, store RawStore, metrics *cacheMetrics) *cache
 
 type dummyStore struct {
 	copyImpl
-	HeadCb     func(ctx context.Context, reference DataReference) (Metadata, error)
-	ReadRawCb  func(ctx context.Context, reference DataReference) (io.ReadCloser, error)
-	WriteRawCb func(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error
-	DeleteCb   func(ctx context.Context, reference DataReference) error
+	HeadCb         func(ctx context.Context, reference DataReference) (Metadata, error)
+	ReadRawCb      func(ctx context.Context, reference DataReference) (io.ReadCloser, error)
+	ReadRawRangeCb func(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error)
+	WriteRawCb     func(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error
+	DeleteCb       func(ctx context.Context, reference DataReference) error
+	ListCb         func(ctx context.Context, prefix DataReference, cursor Cursor, limit int) ([]DataReference, Cursor, error)
+	OpenWriterCb   func(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error)
 }
 
 // CreateSignedURL creates a signed url with the provided properties.
@@ -77,14 +82,26 @@ func (d *dummyStore) ReadRaw(ctx context.Context, reference DataReference) (io.R
 	return d.ReadRawCb(ctx, reference)
 }
 
+func (d *dummyStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	return d.ReadRawRangeCb(ctx, reference, offset, length)
+}
+
 func (d *dummyStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
 	return d.WriteRawCb(ctx, reference, size, opts, raw)
 }
 
+func (d *dummyStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	return d.OpenWriterCb(ctx, reference, opts)
+}
+
 func (d *dummyStore) Delete(ctx context.Context, reference DataReference) error {
 	return d.DeleteCb(ctx, reference)
 }
 
+func (d *dummyStore) List(ctx context.Context, prefix DataReference, cursor Cursor, limit int) ([]DataReference, Cursor, error) {
+	return d.ListCb(ctx, prefix, cursor, limit)
+}
+
 func TestCachedRawStore(t *testing.T) {
 	ctx := context.TODO()
 	k1 := DataReference("k1")
@@ -98,6 +115,7 @@ func TestCachedRawStore(t *testing.T) {
 	assert.NoError(t, err)
 	writeCalled := false
 	readCalled := false
+	readRangeCalled := false
 	store := &dummyStore{
 		HeadCb: func(ctx context.Context, reference DataReference) (Metadata, error) {
 			if reference == "k1" {
@@ -135,6 +153,13 @@ func TestCachedRawStore(t *testing.T) {
 			}
 			return nil, fmt.Errorf("err")
 		},
+		ReadRawRangeCb: func(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+			readRangeCalled = true
+			if reference == "bigK" {
+				return ioutils.NewBytesReadCloser(bigD[offset : offset+length]), nil
+			}
+			return nil, fmt.Errorf("err")
+		},
 		DeleteCb: func(ctx context.Context, reference DataReference) error {
 			if reference == "k1" {
 				return nil
@@ -183,6 +208,29 @@ func TestCachedRawStore(t *testing.T) {
 		assert.True(t, readCalled)
 	})
 
+	t.Run("ReadRangeFromCache", func(t *testing.T) {
+		readRangeCalled = false
+		o, err := cStore.ReadRawRange(ctx, k1, 1, 1)
+		assert.NoError(t, err)
+		b, err := ioutil.ReadAll(o)
+		assert.NoError(t, err)
+		assert.Equal(t, d1[1:2], b)
+		assert.False(t, readRangeCalled)
+	})
+
+	t.Run("ReadRangeNotCached", func(t *testing.T) {
+		readRangeCalled = false
+		o, err := cStore.ReadRawRange(ctx, bigK, 10, 5)
+		assert.NoError(t, err)
+		b, err := ioutil.ReadAll(o)
+		assert.NoError(t, err)
+		assert.Equal(t, bigD[10:15], b)
+		assert.True(t, readRangeCalled)
+
+		_, err = cStore.cache.Get([]byte(bigK))
+		assert.Error(t, err)
+	})
+
 	t.Run("WriteAndRead", func(t *testing.T) {
 		readCalled = false
 		assert.NoError(t, cStore.WriteRaw(ctx, k2, int64(len(d2)), Options{}, bytes.NewReader(d2)))
@@ -221,3 +269,108 @@ func TestCachedRawStore(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestCachedRawStore_Invalidation(t *testing.T) {
+	ctx := context.TODO()
+	newStore := func(t *testing.T, cacheCfg CachingConfig) (*cachedRawStore, RawStore) {
+		underlying, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+		assert.NoError(t, err)
+		cacheCfg.MaxSizeMegabytes = 1
+		cStore := newCachedRawStore(&Config{Cache: cacheCfg}, underlying, metrics.cacheMetrics)
+		return cStore.(*cachedRawStore), underlying
+	}
+
+	write := func(t *testing.T, store RawStore, reference DataReference, data []byte) error {
+		return store.WriteRaw(ctx, reference, int64(len(data)), Options{}, bytes.NewReader(data))
+	}
+
+	read := func(t *testing.T, store RawStore, reference DataReference) string {
+		rc, err := store.ReadRaw(ctx, reference)
+		assert.NoError(t, err)
+		b, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		return string(b)
+	}
+
+	t.Run("Overwrite too big to cache", func(t *testing.T) {
+		cStore, _ := newStore(t, CachingConfig{})
+		assert.NoError(t, write(t, cStore, "mem://container/a", []byte("small")))
+		bigD := bytes.Repeat([]byte("a"), int(MiB))
+		assert.True(t, IsFailedWriteToCache(write(t, cStore, "mem://container/a", bigD)))
+
+		_, err := cStore.cache.Get([]byte("mem://container/a"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		cStore, _ := newStore(t, CachingConfig{})
+		assert.NoError(t, write(t, cStore, "mem://container/a", []byte("hello")))
+		assert.NoError(t, cStore.Delete(ctx, "mem://container/a"))
+		_, err := cStore.ReadRaw(ctx, "mem://container/a")
+		assert.True(t, IsNotFound(err))
+	})
+
+	t.Run("DeletePrefix", func(t *testing.T) {
+		cStore, _ := newStore(t, CachingConfig{})
+		assert.NoError(t, write(t, cStore, "mem://container/dir/a", []byte("hello")))
+		deleted, err := deletePrefix(ctx, cStore, "mem://container/dir", DeleteOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/dir/a"}, deleted)
+		_, err = cStore.ReadRaw(ctx, "mem://container/dir/a")
+		assert.True(t, IsNotFound(err))
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		cStore, _ := newStore(t, CachingConfig{})
+		assert.NoError(t, write(t, cStore, "mem://container/a", []byte("hello")))
+		assert.NoError(t, write(t, cStore, "mem://container/b", []byte("world")))
+		assert.NoError(t, cStore.CopyRaw(ctx, "mem://container/a", "mem://container/b", Options{}))
+		assert.Equal(t, "hello", read(t, cStore, "mem://container/b"))
+	})
+
+	t.Run("Streaming writer", func(t *testing.T) {
+		cStore, _ := newStore(t, CachingConfig{})
+		assert.NoError(t, write(t, cStore, "mem://container/a", []byte("hello")))
+		w, err := cStore.OpenWriter(ctx, "mem://container/a", Options{})
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", read(t, cStore, "mem://container/a"))
+		_, err = w.Write([]byte("world"))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		assert.Equal(t, "world", read(t, cStore, "mem://container/a"))
+	})
+
+	t.Run("Changed by another process", func(t *testing.T) {
+		cStore, underlying := newStore(t, CachingConfig{EtagCheck: true})
+		assert.NoError(t, write(t, cStore, "mem://container/a", []byte("hello")))
+		assert.Equal(t, "hello", read(t, cStore, "mem://container/a"))
+
+		assert.NoError(t, write(t, underlying, "mem://container/a", []byte("world")))
+		assert.Equal(t, "world", read(t, cStore, "mem://container/a"))
+	})
+
+	t.Run("Within staleness window", func(t *testing.T) {
+		cStore, underlying := newStore(t, CachingConfig{
+			EtagCheck:       true,
+			StalenessWindow: config.Duration{Duration: time.Hour},
+		})
+		assert.NoError(t, write(t, cStore, "mem://container/a", []byte("hello")))
+		assert.NoError(t, write(t, underlying, "mem://container/a", []byte("world")))
+		assert.Equal(t, "hello", read(t, cStore, "mem://container/a"))
+
+		cStore.stalenessWindow = 0
+		assert.Equal(t, "world", read(t, cStore, "mem://container/a"))
+	})
+}
+
+func TestCacheEntryEncoding(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano())
+	data, etag, validatedAt, ok := decodeCacheEntry(encodeCacheEntry([]byte("hello"), "etag", now))
+	assert.True(t, ok)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, "etag", etag)
+	assert.True(t, now.Equal(validatedAt))
+
+	_, _, _, ok = decodeCacheEntry([]byte("abc"))
+	assert.False(t, ok)
+}
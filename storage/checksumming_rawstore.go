@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5" // #nosec
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	errs "github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	stdErrs "github.com/flyteorg/flytestdlib/errors"
+	"github.com/flyteorg/flytestdlib/logger"
+)
+
+// ChecksumAlgorithm defines the algorithm used to compute the checksum of written objects.
+type ChecksumAlgorithm = string
+
+const (
+	ChecksumNone   ChecksumAlgorithm = "none"
+	ChecksumMD5    ChecksumAlgorithm = "md5"
+	ChecksumCRC32C ChecksumAlgorithm = "crc32c"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type checksum struct {
+	metadataKey string
+	newHash     func() hash.Hash
+}
+
+var checksums = map[ChecksumAlgorithm]checksum{
+	ChecksumMD5:    {metadataKey: MetadataKeyContentMD5, newHash: md5.New}, // #nosec
+	ChecksumCRC32C: {metadataKey: MetadataKeyContentCRC32C, newHash: func() hash.Hash { return crc32.New(crc32cTable) }},
+}
+
+// verifyingReader hashes data as it's read and fails with ErrChecksumMismatch instead of returning io.EOF if the hash
+// doesn't match the expected checksum. Since the checksum is looked up before the data is read, the object may have
+// been overwritten in between, so the checksum is looked up again through recorded before reporting a mismatch.
+type verifyingReader struct {
+	io.ReadCloser
+	reference DataReference
+	hash      hash.Hash
+	expected  string
+	recorded  func() (string, error)
+	mismatch  prometheus.Counter
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		actual := hex.EncodeToString(r.hash.Sum(nil))
+		if actual == r.expected {
+			return n, err
+		}
+
+		current, recordedErr := r.recorded()
+		if recordedErr != nil {
+			return n, errs.Wrapf(recordedErr, "failed to look up the checksum of [%v] after a mismatch", r.reference)
+		}
+
+		// The data belongs to a newer object if it matches the checksum recorded now, or can't be verified if the
+		// newer object has no checksum.
+		if len(current) == 0 || actual == current {
+			return n, err
+		}
+
+		r.mismatch.Inc()
+		return n, stdErrs.Errorf(ErrChecksumMismatch, "checksum of [%v] is [%v], expected [%v]", r.reference,
+			actual, current)
+	}
+
+	return n, err
+}
+
+// checksummingRawStore records the checksum of objects in their metadata when they're written and verifies it while
+// they're read in full. Objects without a recorded checksum (e.g. written before checksums were enabled) are read
+// without verification, as are ranges.
+type checksummingRawStore struct {
+	RawStore
+	checksum checksum
+	mismatch prometheus.Counter
+}
+
+// WriteRaw computes the checksum of raw before writing it. Readers that aren't seekable are spooled to a local file
+// while they're hashed.
+func (s *checksummingRawStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	seeker, ok := raw.(io.ReadSeeker)
+	if !ok {
+		spoolCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		w, err := s.newChecksummingWriter(spoolCtx, ctx, reference, opts)
+		if err != nil {
+			return err
+		}
+
+		if _, err = io.Copy(w, raw); err != nil {
+			// Canceling the spool discards the data when it's closed.
+			cancel()
+			if closeErr := w.Close(); closeErr != nil {
+				logger.Debugf(ctx, "Discarded spooled data of [%v]. Error: %v", reference, closeErr)
+			}
+
+			return err
+		}
+
+		return w.Close()
+	}
+
+	h := s.checksum.newHash()
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(h, seeker); err != nil {
+		return err
+	}
+
+	if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	return s.writeWithChecksum(ctx, reference, size, opts, h, seeker)
+}
+
+// OpenWriter spools the written data to a local file while it's hashed, then writes it along with its checksum once
+// the writer is closed, since the checksum has to be known before the data is sent.
+func (s *checksummingRawStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	return s.newChecksummingWriter(ctx, ctx, reference, opts)
+}
+
+// newChecksummingWriter returns a writer that hashes the written data as it's spooled. The spool is discarded instead
+// of written if spoolCtx is done by the time the writer is closed. The data is written with ctx.
+func (s *checksummingRawStore) newChecksummingWriter(spoolCtx, ctx context.Context, reference DataReference,
+	opts Options) (io.WriteCloser, error) {
+
+	h := s.checksum.newHash()
+	spool, err := newSpoolingWriter(spoolCtx, func(size int64, raw io.Reader) error {
+		return s.writeWithChecksum(ctx, reference, size, opts, h, raw)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return checksummingWriter{Writer: io.MultiWriter(spool, h), Closer: spool}, nil
+}
+
+// writeWithChecksum records the checksum h computed over raw in the metadata of the written object.
+func (s *checksummingRawStore) writeWithChecksum(ctx context.Context, reference DataReference, size int64,
+	opts Options, h hash.Hash, raw io.Reader) error {
+
+	metadata := make(map[string]interface{}, len(opts.Metadata)+1)
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+
+	metadata[s.checksum.metadataKey] = hex.EncodeToString(h.Sum(nil))
+	opts.Metadata = metadata
+	return s.RawStore.WriteRaw(ctx, reference, size, opts, raw)
+}
+
+// ReadRaw verifies the data against the checksum recorded in the object metadata, if any, as it's read. The returned
+// reader fails with ErrChecksumMismatch once all data is read if it doesn't match.
+func (s *checksummingRawStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	md, err := s.RawStore.Head(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := s.RawStore.ReadRaw(ctx, reference)
+	if err != nil || !md.Exists() {
+		return rc, err
+	}
+
+	algorithm, expected, found := recordedChecksum(md)
+	if !found {
+		return rc, nil
+	}
+
+	return &verifyingReader{
+		ReadCloser: rc,
+		reference:  reference,
+		hash:       checksums[algorithm].newHash(),
+		expected:   expected,
+		recorded: func() (string, error) {
+			md, err := s.RawStore.Head(ctx, reference)
+			if err != nil || !md.Exists() {
+				return "", err
+			}
+
+			return md.UserMetadata()[checksums[algorithm].metadataKey], nil
+		},
+		mismatch: s.mismatch,
+	}, nil
+}
+
+// recordedChecksum returns the checksum recorded in md along with its algorithm. CRC32C is cheaper to compute so it's
+// preferred if both checksums were recorded.
+func recordedChecksum(md Metadata) (algorithm ChecksumAlgorithm, expected string, found bool) {
+	userMetadata := md.UserMetadata()
+	for _, algorithm := range []ChecksumAlgorithm{ChecksumCRC32C, ChecksumMD5} {
+		if expected, found := userMetadata[checksums[algorithm].metadataKey]; found {
+			return algorithm, expected, true
+		}
+	}
+
+	return "", "", false
+}
+
+// checksummingWriter hashes data as it's written to a spoolingWriter.
+type checksummingWriter struct {
+	io.Writer
+	io.Closer
+}
+
+// newChecksummingRawStore wraps store to record and verify checksums with algorithm, or returns store as is if
+// algorithm is none.
+func newChecksummingRawStore(algorithm ChecksumAlgorithm, store RawStore, mismatch prometheus.Counter) (RawStore, error) {
+	if len(algorithm) == 0 || algorithm == ChecksumNone {
+		return store, nil
+	}
+
+	c, found := checksums[algorithm]
+	if !found {
+		return nil, fmt.Errorf("unsupported checksum algorithm [%v]", algorithm)
+	}
+
+	return &checksummingRawStore{
+		RawStore: store,
+		checksum: c,
+		mismatch: mismatch,
+	}, nil
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+func TestNewChecksummingRawStore(t *testing.T) {
+	memStore, err := NewInMemoryRawStore(context.TODO(), &Config{}, metrics)
+	assert.NoError(t, err)
+
+	store, err := newChecksummingRawStore(ChecksumNone, memStore, metrics.protoMetrics.ChecksumMismatch)
+	assert.NoError(t, err)
+	assert.Equal(t, memStore, store)
+
+	_, err = newChecksummingRawStore("sha1", memStore, metrics.protoMetrics.ChecksumMismatch)
+	assert.Error(t, err)
+}
+
+func TestChecksummingRawStore(t *testing.T) {
+	ctx := context.TODO()
+	const ref = DataReference("mem://container/a")
+
+	for _, tc := range []struct {
+		algorithm ChecksumAlgorithm
+		key       string
+		checksum  string
+	}{
+		{algorithm: ChecksumMD5, key: MetadataKeyContentMD5, checksum: "5d41402abc4b2a76b9719d911017c592"},
+		{algorithm: ChecksumCRC32C, key: MetadataKeyContentCRC32C, checksum: "9a71bb4c"},
+	} {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+			assert.NoError(t, err)
+			store, err := newChecksummingRawStore(tc.algorithm, memStore, metrics.protoMetrics.ChecksumMismatch)
+			assert.NoError(t, err)
+
+			t.Run("Records checksum", func(t *testing.T) {
+				assert.NoError(t, store.WriteRaw(ctx, ref, 5, Options{Metadata: map[string]interface{}{"k": "v"}},
+					bytes.NewReader([]byte("hello"))))
+				md, err := memStore.Head(ctx, ref)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.checksum, md.UserMetadata()[tc.key])
+				assert.Equal(t, "v", md.UserMetadata()["k"])
+				assert.Equal(t, "hello", readString(t, store, ref))
+
+				assert.NoError(t, store.WriteRaw(ctx, ref, 5, Options{}, newNotSeekerReader(6)))
+				md, err = memStore.Head(ctx, ref)
+				assert.NoError(t, err)
+				assert.NotEqual(t, tc.checksum, md.UserMetadata()[tc.key])
+				readString(t, store, ref)
+			})
+
+			t.Run("Records checksum of streamed data", func(t *testing.T) {
+				assert.NoError(t, store.WriteRaw(ctx, ref, 5, Options{}, struct{ io.Reader }{bytes.NewReader([]byte("hello"))}))
+				md, err := memStore.Head(ctx, ref)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.checksum, md.UserMetadata()[tc.key])
+				assert.Equal(t, "hello", readString(t, store, ref))
+
+				assert.NoError(t, memStore.Delete(ctx, ref))
+				w, err := store.OpenWriter(ctx, ref, Options{})
+				assert.NoError(t, err)
+				_, err = w.Write([]byte("hel"))
+				assert.NoError(t, err)
+				_, err = w.Write([]byte("lo"))
+				assert.NoError(t, err)
+				assert.NoError(t, w.Close())
+				md, err = memStore.Head(ctx, ref)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.checksum, md.UserMetadata()[tc.key])
+				assert.Equal(t, "hello", readString(t, store, ref))
+			})
+
+			t.Run("Tolerates overwrites while reading", func(t *testing.T) {
+				assert.NoError(t, store.WriteRaw(ctx, ref, 5, Options{}, bytes.NewReader([]byte("hello"))))
+				overwritten, err := newChecksummingRawStore(tc.algorithm, &overwritingStore{
+					RawStore: memStore,
+					overwrite: func() {
+						assert.NoError(t, store.WriteRaw(ctx, ref, 5, Options{}, bytes.NewReader([]byte("world"))))
+					},
+				}, metrics.protoMetrics.ChecksumMismatch)
+				assert.NoError(t, err)
+
+				mismatches := testutil.ToFloat64(metrics.protoMetrics.ChecksumMismatch)
+				assert.Equal(t, "world", readString(t, overwritten, ref))
+				assert.Equal(t, mismatches, testutil.ToFloat64(metrics.protoMetrics.ChecksumMismatch))
+			})
+
+			t.Run("Detects corruption", func(t *testing.T) {
+				assert.NoError(t, memStore.WriteRaw(ctx, ref, 5, Options{Metadata: map[string]interface{}{tc.key: tc.checksum}},
+					bytes.NewReader([]byte("jello"))))
+				mismatches := testutil.ToFloat64(metrics.protoMetrics.ChecksumMismatch)
+				rc, err := store.ReadRaw(ctx, ref)
+				assert.NoError(t, err)
+				_, err = ioutil.ReadAll(rc)
+				assert.True(t, IsChecksumMismatch(err), err)
+				assert.Equal(t, mismatches+1, testutil.ToFloat64(metrics.protoMetrics.ChecksumMismatch))
+			})
+
+			t.Run("Skips objects without checksums", func(t *testing.T) {
+				assert.NoError(t, memStore.WriteRaw(ctx, ref, 5, Options{}, bytes.NewReader([]byte("world"))))
+				assert.Equal(t, "world", readString(t, store, ref))
+			})
+		})
+	}
+}
+
+// overwritingStore calls overwrite before reading, as if the object was overwritten after its metadata was read.
+type overwritingStore struct {
+	RawStore
+	overwrite func()
+}
+
+func (s *overwritingStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	s.overwrite()
+	return s.RawStore.ReadRaw(ctx, reference)
+}
+
+func TestDefaultProtobufStore_Checksum(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+	s, err := NewDefaultProtobufStoreFromConfig(memStore, &Config{Checksum: ChecksumConfig{Algorithm: ChecksumCRC32C}},
+		promutils.NewTestScope())
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.WriteProtobuf(ctx, "mem://container/a", Options{}, &mockProtoMessage{X: 5}))
+	m := &mockProtoMessage{}
+	assert.NoError(t, s.ReadProtobuf(ctx, "mem://container/a", m))
+	assert.Equal(t, int64(5), m.X)
+
+	md, err := memStore.Head(ctx, "mem://container/a")
+	assert.NoError(t, err)
+	assert.NoError(t, memStore.WriteRaw(ctx, "mem://container/a", 2, Options{Metadata: map[string]interface{}{
+		MetadataKeyContentCRC32C: md.UserMetadata()[MetadataKeyContentCRC32C],
+	}}, bytes.NewReader([]byte{0x10, 0x06})))
+	err = s.ReadProtobuf(ctx, "mem://container/a", m)
+	assert.True(t, IsChecksumMismatch(err), err)
+}
+
+// headCountingStore counts the Head calls to the underlying store.
+type headCountingStore struct {
+	RawStore
+	heads int
+}
+
+func (s *headCountingStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+	s.heads++
+	return s.RawStore.Head(ctx, reference)
+}
+
+func TestDataStore_ChecksumBelowCache(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+
+	backend := &headCountingStore{RawStore: memStore}
+	stores["counting"] = func(ctx context.Context, cfg *Config, metrics *dataStoreMetrics) (RawStore, error) {
+		return backend, nil
+	}
+	defer delete(stores, "counting")
+
+	s, err := NewDataStore(&Config{
+		Type:     "counting",
+		Cache:    CachingConfig{MaxSizeMegabytes: 1},
+		Checksum: ChecksumConfig{Algorithm: ChecksumCRC32C},
+	}, promutils.NewTestScope())
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.WriteRaw(ctx, "mem://container/a", 5, Options{}, bytes.NewReader([]byte("hello"))))
+	md, err := memStore.Head(ctx, "mem://container/a")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, md.UserMetadata()[MetadataKeyContentCRC32C])
+
+	// Cache hits are served without looking up the checksum.
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "hello", readString(t, s, "mem://container/a"))
+	}
+
+	assert.Equal(t, 0, backend.heads)
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/flyteorg/flytestdlib/errors"
+)
+
+// CompressionCodec defines the codec used to compress protobufs before writing them.
+type CompressionCodec = string
+
+const (
+	CompressionNone   CompressionCodec = "none"
+	CompressionGzip   CompressionCodec = "gzip"
+	CompressionZstd   CompressionCodec = "zstd"
+	CompressionSnappy CompressionCodec = "snappy"
+)
+
+// compressionMagic prefixes compressed objects and is followed by a single byte identifying the codec. A serialized
+// protobuf can never start with 0xff (it would be a tag with the invalid wire type 7) so uncompressed objects, including
+// those written before compression was enabled, are never mistaken for compressed ones.
+var compressionMagic = []byte{0xff, 'F', 'L', 'Z'}
+
+type compressor interface {
+	// id uniquely identifies the codec in the header of compressed objects. It must never change.
+	id() byte
+	compress(raw []byte) ([]byte, error)
+	// decompress returns an ErrExceedsLimit error if the decompressed data is larger than maxSize bytes.
+	decompress(compressed []byte, maxSize int64) ([]byte, error)
+}
+
+var compressors = map[CompressionCodec]compressor{
+	CompressionGzip:   gzipCompressor{},
+	CompressionZstd:   zstdCompressor{},
+	CompressionSnappy: snappyCompressor{},
+}
+
+func getCompressor(codec CompressionCodec) (compressor, error) {
+	if len(codec) == 0 || codec == CompressionNone {
+		return nil, nil
+	}
+
+	c, found := compressors[codec]
+	if !found {
+		return nil, fmt.Errorf("unsupported compression codec [%v]", codec)
+	}
+
+	return c, nil
+}
+
+// compress compresses raw using the compressor and prefixes it with the compression header. A nil compressor returns
+// raw unchanged.
+func compress(c compressor, raw []byte) ([]byte, error) {
+	if c == nil {
+		return raw, nil
+	}
+
+	compressed, err := c.compress(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]byte, 0, len(compressionMagic)+1+len(compressed))
+	res = append(res, compressionMagic...)
+	res = append(res, c.id())
+	return append(res, compressed...), nil
+}
+
+// decompress detects the compression header and decompresses the data accordingly. Data without a header is
+// returned unchanged. A non-positive maxSize disables the decompressed size limit.
+func decompress(data []byte, maxSize int64) ([]byte, error) {
+	if len(data) <= len(compressionMagic) || !bytes.HasPrefix(data, compressionMagic) {
+		return data, nil
+	}
+
+	id := data[len(compressionMagic)]
+	for _, c := range compressors {
+		if c.id() == id {
+			return c.decompress(data[len(compressionMagic)+1:], maxSize)
+		}
+	}
+
+	return nil, fmt.Errorf("unknown compression codec id [%v]", id)
+}
+
+// readAllLimited reads r fully, failing if it yields more than maxSize bytes.
+func readAllLimited(r io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	raw, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(raw)) > maxSize {
+		return nil, errors.Errorf(ErrExceedsLimit, "decompressed data exceeds limit of %vb", maxSize)
+	}
+
+	return raw, nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) id() byte {
+	return 1
+}
+
+func (gzipCompressor) compress(raw []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(raw); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) decompress(compressed []byte, maxSize int64) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+
+	return readAllLimited(r, maxSize)
+}
+
+type zstdCompressor struct{}
+
+func (zstdCompressor) id() byte {
+	return 2
+}
+
+func (zstdCompressor) compress(raw []byte) ([]byte, error) {
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer w.Close()
+	return w.EncodeAll(raw, nil), nil
+}
+
+func (zstdCompressor) decompress(compressed []byte, maxSize int64) ([]byte, error) {
+	r, err := zstd.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return readAllLimited(r, maxSize)
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) id() byte {
+	return 3
+}
+
+func (snappyCompressor) compress(raw []byte) ([]byte, error) {
+	return snappy.Encode(nil, raw), nil
+}
+
+func (snappyCompressor) decompress(compressed []byte, maxSize int64) ([]byte, error) {
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxSize > 0 && int64(size) > maxSize {
+		return nil, errors.Errorf(ErrExceedsLimit, "decompressed data exceeds limit of %vb", maxSize)
+	}
+
+	return snappy.Decode(nil, compressed)
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	raw := bytes.Repeat([]byte("compressible "), 1000)
+	for _, codec := range []CompressionCodec{CompressionGzip, CompressionZstd, CompressionSnappy} {
+		t.Run(codec, func(t *testing.T) {
+			c, err := getCompressor(codec)
+			assert.NoError(t, err)
+
+			compressed, err := compress(c, raw)
+			assert.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(compressed, compressionMagic))
+			assert.Less(t, len(compressed), len(raw))
+
+			decompressed, err := decompress(compressed, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, raw, decompressed)
+
+			_, err = decompress(compressed, 100)
+			assert.True(t, IsExceedsLimit(err))
+		})
+	}
+
+	t.Run("none", func(t *testing.T) {
+		for _, codec := range []CompressionCodec{"", CompressionNone} {
+			c, err := getCompressor(codec)
+			assert.NoError(t, err)
+			assert.Nil(t, c)
+
+			compressed, err := compress(c, raw)
+			assert.NoError(t, err)
+			assert.Equal(t, raw, compressed)
+		}
+	})
+
+	t.Run("unsupported codec", func(t *testing.T) {
+		_, err := getCompressor("lz4")
+		assert.Error(t, err)
+	})
+
+	t.Run("uncompressed data", func(t *testing.T) {
+		decompressed, err := decompress(raw, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, raw, decompressed)
+	})
+
+	t.Run("unknown codec id", func(t *testing.T) {
+		_, err := decompress(append(append([]byte{}, compressionMagic...), 0, 1, 2), 0)
+		assert.Error(t, err)
+	})
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/flyteorg/flytestdlib/config"
 	"github.com/flyteorg/flytestdlib/logger"
@@ -21,6 +22,7 @@ const (
 	TypeLocal  Type = "local"
 	TypeMinio  Type = "minio"
 	TypeStow   Type = "stow"
+	TypeFaulty Type = "faulty"
 )
 
 const (
@@ -29,7 +31,7 @@ const (
 )
 
 var (
-	ConfigSection = config.MustRegisterSection(configSectionKey, defaultConfig)
+	ConfigSection = config.MustRegisterSectionWithUpdates(configSectionKey, defaultConfig, onConfigUpdated)
 	defaultConfig = &Config{
 		Type: TypeS3,
 		Limits: LimitsConfig{
@@ -40,12 +42,31 @@ var (
 			AuthType: "iam",
 		},
 		MultiContainerEnabled: false,
+		MultipartUpload: MultipartUploadConfig{
+			PartSizeMegabytes: 8,
+			Concurrency:       4,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:    1,
+			InitialBackoff: config.Duration{Duration: 100 * time.Millisecond},
+			MaxBackoff:     config.Duration{Duration: 5 * time.Second},
+		},
+		Mirror: MirrorConfig{
+			Mode: MirrorModeSync,
+		},
+		ReferenceConstructor: ReferenceConstructorConfig{
+			Type: ReferenceConstructorURL,
+			Shards: ShardsConfig{
+				Depth: 1,
+				Width: 2,
+			},
+		},
 	}
 )
 
 // Config is a common storage config.
 type Config struct {
-	Type Type `json:"type" pflag:",Sets the type of storage to configure [s3/minio/local/mem/stow]."`
+	Type Type `json:"type" pflag:",Sets the type of storage to configure [s3/minio/local/mem/stow/faulty]."`
 	// Deprecated: Please use StowConfig instead
 	Connection ConnectionConfig `json:"connection"`
 	Stow       StowConfig       `json:"stow,omitempty" pflag:",Storage config for stow backend."`
@@ -63,6 +84,36 @@ type Config struct {
 	Limits            LimitsConfig     `json:"limits" pflag:",Sets limits for stores."`
 	DefaultHTTPClient HTTPClientConfig `json:"defaultHttpClient" pflag:",Sets the default http client config."`
 	SignedURL         SignedURLConfig  `json:"signedUrl" pflag:",Sets config for SignedURL."`
+	// MultipartUpload configures how writers returned by OpenWriter split a stream into parts. Backends that don't
+	// support multipart uploads ignore this config.
+	MultipartUpload MultipartUploadConfig `json:"multipartUpload" pflag:",Sets config for streaming multipart uploads."`
+	// Encryption enables client-side envelope encryption of all objects written through the store. Objects written
+	// before encryption was enabled can't be read while it's enabled.
+	Encryption EncryptionConfig `json:"encryption" pflag:",Sets config for client-side encryption."`
+	// Compression applies to protobufs written through the DataStore. Compressed protobufs are detected when read
+	// regardless of this config.
+	Compression CompressionConfig `json:"compression" pflag:",Sets config for compressing protobufs."`
+	// Serialization applies to protobufs written through the DataStore. The format is recorded as the content type of
+	// each object and detected from the contents when read, so protobufs are decoded regardless of this config.
+	Serialization SerializationConfig `json:"serialization" pflag:",Sets config for serializing protobufs."`
+	// Checksum applies to objects written and read through the DataStore, including protobufs.
+	Checksum ChecksumConfig `json:"checksum" pflag:",Sets config for verifying the integrity of stored objects."`
+	// Retry applies to operations on the underlying store. Retries are disabled by default.
+	Retry RetryConfig `json:"retry" pflag:",Sets config for retrying failed storage operations."`
+	// RateLimits apply to requests to the underlying store, including retries. Limits are disabled by default.
+	RateLimits RateLimitsConfig `json:"rateLimits" pflag:",Sets config for limiting requests to the backend."`
+	// Mirror replicates every object written through the store to a secondary backend and fails reads over to it.
+	// Mirroring is disabled unless a secondary backend is configured.
+	Mirror MirrorConfig `json:"mirror" pflag:",Sets config for mirroring objects to a secondary backend."`
+	// Mounts route references under a prefix to their own backend instead of the one configured above. The longest
+	// matching prefix wins.
+	Mounts []MountConfig `json:"mounts,omitempty" pflag:"-,Backends mounted under scheme/container prefixes."`
+	// Faults configures the faults injected into the backend when Type is faulty. It's meant for testing how callers
+	// cope with failing storage and must not be used in production.
+	Faults FaultsConfig `json:"faults" pflag:",Sets config for injecting faults into storage operations. Only used if type is faulty."`
+	// ReferenceConstructor configures how the DataStore constructs references. It's only read when the DataStore is
+	// created since changing it would move objects written afterwards.
+	ReferenceConstructor ReferenceConstructorConfig `json:"referenceConstructor" pflag:",Sets config for constructing references."`
 }
 
 // SignedURLConfig encapsulates configs specifically used for SignedURL behavior.
@@ -105,6 +156,61 @@ type CachingConfig struct {
 	// refer to https://golang.org/pkg/runtime/debug/#SetGCPercent
 	// If not specified or set to 0, GC percent is not tweaked
 	TargetGCPercent int `json:"target_gc_percent" pflag:",Sets the garbage collection target percentage."`
+	// Cached objects are always invalidated when written or deleted through the same store. EtagCheck additionally
+	// detects objects changed by other processes by comparing Etags once cached entries are older than
+	// StalenessWindow, at the cost of a Head request per check.
+	EtagCheck       bool            `json:"etag_check" pflag:",Revalidates cached objects against the Etag of the underlying object once they are older than the staleness window."`
+	StalenessWindow config.Duration `json:"staleness_window" pflag:",Time during which cached objects are served without checking their Etag. Only used if etag_check is enabled."`
+	// Disk configures a second cache tier on local disk that survives restarts. It's independent of the in-memory
+	// cache and is disabled unless both a directory and a max size are set.
+	Disk DiskCacheConfig `json:"disk" pflag:",Sets config for the on-disk cache tier."`
+}
+
+// DiskCacheConfig specifies the on-disk LRU cache tier.
+type DiskCacheConfig struct {
+	Dir          string          `json:"dir" pflag:",Directory where cached objects are stored. The disk cache is disabled if not set."`
+	MaxSizeBytes int64           `json:"max_size_bytes" pflag:",Maximum total size (in bytes) of the disk cache. The least recently used objects are evicted beyond it."`
+	TTL          config.Duration `json:"ttl" pflag:",Time after which cached objects expire. If not specified or set to 0, objects never expire."`
+}
+
+// MultipartUploadConfig specifies how streaming writers upload objects in parts. Only S3 supports multipart uploads,
+// writers to other backends spool the object to a local file. S3 rejects parts smaller than 5MB.
+type MultipartUploadConfig struct {
+	PartSizeMegabytes int64 `json:"partSizeMBs" pflag:",Size (in MBs) of each part uploaded by a streaming writer."`
+	Concurrency       int   `json:"concurrency" pflag:",Maximum number of parts a single streaming writer uploads in parallel."`
+}
+
+// EncryptionConfig specifies how objects are encrypted at rest.
+type EncryptionConfig struct {
+	Enabled bool `json:"enabled" pflag:",Enables client-side envelope encryption of stored objects."`
+	// KeyProvider is one of the built-in providers or the kind of a provider registered through RegisterKeyProvider.
+	KeyProvider string `json:"keyProvider" pflag:",Key provider to use [file/env] or the kind of a registered provider."`
+	KeyID       string `json:"keyId" pflag:",Id of the key encryption key. It's recorded with every encrypted object."`
+	KeyFile     string `json:"keyFile" pflag:",Path to a file containing the base64 encoded 256-bit key for the file key provider."`
+	KeyEnvVar   string `json:"keyEnvVar" pflag:",Environment variable containing the base64 encoded 256-bit key for the env key provider."`
+	// PreviousKeyFiles and PreviousKeyEnvVars hold retired keys by id, so that objects encrypted before the key was
+	// rotated can still be read. New objects are always encrypted with the key identified by KeyID.
+	PreviousKeyFiles   map[string]string `json:"previousKeyFiles,omitempty" pflag:",Files containing retired keys by key id for the file key provider."`
+	PreviousKeyEnvVars map[string]string `json:"previousKeyEnvVars,omitempty" pflag:",Environment variables containing retired keys by key id for the env key provider."`
+	// Config holds arbitrary settings for registered key providers (e.g. a KMS key ARN).
+	Config map[string]string `json:"config,omitempty" pflag:",Configuration for a registered key provider."`
+}
+
+// CompressionConfig specifies how protobufs are compressed before being written.
+type CompressionConfig struct {
+	Codec CompressionCodec `json:"codec" pflag:",Codec used to compress protobufs before writing them [none/gzip/zstd/snappy]."`
+}
+
+// SerializationConfig specifies how protobufs are serialized before being written.
+type SerializationConfig struct {
+	Format WireFormat `json:"format" pflag:",Wire format protobufs are written in [binary/json/text]."`
+}
+
+// ChecksumConfig specifies how the integrity of objects is verified end-to-end.
+type ChecksumConfig struct {
+	// Algorithm is used to compute the checksum recorded in the metadata of written objects. Objects that have a
+	// recorded checksum are verified when read in full regardless of the algorithm, unless it's none.
+	Algorithm ChecksumAlgorithm `json:"algorithm" pflag:",Checksum recorded when writing objects and verified when reading them [none/md5/crc32c]."`
 }
 
 // LimitsConfig specifies limits for storage package.
@@ -121,3 +227,94 @@ func GetConfig() *Config {
 	logger.Warnf(context.TODO(), "Failed to retrieve config section [%v].", configSectionKey)
 	return nil
 }
+
+// RetryConfig specifies how failed storage operations are retried. Only errors considered transient (see IsRetryable)
+// are retried. The backoff between attempts grows exponentially from InitialBackoff up to MaxBackoff and is randomized
+// to avoid retrying in lockstep.
+type RetryConfig struct {
+	MaxAttempts    int             `json:"maxAttempts" pflag:",Maximum number of attempts for an operation including the first one. Values lower than 2 disable retries."`
+	InitialBackoff config.Duration `json:"initialBackoff" pflag:",Maximum backoff before the first retry. It doubles with every subsequent retry."`
+	MaxBackoff     config.Duration `json:"maxBackoff" pflag:",Maximum backoff between two attempts."`
+}
+
+// RateLimitsConfig specifies how requests to a backend are throttled to stay within its request quotas. The top level
+// limits are shared by all operations while those in Operations only apply to the operation they're keyed by (one of
+// head, read, read_range, write, open_writer, delete, list or copy). Requests wait for both before being sent. Zero
+// values disable a limit.
+type RateLimitsConfig struct {
+	MaxInFlight       int     `json:"maxInFlight" pflag:",Maximum number of requests in flight to the backend. 0 disables the limit."`
+	RequestsPerSecond float64 `json:"requestsPerSecond" pflag:",Maximum sustained number of requests per second to the backend. 0 disables the limit."`
+	Burst             int     `json:"burst" pflag:",Maximum number of requests sent at once when below the rate. Defaults to 1."`
+	// Operations holds additional limits per operation type.
+	Operations map[string]RateLimitConfig `json:"operations,omitempty" pflag:"-,Limits per operation type."`
+}
+
+// RateLimitConfig specifies the limits of a single operation type.
+type RateLimitConfig struct {
+	MaxInFlight       int     `json:"maxInFlight"`
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// MirrorConfig specifies the secondary backend objects are mirrored to and how they're replicated.
+type MirrorConfig struct {
+	// Secondary configures the backend objects are mirrored to. Only its backend and retry configs are used; caching,
+	// encryption and compression are applied once on top of both backends as configured for the primary.
+	Secondary *Config `json:"secondary,omitempty" pflag:"-,Config of the secondary backend."`
+	// Mode is either sync, where writes only succeed once replicated, or async, where writes are replicated in the
+	// background after they succeed on the primary.
+	Mode MirrorMode `json:"mode" pflag:",Replication mode [sync/async]."`
+	// RepairOnRead checks that objects read from the primary exist in the secondary and replicates them if they don't.
+	RepairOnRead bool `json:"repairOnRead" pflag:",Replicates objects missing from the secondary backend when they're read."`
+}
+
+// MountConfig maps references under a prefix (e.g. s3://my-bucket or file://scratch/tmp) to a backend.
+type MountConfig struct {
+	Prefix string `json:"prefix" pflag:",Scheme and container, optionally followed by a key prefix, routed to this backend."`
+	// Config configures the mounted backend. Only its backend and retry configs are used; caching, encryption and
+	// compression are applied once on top of all mounts.
+	Config Config `json:"config" pflag:",Config of the mounted backend."`
+}
+
+// FaultsConfig specifies the backend faults are injected into and which faults are injected.
+type FaultsConfig struct {
+	// Type is the type of the backend faults are injected into. The rest of the storage config applies to it as usual.
+	Type Type  `json:"type" pflag:",Type of the storage faults are injected into."`
+	Seed int64 `json:"seed" pflag:",Seed of the random faults. If not specified or set to 0, a random seed is used."`
+	// Rules are evaluated in order and the first one matching an operation decides which faults it's subject to.
+	// Operations no rule matches are passed through.
+	Rules []FaultRule `json:"rules,omitempty" pflag:"-,Faults to inject into matching operations."`
+}
+
+// FaultRule specifies the probabilities (between 0 and 1) of the faults injected into matching operations.
+type FaultRule struct {
+	// Operations are the operations the rule applies to [head/read/read_range/write/open_writer/copy/delete/list]. If
+	// empty, the rule applies to all of them.
+	Operations []string `json:"operations,omitempty" pflag:",Operations the rule applies to."`
+	// References is a glob (see path.Match) matched against the whole reference, e.g. s3://bucket/*/outputs.pb. If
+	// empty, the rule applies to all references.
+	References string `json:"references" pflag:",Glob of the references the rule applies to."`
+	// ErrorProbability is the probability of failing the operation with a retryable error.
+	ErrorProbability float64 `json:"errorProbability" pflag:",Probability of failing with a retryable error."`
+	// NotFoundProbability is the probability of reporting that the reference doesn't exist.
+	NotFoundProbability float64 `json:"notFoundProbability" pflag:",Probability of reporting that the reference doesn't exist."`
+	// PartialReadProbability is the probability of a read failing with io.ErrUnexpectedEOF partway through the data.
+	PartialReadProbability float64 `json:"partialReadProbability" pflag:",Probability of a read ending before all the data is read."`
+	// LatencyProbability is the probability of delaying the operation by Latency.
+	LatencyProbability float64         `json:"latencyProbability" pflag:",Probability of delaying the operation."`
+	Latency            config.Duration `json:"latency" pflag:",Delay added to delayed operations."`
+}
+
+// ReferenceConstructorConfig specifies how references are constructed from a base reference and nested keys.
+type ReferenceConstructorConfig struct {
+	Type ReferenceConstructorType `json:"type" pflag:",Constructor of references [url/sharded]."`
+	// Shards configures the hash shards the sharded constructor inserts between the base reference and nested keys to
+	// spread objects across key prefixes (e.g. to avoid S3 per-prefix throttling).
+	Shards ShardsConfig `json:"shards" pflag:",Sets config for the shards of the sharded constructor."`
+}
+
+// ShardsConfig specifies the layout of the hash shards inserted into references.
+type ShardsConfig struct {
+	Depth int `json:"depth" pflag:",Number of shard path segments inserted into references."`
+	Width int `json:"width" pflag:",Number of hex characters in each shard path segment."`
+}
@@ -50,7 +50,7 @@ func (Config) mustMarshalJSON(v json.Marshaler) string {
 // flags is json-name.json-sub-name... etc.
 func (cfg Config) GetPFlagSet(prefix string) *pflag.FlagSet {
 	cmdFlags := pflag.NewFlagSet("Config", pflag.ExitOnError)
-	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "type"), defaultConfig.Type, "Sets the type of storage to configure [s3/minio/local/mem/stow].")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "type"), defaultConfig.Type, "Sets the type of storage to configure [s3/minio/local/mem/stow/faulty].")
 	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "connection.endpoint"), defaultConfig.Connection.Endpoint.String(), "URL for storage client to connect to.")
 	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "connection.auth-type"), defaultConfig.Connection.AuthType, "Auth Type to use [iam, accesskey].")
 	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "connection.access-key"), defaultConfig.Connection.AccessKey, "Access key to use. Only required when authtype is set to accesskey.")
@@ -63,7 +63,38 @@ func (cfg Config) GetPFlagSet(prefix string) *pflag.FlagSet {
 	cmdFlags.Bool(fmt.Sprintf("%v%v", prefix, "enable-multicontainer"), defaultConfig.MultiContainerEnabled, "If this is true,  then the container argument is overlooked and redundant. This config will automatically open new connections to new containers/buckets as they are encountered")
 	cmdFlags.Int(fmt.Sprintf("%v%v", prefix, "cache.max_size_mbs"), defaultConfig.Cache.MaxSizeMegabytes, "Maximum size of the cache where the Blob store data is cached in-memory. If not specified or set to 0,  cache is not used")
 	cmdFlags.Int(fmt.Sprintf("%v%v", prefix, "cache.target_gc_percent"), defaultConfig.Cache.TargetGCPercent, "Sets the garbage collection target percentage.")
+	cmdFlags.Bool(fmt.Sprintf("%v%v", prefix, "cache.etag_check"), defaultConfig.Cache.EtagCheck, "Revalidates cached objects against the Etag of the underlying object once they are older than the staleness window.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "cache.staleness_window"), defaultConfig.Cache.StalenessWindow.String(), "Time during which cached objects are served without checking their Etag. Only used if etag_check is enabled.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "cache.disk.dir"), defaultConfig.Cache.Disk.Dir, "Directory where cached objects are stored. The disk cache is disabled if not set.")
+	cmdFlags.Int64(fmt.Sprintf("%v%v", prefix, "cache.disk.max_size_bytes"), defaultConfig.Cache.Disk.MaxSizeBytes, "Maximum total size (in bytes) of the disk cache. The least recently used objects are evicted beyond it.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "cache.disk.ttl"), defaultConfig.Cache.Disk.TTL.String(), "Time after which cached objects expire. If not specified or set to 0,  objects never expire.")
 	cmdFlags.Int64(fmt.Sprintf("%v%v", prefix, "limits.maxDownloadMBs"), defaultConfig.Limits.GetLimitMegabytes, "Maximum allowed download size (in MBs) per call.")
 	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "defaultHttpClient.timeout"), defaultConfig.DefaultHTTPClient.Timeout.String(), "Sets time out on the http client.")
+	cmdFlags.Int64(fmt.Sprintf("%v%v", prefix, "multipartUpload.partSizeMBs"), defaultConfig.MultipartUpload.PartSizeMegabytes, "Size (in MBs) of each part uploaded by a streaming writer.")
+	cmdFlags.Int(fmt.Sprintf("%v%v", prefix, "multipartUpload.concurrency"), defaultConfig.MultipartUpload.Concurrency, "Maximum number of parts a single streaming writer uploads in parallel.")
+	cmdFlags.Bool(fmt.Sprintf("%v%v", prefix, "encryption.enabled"), defaultConfig.Encryption.Enabled, "Enables client-side envelope encryption of stored objects.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "encryption.keyProvider"), defaultConfig.Encryption.KeyProvider, "Key provider to use [file/env] or the kind of a registered provider.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "encryption.keyId"), defaultConfig.Encryption.KeyID, "Id of the key encryption key. It's recorded with every encrypted object.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "encryption.keyFile"), defaultConfig.Encryption.KeyFile, "Path to a file containing the base64 encoded 256-bit key for the file key provider.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "encryption.keyEnvVar"), defaultConfig.Encryption.KeyEnvVar, "Environment variable containing the base64 encoded 256-bit key for the env key provider.")
+	cmdFlags.StringToString(fmt.Sprintf("%v%v", prefix, "encryption.previousKeyFiles"), defaultConfig.Encryption.PreviousKeyFiles, "Files containing retired keys by key id for the file key provider.")
+	cmdFlags.StringToString(fmt.Sprintf("%v%v", prefix, "encryption.previousKeyEnvVars"), defaultConfig.Encryption.PreviousKeyEnvVars, "Environment variables containing retired keys by key id for the env key provider.")
+	cmdFlags.StringToString(fmt.Sprintf("%v%v", prefix, "encryption.config"), defaultConfig.Encryption.Config, "Configuration for a registered key provider.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "compression.codec"), defaultConfig.Compression.Codec, "Codec used to compress protobufs before writing them [none/gzip/zstd/snappy].")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "serialization.format"), defaultConfig.Serialization.Format, "Wire format protobufs are written in [binary/json/text].")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "checksum.algorithm"), defaultConfig.Checksum.Algorithm, "Checksum recorded when writing objects and verified when reading them [none/md5/crc32c].")
+	cmdFlags.Int(fmt.Sprintf("%v%v", prefix, "retry.maxAttempts"), defaultConfig.Retry.MaxAttempts, "Maximum number of attempts for an operation including the first one. Values lower than 2 disable retries.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "retry.initialBackoff"), defaultConfig.Retry.InitialBackoff.String(), "Maximum backoff before the first retry. It doubles with every subsequent retry.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "retry.maxBackoff"), defaultConfig.Retry.MaxBackoff.String(), "Maximum backoff between two attempts.")
+	cmdFlags.Int(fmt.Sprintf("%v%v", prefix, "rateLimits.maxInFlight"), defaultConfig.RateLimits.MaxInFlight, "Maximum number of requests in flight to the backend. 0 disables the limit.")
+	cmdFlags.Float64(fmt.Sprintf("%v%v", prefix, "rateLimits.requestsPerSecond"), defaultConfig.RateLimits.RequestsPerSecond, "Maximum sustained number of requests per second to the backend. 0 disables the limit.")
+	cmdFlags.Int(fmt.Sprintf("%v%v", prefix, "rateLimits.burst"), defaultConfig.RateLimits.Burst, "Maximum number of requests sent at once when below the rate. Defaults to 1.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "mirror.mode"), defaultConfig.Mirror.Mode, "Replication mode [sync/async].")
+	cmdFlags.Bool(fmt.Sprintf("%v%v", prefix, "mirror.repairOnRead"), defaultConfig.Mirror.RepairOnRead, "Replicates objects missing from the secondary backend when they're read.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "faults.type"), defaultConfig.Faults.Type, "Type of the storage faults are injected into.")
+	cmdFlags.Int64(fmt.Sprintf("%v%v", prefix, "faults.seed"), defaultConfig.Faults.Seed, "Seed of the random faults. If not specified or set to 0, a random seed is used.")
+	cmdFlags.String(fmt.Sprintf("%v%v", prefix, "referenceConstructor.type"), defaultConfig.ReferenceConstructor.Type, "Constructor of references [url/sharded].")
+	cmdFlags.Int(fmt.Sprintf("%v%v", prefix, "referenceConstructor.shards.depth"), defaultConfig.ReferenceConstructor.Shards.Depth, "Number of shard path segments inserted into references.")
+	cmdFlags.Int(fmt.Sprintf("%v%v", prefix, "referenceConstructor.shards.width"), defaultConfig.ReferenceConstructor.Shards.Width, "Number of hex characters in each shard path segment.")
 	return cmdFlags
 }
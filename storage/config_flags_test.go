@@ -281,6 +281,76 @@ func TestConfig_SetFlags(t *testing.T) {
 			}
 		})
 	})
+	t.Run("Test_cache.etag_check", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("cache.etag_check", testValue)
+			if vBool, err := cmdFlags.GetBool("cache.etag_check"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vBool), &actual.Cache.EtagCheck)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_cache.staleness_window", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := defaultConfig.Cache.StalenessWindow.String()
+
+			cmdFlags.Set("cache.staleness_window", testValue)
+			if vString, err := cmdFlags.GetString("cache.staleness_window"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Cache.StalenessWindow)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_cache.disk.dir", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("cache.disk.dir", testValue)
+			if vString, err := cmdFlags.GetString("cache.disk.dir"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Cache.Disk.Dir)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_cache.disk.max_size_bytes", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("cache.disk.max_size_bytes", testValue)
+			if vInt64, err := cmdFlags.GetInt64("cache.disk.max_size_bytes"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vInt64), &actual.Cache.Disk.MaxSizeBytes)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_cache.disk.ttl", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := defaultConfig.Cache.Disk.TTL.String()
+
+			cmdFlags.Set("cache.disk.ttl", testValue)
+			if vString, err := cmdFlags.GetString("cache.disk.ttl"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Cache.Disk.TTL)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
 	t.Run("Test_limits.maxDownloadMBs", func(t *testing.T) {
 
 		t.Run("Override", func(t *testing.T) {
@@ -309,4 +379,368 @@ func TestConfig_SetFlags(t *testing.T) {
 			}
 		})
 	})
+	t.Run("Test_multipartUpload.partSizeMBs", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("multipartUpload.partSizeMBs", testValue)
+			if vInt64, err := cmdFlags.GetInt64("multipartUpload.partSizeMBs"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vInt64), &actual.MultipartUpload.PartSizeMegabytes)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_multipartUpload.concurrency", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("multipartUpload.concurrency", testValue)
+			if vInt, err := cmdFlags.GetInt("multipartUpload.concurrency"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vInt), &actual.MultipartUpload.Concurrency)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_encryption.enabled", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("encryption.enabled", testValue)
+			if vBool, err := cmdFlags.GetBool("encryption.enabled"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vBool), &actual.Encryption.Enabled)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_encryption.keyProvider", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("encryption.keyProvider", testValue)
+			if vString, err := cmdFlags.GetString("encryption.keyProvider"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Encryption.KeyProvider)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_encryption.keyId", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("encryption.keyId", testValue)
+			if vString, err := cmdFlags.GetString("encryption.keyId"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Encryption.KeyID)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_encryption.keyFile", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("encryption.keyFile", testValue)
+			if vString, err := cmdFlags.GetString("encryption.keyFile"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Encryption.KeyFile)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_encryption.keyEnvVar", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("encryption.keyEnvVar", testValue)
+			if vString, err := cmdFlags.GetString("encryption.keyEnvVar"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Encryption.KeyEnvVar)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_encryption.previousKeyFiles", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "a=1,b=2"
+
+			cmdFlags.Set("encryption.previousKeyFiles", testValue)
+			if vStringToString, err := cmdFlags.GetStringToString("encryption.previousKeyFiles"); err == nil {
+				testDecodeRaw_Config(t, vStringToString, &actual.Encryption.PreviousKeyFiles)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_encryption.previousKeyEnvVars", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "a=1,b=2"
+
+			cmdFlags.Set("encryption.previousKeyEnvVars", testValue)
+			if vStringToString, err := cmdFlags.GetStringToString("encryption.previousKeyEnvVars"); err == nil {
+				testDecodeRaw_Config(t, vStringToString, &actual.Encryption.PreviousKeyEnvVars)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_encryption.config", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "a=1,b=2"
+
+			cmdFlags.Set("encryption.config", testValue)
+			if vStringToString, err := cmdFlags.GetStringToString("encryption.config"); err == nil {
+				testDecodeRaw_Config(t, vStringToString, &actual.Encryption.Config)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_compression.codec", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("compression.codec", testValue)
+			if vString, err := cmdFlags.GetString("compression.codec"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Compression.Codec)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_serialization.format", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("serialization.format", testValue)
+			if vString, err := cmdFlags.GetString("serialization.format"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Serialization.Format)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_checksum.algorithm", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("checksum.algorithm", testValue)
+			if vString, err := cmdFlags.GetString("checksum.algorithm"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Checksum.Algorithm)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_retry.maxAttempts", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("retry.maxAttempts", testValue)
+			if vInt, err := cmdFlags.GetInt("retry.maxAttempts"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vInt), &actual.Retry.MaxAttempts)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_retry.initialBackoff", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := defaultConfig.Retry.InitialBackoff.String()
+
+			cmdFlags.Set("retry.initialBackoff", testValue)
+			if vString, err := cmdFlags.GetString("retry.initialBackoff"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Retry.InitialBackoff)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_retry.maxBackoff", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := defaultConfig.Retry.MaxBackoff.String()
+
+			cmdFlags.Set("retry.maxBackoff", testValue)
+			if vString, err := cmdFlags.GetString("retry.maxBackoff"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Retry.MaxBackoff)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_rateLimits.maxInFlight", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("rateLimits.maxInFlight", testValue)
+			if vInt, err := cmdFlags.GetInt("rateLimits.maxInFlight"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vInt), &actual.RateLimits.MaxInFlight)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_rateLimits.requestsPerSecond", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("rateLimits.requestsPerSecond", testValue)
+			if vFloat64, err := cmdFlags.GetFloat64("rateLimits.requestsPerSecond"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vFloat64), &actual.RateLimits.RequestsPerSecond)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_rateLimits.burst", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("rateLimits.burst", testValue)
+			if vInt, err := cmdFlags.GetInt("rateLimits.burst"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vInt), &actual.RateLimits.Burst)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_mirror.mode", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("mirror.mode", testValue)
+			if vString, err := cmdFlags.GetString("mirror.mode"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Mirror.Mode)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_mirror.repairOnRead", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("mirror.repairOnRead", testValue)
+			if vBool, err := cmdFlags.GetBool("mirror.repairOnRead"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vBool), &actual.Mirror.RepairOnRead)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_faults.type", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("faults.type", testValue)
+			if vString, err := cmdFlags.GetString("faults.type"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.Faults.Type)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_faults.seed", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("faults.seed", testValue)
+			if vInt64, err := cmdFlags.GetInt64("faults.seed"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vInt64), &actual.Faults.Seed)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_referenceConstructor.type", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("referenceConstructor.type", testValue)
+			if vString, err := cmdFlags.GetString("referenceConstructor.type"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vString), &actual.ReferenceConstructor.Type)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_referenceConstructor.shards.depth", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("referenceConstructor.shards.depth", testValue)
+			if vInt, err := cmdFlags.GetInt("referenceConstructor.shards.depth"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vInt), &actual.ReferenceConstructor.Shards.Depth)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
+	t.Run("Test_referenceConstructor.shards.width", func(t *testing.T) {
+
+		t.Run("Override", func(t *testing.T) {
+			testValue := "1"
+
+			cmdFlags.Set("referenceConstructor.shards.width", testValue)
+			if vInt, err := cmdFlags.GetInt("referenceConstructor.shards.width"); err == nil {
+				testDecodeJson_Config(t, fmt.Sprintf("%v", vInt), &actual.ReferenceConstructor.Shards.Width)
+
+			} else {
+				assert.FailNow(t, err.Error())
+			}
+		})
+	})
 }
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+
+	stdErrs "github.com/flyteorg/flytestdlib/errors"
+	"github.com/flyteorg/flytestdlib/ioutils"
+	"github.com/flyteorg/flytestdlib/logger"
+	"github.com/flyteorg/flytestdlib/pbhash"
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+const (
+	defaultContentAddressedPrefix = "cas"
+	rawContentKey                 = "sha256"
+)
+
+// ContentAddressedConfig specifies where a ContentAddressedStore writes content and how it reads it back.
+type ContentAddressedConfig struct {
+	// Prefix is the key under the base container that content is written to. Defaults to "cas".
+	Prefix string `json:"prefix"`
+	// VerifyOnRead recomputes the hash of content read back and fails the read if it doesn't match its reference.
+	VerifyOnRead bool `json:"verifyOnRead"`
+}
+
+type contentAddressedMetrics struct {
+	Written        prometheus.Counter
+	Deduplicated   prometheus.Counter
+	VerifyFailures prometheus.Counter
+	FetchLatency   promutils.StopWatch
+}
+
+// ContentAddressedStore writes protobufs and blobs under a reference derived from the hash of their content so that
+// identical content is only ever stored once. Protobufs are hashed with pbhash.ComputeHash, which is stable across
+// marshalling, and keyed by their message name. Blobs are hashed with SHA-256.
+type ContentAddressedStore struct {
+	store        *DataStore
+	prefix       string
+	verifyOnRead bool
+	metrics      *contentAddressedMetrics
+}
+
+// ProtobufReference returns the reference msg is, or would be, written to.
+func (s *ContentAddressedStore) ProtobufReference(ctx context.Context, msg proto.Message) (DataReference, error) {
+	hash, err := pbhash.ComputeHash(ctx, msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash protobuf. Error: %w", err)
+	}
+
+	return s.reference(ctx, proto.MessageName(msg), hash)
+}
+
+// RawReference returns the reference raw is, or would be, written to.
+func (s *ContentAddressedStore) RawReference(ctx context.Context, raw []byte) (DataReference, error) {
+	hash := sha256.Sum256(raw)
+	return s.reference(ctx, rawContentKey, hash[:])
+}
+
+func (s *ContentAddressedStore) reference(ctx context.Context, kind string, hash []byte) (DataReference, error) {
+	if len(kind) == 0 {
+		return "", fmt.Errorf("content kind must not be empty")
+	}
+
+	return s.store.ConstructReference(ctx, s.store.GetBaseContainerFQN(ctx), s.prefix, kind, hex.EncodeToString(hash))
+}
+
+// WriteProtobuf writes msg unless identical content already exists and returns its reference. Options.IfNotExists is
+// always set.
+func (s *ContentAddressedStore) WriteProtobuf(ctx context.Context, opts Options, msg proto.Message) (DataReference, error) {
+	reference, err := s.ProtobufReference(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+
+	opts.IfNotExists = true
+	return reference, s.handleWriteErr(ctx, reference, s.store.WriteProtobuf(ctx, reference, opts, msg))
+}
+
+// WriteRaw writes raw unless identical content already exists and returns its reference. Options.IfNotExists is
+// always set.
+func (s *ContentAddressedStore) WriteRaw(ctx context.Context, opts Options, raw []byte) (DataReference, error) {
+	reference, err := s.RawReference(ctx, raw)
+	if err != nil {
+		return "", err
+	}
+
+	opts.IfNotExists = true
+	return reference, s.handleWriteErr(ctx, reference,
+		s.store.WriteRaw(ctx, reference, int64(len(raw)), opts, bytes.NewReader(raw)))
+}
+
+// handleWriteErr treats writes that failed because the reference already exists as successful, since the existing
+// object has the same content.
+func (s *ContentAddressedStore) handleWriteErr(ctx context.Context, reference DataReference, err error) error {
+	if err == nil {
+		s.metrics.Written.Inc()
+		return nil
+	}
+
+	if IsPreconditionFailed(err) || IsExists(err) {
+		logger.Debugf(ctx, "Content [%v] already exists.", reference)
+		s.metrics.Deduplicated.Inc()
+		return nil
+	}
+
+	return err
+}
+
+// ReadProtobuf reads the protobuf at reference into msg. If VerifyOnRead is set, it fails with ErrHashMismatch if the
+// hash of msg doesn't match reference.
+func (s *ContentAddressedStore) ReadProtobuf(ctx context.Context, reference DataReference, msg proto.Message) error {
+	if err := s.store.ReadProtobuf(ctx, reference, msg); err != nil {
+		return err
+	}
+
+	if !s.verifyOnRead {
+		return nil
+	}
+
+	hash, err := pbhash.ComputeHash(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to hash protobuf [%v]. Error: %w", reference, err)
+	}
+
+	return s.verify(reference, hash)
+}
+
+// ReadRaw reads the blob at reference. If VerifyOnRead is set, it fails with ErrHashMismatch if the hash of the blob
+// doesn't match reference.
+func (s *ContentAddressedStore) ReadRaw(ctx context.Context, reference DataReference) ([]byte, error) {
+	rc, err := s.store.ReadRaw(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rc.Close(); err != nil {
+			logger.Warnf(ctx, "Failed to close reference [%v]. Error: %v", reference, err)
+		}
+	}()
+
+	raw, err := ioutils.ReadAll(rc, s.metrics.FetchLatency.Start())
+	if err != nil {
+		return nil, err
+	}
+
+	if s.verifyOnRead {
+		hash := sha256.Sum256(raw)
+		if err = s.verify(reference, hash[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return raw, nil
+}
+
+func (s *ContentAddressedStore) verify(reference DataReference, hash []byte) error {
+	_, _, key, err := reference.Split()
+	if err != nil {
+		return err
+	}
+
+	if expected, actual := path.Base(key), hex.EncodeToString(hash); expected != actual {
+		s.metrics.VerifyFailures.Inc()
+		return stdErrs.Errorf(ErrHashMismatch, "content of [%v] hashes to [%v]", reference, actual)
+	}
+
+	return nil
+}
+
+// NewContentAddressedStore creates a ContentAddressedStore that writes content under cfg.Prefix in the base container
+// of store.
+func NewContentAddressedStore(store *DataStore, cfg ContentAddressedConfig, scope promutils.Scope) *ContentAddressedStore {
+	prefix := cfg.Prefix
+	if len(prefix) == 0 {
+		prefix = defaultContentAddressedPrefix
+	}
+
+	return &ContentAddressedStore{
+		store:        store,
+		prefix:       prefix,
+		verifyOnRead: cfg.VerifyOnRead,
+		metrics: &contentAddressedMetrics{
+			Written:        scope.MustNewCounter("cas_written", "Number of objects written to the content-addressed store"),
+			Deduplicated:   scope.MustNewCounter("cas_deduplicated", "Number of writes skipped because the content already existed"),
+			VerifyFailures: scope.MustNewCounter("cas_verify_failures", "Number of reads whose content didn't match its hash"),
+			FetchLatency:   scope.MustNewStopWatch("cas_fetch", "Time to read content before verifying it", time.Millisecond),
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+func TestContentAddressedStore_Protobuf(t *testing.T) {
+	ctx := context.TODO()
+	dataStore, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+	assert.NoError(t, err)
+
+	t.Run("Dedupes identical messages", func(t *testing.T) {
+		s := NewContentAddressedStore(dataStore, ContentAddressedConfig{}, promutils.NewTestScope())
+		ref1, err := s.WriteProtobuf(ctx, Options{}, &duration.Duration{Seconds: 5})
+		assert.NoError(t, err)
+		assert.True(t, strings.Contains(ref1.String(), "/cas/google.protobuf.Duration/"), ref1)
+
+		ref2, err := s.WriteProtobuf(ctx, Options{}, &duration.Duration{Seconds: 5})
+		assert.NoError(t, err)
+		assert.Equal(t, ref1, ref2)
+		assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.Written))
+		assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.Deduplicated))
+
+		ref3, err := s.WriteProtobuf(ctx, Options{}, &duration.Duration{Seconds: 6})
+		assert.NoError(t, err)
+		assert.NotEqual(t, ref1, ref3)
+
+		msg := &duration.Duration{}
+		assert.NoError(t, s.ReadProtobuf(ctx, ref1, msg))
+		assert.True(t, proto.Equal(&duration.Duration{Seconds: 5}, msg))
+	})
+
+	t.Run("Keys by message name", func(t *testing.T) {
+		s := NewContentAddressedStore(dataStore, ContentAddressedConfig{Prefix: "blobs"}, promutils.NewTestScope())
+		ref1, err := s.ProtobufReference(ctx, &duration.Duration{Seconds: 5})
+		assert.NoError(t, err)
+		ref2, err := s.ProtobufReference(ctx, &timestamp.Timestamp{Seconds: 5})
+		assert.NoError(t, err)
+		assert.NotEqual(t, ref1, ref2)
+		assert.True(t, strings.Contains(ref1.String(), "/blobs/"), ref1)
+	})
+
+	t.Run("Verify on read", func(t *testing.T) {
+		s := NewContentAddressedStore(dataStore, ContentAddressedConfig{VerifyOnRead: true}, promutils.NewTestScope())
+		ref, err := s.WriteProtobuf(ctx, Options{}, &duration.Duration{Seconds: 5})
+		assert.NoError(t, err)
+		assert.NoError(t, s.ReadProtobuf(ctx, ref, &duration.Duration{}))
+
+		assert.NoError(t, dataStore.WriteProtobuf(ctx, ref, Options{}, &duration.Duration{Seconds: 7}))
+		err = s.ReadProtobuf(ctx, ref, &duration.Duration{})
+		assert.True(t, IsHashMismatch(err), err)
+		assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.VerifyFailures))
+	})
+}
+
+func TestContentAddressedStore_Raw(t *testing.T) {
+	ctx := context.TODO()
+	dataStore, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+	assert.NoError(t, err)
+
+	t.Run("Dedupes identical blobs", func(t *testing.T) {
+		s := NewContentAddressedStore(dataStore, ContentAddressedConfig{}, promutils.NewTestScope())
+		ref1, err := s.WriteRaw(ctx, Options{}, []byte("hello"))
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(ref1.String(),
+			"/cas/sha256/2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"), ref1)
+
+		ref2, err := s.WriteRaw(ctx, Options{}, []byte("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, ref1, ref2)
+		assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.Deduplicated))
+
+		raw, err := s.ReadRaw(ctx, ref1)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(raw))
+	})
+
+	t.Run("Verify on read", func(t *testing.T) {
+		s := NewContentAddressedStore(dataStore, ContentAddressedConfig{VerifyOnRead: true}, promutils.NewTestScope())
+		ref, err := s.WriteRaw(ctx, Options{}, []byte("hello"))
+		assert.NoError(t, err)
+
+		assert.NoError(t, dataStore.WriteRaw(ctx, ref, 5, Options{}, bytes.NewReader([]byte("world"))))
+		_, err = s.ReadRaw(ctx, ref)
+		assert.True(t, IsHashMismatch(err), err)
+	})
+
+	t.Run("Skips verification", func(t *testing.T) {
+		s := NewContentAddressedStore(dataStore, ContentAddressedConfig{}, promutils.NewTestScope())
+		ref, err := s.WriteRaw(ctx, Options{}, []byte("hello"))
+		assert.NoError(t, err)
+
+		assert.NoError(t, dataStore.WriteRaw(ctx, ref, 5, Options{}, bytes.NewReader([]byte("world"))))
+		raw, err := s.ReadRaw(ctx, ref)
+		assert.NoError(t, err)
+		assert.Equal(t, "world", string(raw))
+	})
+}
+
+func TestContentAddressedStore_CompositeDataStore(t *testing.T) {
+	ctx := context.TODO()
+	rawStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+
+	dataStore := NewCompositeDataStore(NewURLPathConstructor(), NewDefaultProtobufStore(rawStore, promutils.NewTestScope()))
+	s := NewContentAddressedStore(dataStore, ContentAddressedConfig{VerifyOnRead: true}, promutils.NewTestScope())
+	ref, err := s.WriteRaw(ctx, Options{}, []byte("hello"))
+	assert.NoError(t, err)
+
+	raw, err := s.ReadRaw(ctx, ref)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(raw))
+}
@@ -25,12 +25,41 @@ type copyMetrics struct {
 	ComputeLengthLatency         labeled.StopWatch
 	WriteFailureUnrelatedToCache prometheus.Counter
 	ReadFailureUnrelatedToCache  prometheus.Counter
+	ServerSideCopies             prometheus.Counter
+	StreamedCopies               prometheus.Counter
 }
 
-// CopyRaw is a naiive implementation for copy that reads all data locally then writes them to destination.
-// TODO: We should upstream an API change to stow to implement copy more natively. E.g. Use s3 copy:
-// https://docs.aws.amazon.com/AmazonS3/latest/dev/CopyingObjectUsingREST.html
+// serverSideCopier is implemented by stores that can copy some objects without streaming them through this process.
+type serverSideCopier interface {
+	// copyServerSide copies source to destination. It returns false, without an error, if the copy has to be streamed
+	// instead.
+	copyServerSide(ctx context.Context, source, destination DataReference, opts Options) (bool, error)
+}
+
+// CopyRaw copies source to destination server-side if the store supports it. Otherwise, it reads all data locally then
+// writes them to destination. CopyLatency covers either kind of copy.
 func (c copyImpl) CopyRaw(ctx context.Context, source, destination DataReference, opts Options) error {
+	t := c.metrics.CopyLatency.Start(ctx)
+	defer t.Stop()
+
+	if copier, ok := c.rawStore.(serverSideCopier); ok {
+		copied, err := copier.copyServerSide(ctx, source, destination, opts)
+		if err != nil {
+			return err
+		}
+
+		if copied {
+			c.metrics.ServerSideCopies.Inc()
+			return nil
+		}
+	}
+
+	c.metrics.StreamedCopies.Inc()
+	return c.streamingCopy(ctx, source, destination)
+}
+
+// streamingCopy is a naiive implementation for copy that reads all data locally then writes them to destination.
+func (c copyImpl) streamingCopy(ctx context.Context, source, destination DataReference) error {
 	rc, err := c.rawStore.ReadRaw(ctx, source)
 
 	if err != nil && !IsFailedWriteToCache(err) {
@@ -70,6 +99,8 @@ func newCopyMetrics(scope promutils.Scope) *copyMetrics {
 		ComputeLengthLatency:         labeled.NewStopWatch("length", "Latency involved in computing length of content before writing.", time.Millisecond, scope, labeled.EmitUnlabeledMetric),
 		WriteFailureUnrelatedToCache: scope.MustNewCounter("write_failure_unrelated_to_cache", "Raw store write failures that are not caused by ErrFailedToWriteCache"),
 		ReadFailureUnrelatedToCache:  scope.MustNewCounter("read_failure_unrelated_to_cache", "Raw store read failures that are not caused by ErrFailedToWriteCache"),
+		ServerSideCopies:             scope.MustNewCounter("server_side", "Copies performed server-side by the store"),
+		StreamedCopies:               scope.MustNewCounter("streamed", "Copies streamed through this process"),
 	}
 }
 
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const schemeSeparator = "://"
+
+// parts splits the reference into its scheme, container and path without unescaping any of them. References without
+// a scheme consist of a path only.
+func (r DataReference) parts() (scheme, container, p string) {
+	s := string(r)
+	idx := strings.Index(s, schemeSeparator)
+	if idx < 0 {
+		return "", "", s
+	}
+
+	scheme, rest := s[:idx], s[idx+len(schemeSeparator):]
+	if slash := strings.Index(rest, separator); slash >= 0 {
+		return scheme, rest[:slash], rest[slash:]
+	}
+
+	return scheme, rest, ""
+}
+
+// newDataReference assembles a reference from its parts.
+func newDataReference(scheme, container, p string) DataReference {
+	if len(scheme) == 0 && len(container) == 0 {
+		return DataReference(p)
+	}
+
+	if len(p) > 0 && !strings.HasPrefix(p, separator) {
+		p = separator + p
+	}
+
+	return DataReference(scheme + schemeSeparator + container + p)
+}
+
+// cleanPath returns the path of the reference with duplicate separators and dot segments resolved. It always starts
+// with a separator.
+func (r DataReference) cleanPath() string {
+	_, _, p := r.parts()
+	return path.Join(separator, p)
+}
+
+// Join returns the reference with keys appended to its path. Keys may contain separators. Duplicate separators and dot
+// segments are resolved and empty keys are skipped, so the resulting path never ends with a separator unless it's the
+// root of the container.
+func (r DataReference) Join(keys ...string) DataReference {
+	scheme, container, p := r.parts()
+	return newDataReference(scheme, container, path.Join(append([]string{separator, p}, keys...)...))
+}
+
+// Parent returns the reference of the directory containing the referenced object. The parent of the root of a
+// container is the root itself.
+func (r DataReference) Parent() DataReference {
+	scheme, container, _ := r.parts()
+	return newDataReference(scheme, container, path.Dir(r.cleanPath()))
+}
+
+// Base returns the last segment of the path of the reference, or an empty string if it references the root of a
+// container.
+func (r DataReference) Base() string {
+	if base := path.Base(r.cleanPath()); base != separator {
+		return base
+	}
+
+	return ""
+}
+
+// Ext returns the extension of the last segment of the path of the reference (e.g. .pb), or an empty string if it
+// doesn't have one.
+func (r DataReference) Ext() string {
+	return path.Ext(r.Base())
+}
+
+// IsPrefixOf returns true if other is the same reference as r or nested under it. Unlike strings.HasPrefix, it only
+// matches whole path segments, so s3://bucket/a isn't a prefix of s3://bucket/ab.
+func (r DataReference) IsPrefixOf(other DataReference) bool {
+	scheme, container, _ := r.parts()
+	otherScheme, otherContainer, _ := other.parts()
+	if scheme != otherScheme || container != otherContainer {
+		return false
+	}
+
+	prefix, p := r.cleanPath(), other.cleanPath()
+	return p == prefix || prefix == separator || strings.HasPrefix(p, prefix+separator)
+}
+
+// WithScheme returns the reference with its scheme replaced.
+func (r DataReference) WithScheme(scheme string) DataReference {
+	_, container, p := r.parts()
+	return newDataReference(scheme, container, p)
+}
+
+// WithContainer returns the reference with its container replaced.
+func (r DataReference) WithContainer(container string) DataReference {
+	scheme, _, p := r.parts()
+	return newDataReference(scheme, container, p)
+}
+
+// Validate returns an error if the reference isn't a valid URL with a scheme and a container, or if its path has empty
+// or dot segments. A single trailing separator is allowed. If allowedSchemes are passed, the scheme must be one of
+// them.
+func (r DataReference) Validate(allowedSchemes ...string) error {
+	u, err := url.Parse(string(r))
+	if err != nil {
+		return fmt.Errorf("reference is of an invalid format [%v]. Error: %w", r, err)
+	}
+
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("reference [%v] must have a scheme and a container", r)
+	}
+
+	if len(allowedSchemes) > 0 && !contains(allowedSchemes, u.Scheme) {
+		return fmt.Errorf("reference [%v] has a scheme that isn't one of %v", r, allowedSchemes)
+	}
+
+	if len(u.RawQuery) > 0 || len(u.Fragment) > 0 {
+		return fmt.Errorf("reference [%v] must not have a query or a fragment", r)
+	}
+
+	if key := strings.TrimSuffix(strings.TrimPrefix(u.Path, separator), separator); len(key) > 0 {
+		for _, segment := range strings.Split(key, separator) {
+			if len(segment) == 0 || segment == "." || segment == ".." {
+				return fmt.Errorf("reference [%v] has an empty or dot path segment", r)
+			}
+		}
+	}
+
+	return nil
+}
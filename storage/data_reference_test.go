@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataReference_Join(t *testing.T) {
+	for _, tc := range []struct {
+		reference DataReference
+		keys      []string
+		expected  DataReference
+	}{
+		{reference: "s3://bucket", keys: []string{"a", "b.pb"}, expected: "s3://bucket/a/b.pb"},
+		{reference: "s3://bucket/", keys: []string{"/a/", "//b"}, expected: "s3://bucket/a/b"},
+		{reference: "s3://bucket/a/", keys: []string{"", "b/c"}, expected: "s3://bucket/a/b/c"},
+		{reference: "s3://bucket/a", keys: []string{"../b"}, expected: "s3://bucket/b"},
+		{reference: "s3://bucket/a", expected: "s3://bucket/a"},
+		{reference: "s3://bucket", expected: "s3://bucket/"},
+		{reference: "hello", keys: []string{"key"}, expected: "/hello/key"},
+	} {
+		t.Run(tc.reference.String(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.reference.Join(tc.keys...))
+		})
+	}
+}
+
+func TestDataReference_Path(t *testing.T) {
+	r := DataReference("s3://bucket/a/b.pb")
+	assert.Equal(t, DataReference("s3://bucket/a"), r.Parent())
+	assert.Equal(t, DataReference("s3://bucket/"), r.Parent().Parent())
+	assert.Equal(t, DataReference("s3://bucket/"), r.Parent().Parent().Parent())
+	assert.Equal(t, "b.pb", r.Base())
+	assert.Equal(t, ".pb", r.Ext())
+	assert.Equal(t, "a", r.Parent().Base())
+	assert.Equal(t, "", r.Parent().Ext())
+	assert.Equal(t, "", DataReference("s3://bucket").Base())
+}
+
+func TestDataReference_IsPrefixOf(t *testing.T) {
+	assert.True(t, DataReference("s3://bucket/a").IsPrefixOf("s3://bucket/a/b"))
+	assert.True(t, DataReference("s3://bucket/a/").IsPrefixOf("s3://bucket/a/b"))
+	assert.True(t, DataReference("s3://bucket/a").IsPrefixOf("s3://bucket/a"))
+	assert.True(t, DataReference("s3://bucket").IsPrefixOf("s3://bucket/a"))
+	assert.False(t, DataReference("s3://bucket/a").IsPrefixOf("s3://bucket/ab"))
+	assert.False(t, DataReference("s3://bucket/a/b").IsPrefixOf("s3://bucket/a"))
+	assert.False(t, DataReference("s3://bucket").IsPrefixOf("s3://bucket2/a"))
+	assert.False(t, DataReference("s3://bucket/a").IsPrefixOf("gs://bucket/a/b"))
+}
+
+func TestDataReference_With(t *testing.T) {
+	r := DataReference("s3://bucket/a/b")
+	assert.Equal(t, DataReference("gs://bucket/a/b"), r.WithScheme("gs"))
+	assert.Equal(t, DataReference("s3://other/a/b"), r.WithContainer("other"))
+	assert.Equal(t, DataReference("s3://other"), DataReference("s3://bucket").WithContainer("other"))
+}
+
+func TestDataReference_Validate(t *testing.T) {
+	assert.NoError(t, DataReference("s3://bucket/a/b").Validate())
+	assert.NoError(t, DataReference("s3://bucket/a/").Validate())
+	assert.NoError(t, DataReference("s3://bucket").Validate("s3", "gs"))
+
+	for _, r := range []DataReference{"/a/b", "s3:///a", "s3://bucket/a//b", "s3://bucket/a/../b", "s3://bucket/a?b=c",
+		"*&^#&$@:%//"} {
+		assert.Error(t, r.Validate(), r)
+	}
+
+	assert.Error(t, DataReference("file://bucket/a").Validate("s3", "gs"))
+}
+
+func FuzzDataReference_Join(f *testing.F) {
+	f.Add("s3://bucket/a", "b")
+	f.Add("s3://bucket/", "/b/c/")
+	f.Add("hello", "..")
+	f.Add("gs://bucket//a", "b//c.pb")
+
+	f.Fuzz(func(t *testing.T, reference, key string) {
+		r := DataReference(reference)
+		joined := r.Join(key)
+		scheme, container, p := joined.parts()
+		expectedScheme, expectedContainer, _ := r.parts()
+		assert.Equal(t, expectedScheme, scheme)
+		assert.Equal(t, expectedContainer, container)
+		assert.NotContains(t, p, "//")
+		assert.Equal(t, joined, joined.Join())
+
+		segment := strings.Trim(key, separator)
+		if len(segment) == 0 || strings.Contains(segment, separator) || segment == "." || segment == ".." {
+			return
+		}
+
+		assert.True(t, r.IsPrefixOf(joined), joined)
+		assert.Equal(t, r.Join(), joined.Parent())
+		assert.Equal(t, segment, joined.Base())
+	})
+}
+
+func FuzzURLPathConstructor_ConstructReference(f *testing.F) {
+	f.Add("s3://bucket/a", "b", "c")
+	f.Add("hello", "key1", "key2/")
+	f.Add("s3://bucket", "", "")
+
+	f.Fuzz(func(t *testing.T, reference, key1, key2 string) {
+		r, err := NewURLPathConstructor().ConstructReference(context.TODO(), DataReference(reference), key1, key2)
+		// Only valid references that don't change once parsed as URLs (e.g. because they need escaping) can be
+		// compared. Keys are resolved like relative URLs, so they're resolved from the container if the first one is
+		// empty.
+		u, parseErr := url.Parse(reference)
+		if err != nil || parseErr != nil || u.String() != reference || DataReference(reference).Validate() != nil ||
+			len(strings.Trim(key1, separator)) == 0 {
+			return
+		}
+
+		// Keys that need escaping are joined escaped, and double separators within keys are kept.
+		unescaped := strings.ReplaceAll(key1+key2, "/", "")
+		if url.PathEscape(unescaped) == unescaped && !strings.Contains(key1+key2, "//") {
+			assert.Equal(t, DataReference(reference).Join(key1, key2), r.Join())
+		}
+
+		if !strings.Contains(key1+key2, "..") {
+			assert.True(t, DataReference(reference).IsPrefixOf(r), r)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/flyteorg/flytestdlib/errors"
+	"github.com/flyteorg/flytestdlib/logger"
+)
+
+const (
+	defaultDeleteConcurrency = 10
+	deletePrefixPageSize     = 1000
+)
+
+// DeleteMany removes the referenced data from the blob store concurrently and returns the references that were
+// deleted, or would be in a dry run. References that don't exist are skipped. Failures are returned as an
+// errors.ErrorCollection.
+func (ds *DataStore) DeleteMany(ctx context.Context, references []DataReference, opts DeleteOptions) (
+	[]DataReference, error) {
+	return deleteMany(ctx, ds.ComposedProtobufStore, references, opts)
+}
+
+// DeletePrefix removes all data under prefix like DeleteMany, a page of listed references at a time. Only whole path
+// segments match, so deleting s3://bucket/exec1 leaves s3://bucket/exec10 alone. The references deleted so far are
+// returned along with any error.
+func (ds *DataStore) DeletePrefix(ctx context.Context, prefix DataReference, opts DeleteOptions) (
+	[]DataReference, error) {
+	return deletePrefix(ctx, ds.ComposedProtobufStore, prefix, opts)
+}
+
+// deleteMany deletes the references through store.Delete with up to opts.Concurrency deletes in flight. The deleted
+// references are returned in the order they were passed.
+func deleteMany(ctx context.Context, store RawStore, references []DataReference, opts DeleteOptions) (
+	[]DataReference, error) {
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultDeleteConcurrency
+	}
+
+	var (
+		lock  sync.Mutex
+		wg    sync.WaitGroup
+		found = make([]bool, len(references))
+		errs  = errors.ErrorCollection{}
+	)
+
+	inFlight := make(chan struct{}, concurrency)
+	for i, reference := range references {
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func(i int, reference DataReference) {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+
+			exists, err := deleteOne(ctx, store, reference, opts.DryRun)
+			if err != nil {
+				lock.Lock()
+				defer lock.Unlock()
+				errs.Append(fmt.Errorf("failed to delete [%v]: %w", reference, err))
+			} else if !exists {
+				logger.Debugf(ctx, "Skipping [%v], it doesn't exist.", reference)
+			}
+
+			found[i] = exists
+		}(i, reference)
+	}
+
+	wg.Wait()
+	deleted := make([]DataReference, 0, len(references))
+	for i, reference := range references {
+		if found[i] {
+			deleted = append(deleted, reference)
+		}
+	}
+
+	return deleted, errs.ErrorOrDefault()
+}
+
+// deleteOne deletes the reference, or only looks it up in a dry run. It returns false if the reference doesn't exist.
+func deleteOne(ctx context.Context, store RawStore, reference DataReference, dryRun bool) (bool, error) {
+	var err error
+	if dryRun {
+		var md Metadata
+		if md, err = store.Head(ctx, reference); err == nil {
+			return md.Exists(), nil
+		}
+	} else if err = store.Delete(ctx, reference); err == nil {
+		return true, nil
+	}
+
+	if IsNotFound(err) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// deletePrefix deletes the references nested under prefix like deleteMany, one page of List results at a time. Listed
+// references that only share a partial path segment with prefix are left alone. If listing fails, the references
+// deleted so far are returned along with the error.
+func deletePrefix(ctx context.Context, store RawStore, prefix DataReference, opts DeleteOptions) (
+	[]DataReference, error) {
+
+	var deleted []DataReference
+	cursor := NewCursorAtStart()
+	for !IsCursorEnd(cursor) {
+		page, next, err := store.List(ctx, prefix, cursor, deletePrefixPageSize)
+		if err != nil {
+			return deleted, err
+		}
+
+		references := make([]DataReference, 0, len(page))
+		for _, reference := range page {
+			if prefix.IsPrefixOf(reference) {
+				references = append(references, reference)
+			}
+		}
+
+		pageDeleted, err := deleteMany(ctx, store, references, opts)
+		deleted = append(deleted, pageDeleted...)
+		if err != nil {
+			return deleted, err
+		}
+
+		cursor = next
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/flyteorg/stow"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flyteorg/flytestdlib/contextutils"
+	"github.com/flyteorg/flytestdlib/errors"
+	"github.com/flyteorg/flytestdlib/promutils"
+	"github.com/flyteorg/flytestdlib/promutils/labeled"
+)
+
+func sortedReferences(references []DataReference) []DataReference {
+	sort.Slice(references, func(i, j int) bool {
+		return references[i] < references[j]
+	})
+
+	return references
+}
+
+// pagingStore lists a single reference per page and fails the failAt-th List call, if set.
+type pagingStore struct {
+	RawStore
+	lists  int
+	failAt int
+}
+
+func (s *pagingStore) List(ctx context.Context, prefix DataReference, cursor Cursor, _ int) ([]DataReference, Cursor, error) {
+	s.lists++
+	if s.lists == s.failAt {
+		return nil, cursor, fmt.Errorf("failed")
+	}
+
+	return s.RawStore.List(ctx, prefix, cursor, 1)
+}
+
+func TestDataStore_Delete(t *testing.T) {
+	ctx := context.TODO()
+	newStore := func(t *testing.T, references ...DataReference) *DataStore {
+		store, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+		assert.NoError(t, err)
+		for _, reference := range references {
+			assert.NoError(t, store.WriteRaw(ctx, reference, 1, Options{}, bytes.NewReader([]byte("a"))))
+		}
+
+		return store
+	}
+
+	t.Run("DeleteMany", func(t *testing.T) {
+		store := newStore(t, "mem://container/a", "mem://container/b", "mem://container/c")
+		references := []DataReference{"mem://container/a", "mem://container/b", "mem://container/missing"}
+		deleted, err := store.DeleteMany(ctx, references, DeleteOptions{Concurrency: 2, DryRun: true})
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/a", "mem://container/b"}, sortedReferences(deleted))
+		md, err := store.Head(ctx, "mem://container/a")
+		assert.NoError(t, err)
+		assert.True(t, md.Exists())
+
+		deleted, err = store.DeleteMany(ctx, references, DeleteOptions{Concurrency: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/a", "mem://container/b"}, sortedReferences(deleted))
+
+		refs, _, err := store.List(ctx, "mem://container", NewCursorAtStart(), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/c"}, refs)
+	})
+
+	t.Run("DeletePrefix", func(t *testing.T) {
+		store := newStore(t, "mem://container/dir/a", "mem://container/dir/b", "mem://container/other")
+		deleted, err := store.DeletePrefix(ctx, "mem://container/dir", DeleteOptions{DryRun: true})
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/dir/a", "mem://container/dir/b"}, sortedReferences(deleted))
+		md, err := store.Head(ctx, "mem://container/dir/a")
+		assert.NoError(t, err)
+		assert.True(t, md.Exists())
+
+		deleted, err = store.DeletePrefix(ctx, "mem://container/dir", DeleteOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/dir/a", "mem://container/dir/b"}, sortedReferences(deleted))
+		refs, _, err := store.List(ctx, "mem://container", NewCursorAtStart(), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/other"}, refs)
+	})
+
+	t.Run("DeletePrefix keeps siblings", func(t *testing.T) {
+		store := newStore(t, "mem://container/exec1/a", "mem://container/exec10/a", "mem://container/exec1.pb")
+		for _, prefix := range []DataReference{"mem://container/exec1", "mem://container/exec1/"} {
+			deleted, err := store.DeletePrefix(ctx, prefix, DeleteOptions{DryRun: true})
+			assert.NoError(t, err)
+			assert.Equal(t, []DataReference{"mem://container/exec1/a"}, deleted)
+		}
+
+		deleted, err := store.DeletePrefix(ctx, "mem://container/exec1", DeleteOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/exec1/a"}, deleted)
+		refs, _, err := store.List(ctx, "mem://container", NewCursorAtStart(), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/exec1.pb", "mem://container/exec10/a"}, refs)
+	})
+
+	t.Run("DeletePrefix deletes page by page", func(t *testing.T) {
+		memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+		assert.NoError(t, err)
+		for _, reference := range []DataReference{"mem://container/exec/a", "mem://container/exec/b",
+			"mem://container/exec/c"} {
+			assert.NoError(t, memStore.WriteRaw(ctx, reference, 1, Options{}, bytes.NewReader([]byte("a"))))
+		}
+
+		store := &pagingStore{RawStore: memStore, failAt: 3}
+		deleted, err := deletePrefix(ctx, store, "mem://container/exec", DeleteOptions{})
+		assert.Error(t, err)
+		assert.Equal(t, []DataReference{"mem://container/exec/a", "mem://container/exec/b"}, deleted)
+		refs, _, err := memStore.List(ctx, "mem://container", NewCursorAtStart(), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/exec/c"}, refs)
+
+		store.failAt = 0
+		deleted, err = deletePrefix(ctx, store, "mem://container/exec", DeleteOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"mem://container/exec/c"}, deleted)
+	})
+
+	t.Run("Collects errors", func(t *testing.T) {
+		store := &dummyStore{
+			DeleteCb: func(ctx context.Context, reference DataReference) error {
+				if reference == "b" || reference == "c" {
+					return fmt.Errorf("failed")
+				}
+
+				return nil
+			},
+		}
+
+		deleted, err := deleteMany(ctx, store, []DataReference{"a", "b", "c"}, DeleteOptions{})
+		assert.Equal(t, []DataReference{"a"}, deleted)
+		assert.IsType(t, errors.ErrorCollection{}, err)
+		assert.Len(t, err.(errors.ErrorCollection), 2)
+	})
+}
+
+func TestStowStore_DeletePrefix(t *testing.T) {
+	labeled.SetMetricKeys(contextutils.ProjectKey, contextutils.DomainKey, contextutils.WorkflowIDKey, contextutils.TaskIDKey)
+	mockContainer := newMockStowContainer("container")
+	for _, id := range []string{"dir/a", "dir/b", "dir10/a", "other"} {
+		mockContainer.items[id] = mockStowItem{url: id}
+	}
+
+	s, err := NewStowRawStore(fQNFn["s3"]("container"), &mockStowLoc{
+		ContainerCb: func(id string) (stow.Container, error) {
+			return mockContainer, nil
+		},
+	}, nil, false, metrics)
+	assert.NoError(t, err)
+
+	deleted, err := deletePrefix(context.TODO(), s, "s3://container/dir", DeleteOptions{Concurrency: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []DataReference{"s3://container/dir/a", "s3://container/dir/b"}, deleted)
+	assert.Len(t, mockContainer.items, 2)
+}
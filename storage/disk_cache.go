@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flyteorg/flytestdlib/ioutils"
+	"github.com/flyteorg/flytestdlib/logger"
+)
+
+const diskCacheTempFilePrefix = "tmp-"
+
+type diskCacheEntry struct {
+	key       string
+	size      int64
+	createdAt time.Time
+}
+
+// diskCache is a size bounded LRU cache of files in a local directory. The index is rebuilt from the directory on
+// startup so cached entries survive restarts, ordered by the time they were written.
+type diskCache struct {
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+	metrics  *cacheMetrics
+
+	lock      sync.Mutex
+	entries   map[string]*list.Element
+	lru       *list.List
+	sizeBytes int64
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+// get returns the cached data for key if it exists and hasn't expired.
+func (c *diskCache) get(key string) ([]byte, bool) {
+	c.lock.Lock()
+	elem, found := c.entries[key]
+	if found {
+		entry := elem.Value.(*diskCacheEntry)
+		if c.ttl > 0 && time.Since(entry.createdAt) > c.ttl {
+			c.removeElement(elem)
+			found = false
+		} else {
+			c.lru.MoveToFront(elem)
+		}
+	}
+	c.lock.Unlock()
+
+	if !found {
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		// The entry may have been evicted concurrently.
+		return nil, false
+	}
+
+	return data, true
+}
+
+// set writes data under key, evicting the least recently used entries to stay under the size limit. Data larger than
+// the whole cache isn't cached.
+func (c *diskCache) set(key string, data []byte) error {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return fmt.Errorf("data of size [%v] exceeds disk cache size [%v]", size, c.maxBytes)
+	}
+
+	f, err := ioutil.TempFile(c.dir, diskCacheTempFilePrefix)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err = os.Rename(f.Name(), c.path(key)); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if elem, found := c.entries[key]; found {
+		c.sizeBytes -= elem.Value.(*diskCacheEntry).size
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+
+	c.add(&diskCacheEntry{key: key, size: size, createdAt: time.Now()})
+	return nil
+}
+
+// add indexes entry as the most recently used one and evicts entries until the cache fits. It must be called with the
+// lock held.
+func (c *diskCache) add(entry *diskCacheEntry) {
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.sizeBytes += entry.size
+	for c.sizeBytes > c.maxBytes {
+		c.removeElement(c.lru.Back())
+		c.metrics.DiskCacheEvict.Inc()
+	}
+}
+
+// removeElement deletes the entry and its file. It must be called with the lock held.
+func (c *diskCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*diskCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.sizeBytes -= entry.size
+	if err := os.Remove(c.path(entry.key)); err != nil && !os.IsNotExist(err) {
+		logger.Warnf(context.TODO(), "Failed to remove disk cache entry [%v]. Error: %v", entry.key, err)
+	}
+}
+
+// load indexes the files already present in the cache directory. Leftover temporary files are removed.
+func (c *diskCache) load() error {
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		if strings.HasPrefix(f.Name(), diskCacheTempFilePrefix) {
+			_ = os.Remove(c.path(f.Name()))
+			continue
+		}
+
+		c.add(&diskCacheEntry{key: f.Name(), size: f.Size(), createdAt: f.ModTime()})
+	}
+
+	return nil
+}
+
+func newDiskCache(cfg DiskCacheConfig, metrics *cacheMetrics) (*diskCache, error) {
+	if err := os.MkdirAll(cfg.Dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create disk cache dir [%v]. Error: %v", cfg.Dir, err)
+	}
+
+	c := &diskCache{
+		dir:      cfg.Dir,
+		maxBytes: cfg.MaxSizeBytes,
+		ttl:      cfg.TTL.Duration,
+		metrics:  metrics,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+
+	return c, c.load()
+}
+
+// diskCachedRawStore caches objects read from the underlying store on local disk. Entries are keyed by the reference
+// and its Etag so objects changed in the underlying store are never served from the cache, at the cost of a Head
+// request per read, and another one per cache miss. Objects without an Etag aren't cached.
+type diskCachedRawStore struct {
+	RawStore
+	cache   *diskCache
+	metrics *cacheMetrics
+}
+
+func diskCacheKey(reference DataReference, etag string) string {
+	hash := sha256.Sum256([]byte(string(reference) + "\n" + etag))
+	return hex.EncodeToString(hash[:])
+}
+
+// cacheKey returns the disk cache key of the current version of the referenced object, or false if it can't be cached.
+func (s *diskCachedRawStore) cacheKey(ctx context.Context, reference DataReference) (string, bool) {
+	md, err := s.RawStore.Head(ctx, reference)
+	if err != nil || !md.Exists() || len(md.Etag()) == 0 {
+		return "", false
+	}
+
+	return diskCacheKey(reference, md.Etag()), true
+}
+
+// ReadRaw serves the current version of the object from disk if cached. Otherwise, it reads the object from the
+// underlying store and caches it.
+func (s *diskCachedRawStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	key, cacheable := s.cacheKey(ctx, reference)
+	if !cacheable {
+		return s.RawStore.ReadRaw(ctx, reference)
+	}
+
+	if data, found := s.cache.get(key); found {
+		s.metrics.DiskCacheHit.Inc()
+		return ioutils.NewBytesReadCloser(data), nil
+	}
+
+	s.metrics.DiskCacheMiss.Inc()
+	if skipsDownloadLimit(ctx) {
+		// The caller streams the object, which may be larger than the download limit, so it isn't buffered to be cached.
+		return s.RawStore.ReadRaw(ctx, reference)
+	}
+
+	reader, err := s.RawStore.ReadRaw(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := reader.Close(); err != nil {
+			logger.Warnf(ctx, "Failed to close reader [%v]. Error: %v", reference, err)
+		}
+	}()
+
+	data, err := ioutils.ReadAll(reader, s.metrics.FetchLatency.Start())
+	if err != nil {
+		return nil, err
+	}
+
+	// The data may belong to a newer version of the object if it was overwritten after the Head, so it's only cached
+	// under the Etag of the version that's still current once it's read.
+	if current, cacheable := s.cacheKey(ctx, reference); !cacheable || current != key {
+		logger.Debugf(ctx, "Skipping caching [%v], it changed while it was read.", reference)
+		return ioutils.NewBytesReadCloser(data), nil
+	}
+
+	if err = s.cache.set(key, data); err != nil {
+		s.metrics.DiskCacheWriteError.Inc()
+		logger.Debugf(ctx, "Failed to write [%v] to the disk cache. Error: %v", reference, err)
+	}
+
+	return ioutils.NewBytesReadCloser(data), nil
+}
+
+// ReadRawRange serves the range from disk if the current version of the object is cached. Otherwise, it reads the
+// range from the underlying store without caching it.
+func (s *diskCachedRawStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	if key, cacheable := s.cacheKey(ctx, reference); cacheable {
+		if data, found := s.cache.get(key); found {
+			s.metrics.DiskCacheHit.Inc()
+			start, end, err := rangeBounds(int64(len(data)), offset, length)
+			if err != nil {
+				return nil, err
+			}
+
+			return ioutils.NewBytesReadCloser(data[start:end]), nil
+		}
+
+		s.metrics.DiskCacheMiss.Inc()
+	}
+
+	return s.RawStore.ReadRawRange(ctx, reference, offset, length)
+}
+
+// newDiskCachedRawStore creates a diskCachedRawStore if the disk cache is configured, otherwise returns store.
+func newDiskCachedRawStore(cfg *Config, store RawStore, metrics *cacheMetrics) (RawStore, error) {
+	if len(cfg.Cache.Disk.Dir) == 0 || cfg.Cache.Disk.MaxSizeBytes <= 0 {
+		return store, nil
+	}
+
+	cache, err := newDiskCache(cfg.Cache.Disk, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	return &diskCachedRawStore{
+		RawStore: store,
+		cache:    cache,
+		metrics:  metrics,
+	}, nil
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flyteorg/flytestdlib/config"
+)
+
+// readCountingStore counts full-object reads that reach the underlying store.
+type readCountingStore struct {
+	RawStore
+	reads int
+}
+
+func (s *readCountingStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	s.reads++
+	return s.RawStore.ReadRaw(ctx, reference)
+}
+
+func readString(t *testing.T, store RawStore, reference DataReference) string {
+	rc, err := store.ReadRaw(context.TODO(), reference)
+	assert.NoError(t, err)
+	raw, err := ioutil.ReadAll(rc)
+	assert.NoError(t, err)
+	return string(raw)
+}
+
+func TestNewDiskCachedRawStore(t *testing.T) {
+	memStore, err := NewInMemoryRawStore(context.TODO(), &Config{}, metrics)
+	assert.NoError(t, err)
+
+	store, err := newDiskCachedRawStore(&Config{}, memStore, metrics.cacheMetrics)
+	assert.NoError(t, err)
+	assert.Equal(t, memStore, store)
+
+	store, err = newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{Dir: t.TempDir()}}}, memStore, metrics.cacheMetrics)
+	assert.NoError(t, err)
+	assert.Equal(t, memStore, store)
+}
+
+func TestDiskCachedRawStore(t *testing.T) {
+	ctx := context.TODO()
+	write := func(t *testing.T, store RawStore, reference DataReference, data string) {
+		assert.NoError(t, store.WriteRaw(ctx, reference, int64(len(data)), Options{}, bytes.NewReader([]byte(data))))
+	}
+
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+
+	t.Run("Read through", func(t *testing.T) {
+		underlying := &readCountingStore{RawStore: memStore}
+		store, err := newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{
+			Dir:          t.TempDir(),
+			MaxSizeBytes: 1024,
+		}}}, underlying, metrics.cacheMetrics)
+		assert.NoError(t, err)
+		write(t, store, "mem://container/a", "hello")
+
+		assert.Equal(t, "hello", readString(t, store, "mem://container/a"))
+		assert.Equal(t, "hello", readString(t, store, "mem://container/a"))
+		assert.Equal(t, 1, underlying.reads)
+
+		rc, err := store.ReadRawRange(ctx, "mem://container/a", 1, 3)
+		assert.NoError(t, err)
+		raw, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, "ell", string(raw))
+	})
+
+	t.Run("Streams bypass the cache", func(t *testing.T) {
+		underlying := &readCountingStore{RawStore: memStore}
+		store, err := newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{
+			Dir:          t.TempDir(),
+			MaxSizeBytes: 1024,
+		}}}, underlying, metrics.cacheMetrics)
+		assert.NoError(t, err)
+		write(t, store, "mem://container/a", "hello")
+
+		rc, err := store.ReadRaw(withoutDownloadLimit(ctx), "mem://container/a")
+		assert.NoError(t, err)
+		raw, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.NoError(t, rc.Close())
+		assert.Equal(t, "hello", string(raw))
+		assert.Equal(t, int64(0), store.(*diskCachedRawStore).cache.sizeBytes)
+	})
+
+	t.Run("Invalidated by Etag", func(t *testing.T) {
+		underlying := &readCountingStore{RawStore: memStore}
+		store, err := newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{
+			Dir:          t.TempDir(),
+			MaxSizeBytes: 1024,
+		}}}, underlying, metrics.cacheMetrics)
+		assert.NoError(t, err)
+		write(t, store, "mem://container/a", "hello")
+		assert.Equal(t, "hello", readString(t, store, "mem://container/a"))
+
+		write(t, underlying, "mem://container/a", "world")
+		assert.Equal(t, "world", readString(t, store, "mem://container/a"))
+		assert.Equal(t, 2, underlying.reads)
+	})
+
+	t.Run("Skips objects overwritten while read", func(t *testing.T) {
+		write(t, memStore, "mem://container/a", "hello")
+		store, err := newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{
+			Dir:          t.TempDir(),
+			MaxSizeBytes: 1024,
+		}}}, &overwritingStore{
+			RawStore: memStore,
+			overwrite: func() {
+				write(t, memStore, "mem://container/a", "world")
+			},
+		}, metrics.cacheMetrics)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "world", readString(t, store, "mem://container/a"))
+		assert.Equal(t, int64(0), store.(*diskCachedRawStore).cache.sizeBytes)
+	})
+
+	t.Run("Evicts least recently used", func(t *testing.T) {
+		underlying := &readCountingStore{RawStore: memStore}
+		store, err := newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{
+			Dir:          t.TempDir(),
+			MaxSizeBytes: 10,
+		}}}, underlying, metrics.cacheMetrics)
+		assert.NoError(t, err)
+		write(t, store, "mem://container/a", "aaaaa")
+		write(t, store, "mem://container/b", "bbbbb")
+		write(t, store, "mem://container/c", "ccccc")
+
+		readString(t, store, "mem://container/a")
+		readString(t, store, "mem://container/b")
+		readString(t, store, "mem://container/a")
+		readString(t, store, "mem://container/c")
+		assert.Equal(t, 3, underlying.reads)
+		assert.Equal(t, int64(10), store.(*diskCachedRawStore).cache.sizeBytes)
+
+		readString(t, store, "mem://container/a")
+		assert.Equal(t, 3, underlying.reads)
+		readString(t, store, "mem://container/b")
+		assert.Equal(t, 4, underlying.reads)
+	})
+
+	t.Run("Skips objects larger than the cache", func(t *testing.T) {
+		underlying := &readCountingStore{RawStore: memStore}
+		store, err := newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{
+			Dir:          t.TempDir(),
+			MaxSizeBytes: 3,
+		}}}, underlying, metrics.cacheMetrics)
+		assert.NoError(t, err)
+		write(t, store, "mem://container/a", "hello")
+		assert.Equal(t, "hello", readString(t, store, "mem://container/a"))
+		assert.Equal(t, "hello", readString(t, store, "mem://container/a"))
+		assert.Equal(t, 2, underlying.reads)
+	})
+
+	t.Run("Expires entries", func(t *testing.T) {
+		underlying := &readCountingStore{RawStore: memStore}
+		store, err := newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{
+			Dir:          t.TempDir(),
+			MaxSizeBytes: 1024,
+			TTL:          config.Duration{Duration: time.Millisecond},
+		}}}, underlying, metrics.cacheMetrics)
+		assert.NoError(t, err)
+		write(t, store, "mem://container/a", "hello")
+		readString(t, store, "mem://container/a")
+		time.Sleep(5 * time.Millisecond)
+		readString(t, store, "mem://container/a")
+		assert.Equal(t, 2, underlying.reads)
+	})
+
+	t.Run("Survives restarts", func(t *testing.T) {
+		dir := t.TempDir()
+		underlying := &readCountingStore{RawStore: memStore}
+		store, err := newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{
+			Dir:          dir,
+			MaxSizeBytes: 1024,
+		}}}, underlying, metrics.cacheMetrics)
+		assert.NoError(t, err)
+		write(t, store, "mem://container/a", "hello")
+		readString(t, store, "mem://container/a")
+
+		store, err = newDiskCachedRawStore(&Config{Cache: CachingConfig{Disk: DiskCacheConfig{
+			Dir:          dir,
+			MaxSizeBytes: 1024,
+		}}}, underlying, metrics.cacheMetrics)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), store.(*diskCachedRawStore).cache.sizeBytes)
+		assert.Equal(t, "hello", readString(t, store, "mem://container/a"))
+		assert.Equal(t, 1, underlying.reads)
+	})
+}
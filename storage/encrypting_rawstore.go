@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/flyteorg/flytestdlib/errors"
+	"github.com/flyteorg/flytestdlib/ioutils"
+	"github.com/flyteorg/flytestdlib/logger"
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+const (
+	// MetadataKeyEncryptionKeyID is the object metadata key that records the id of the key encryption key.
+	MetadataKeyEncryptionKeyID = "flyte-encryption-key-id"
+	// MetadataKeyEncryptionNonce is the object metadata key that records the (base64 encoded) nonce used to encrypt
+	// the object.
+	MetadataKeyEncryptionNonce = "flyte-encryption-nonce"
+)
+
+// envelopeMagic prefixes every encrypted object so that the envelope can be parsed without relying on object metadata,
+// which isn't preserved by all operations (e.g. CopyRaw).
+var envelopeMagic = []byte("FLYTEENC")
+
+const envelopeVersion byte = 1
+
+type encryptionMetrics struct {
+	EncryptFailure prometheus.Counter
+	DecryptFailure prometheus.Counter
+}
+
+// encryptingRawStore encrypts objects on write and decrypts them on read using envelope encryption: every object is
+// encrypted with its own AES-256-GCM data key, which is in turn wrapped by the KeyProvider and stored alongside the
+// ciphertext.
+// Objects are encrypted and decrypted in memory, the download limits of the underlying store apply to the encrypted
+// objects.
+type encryptingRawStore struct {
+	RawStore
+	keyProvider KeyProvider
+	metrics     *encryptionMetrics
+}
+
+// ReadRaw retrieves and decrypts the referenced object.
+func (s *encryptingRawStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	plaintext, err := s.readAndDecrypt(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutils.NewBytesReadCloser(plaintext), nil
+}
+
+// ReadRawRange retrieves and decrypts the entire referenced object then returns the requested range, since AES-GCM
+// ciphertexts can only be authenticated as a whole.
+func (s *encryptingRawStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	plaintext, err := s.readAndDecrypt(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := rangeBounds(int64(len(plaintext)), offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutils.NewBytesReadCloser(plaintext[start:end]), nil
+}
+
+// WriteRaw encrypts and stores the raw bytes. The key id and nonce are recorded in the object metadata.
+func (s *encryptingRawStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	plaintext, err := ioutil.ReadAll(raw)
+	if err != nil {
+		return err
+	}
+
+	envelope, keyID, nonce, err := s.encrypt(ctx, plaintext)
+	if err != nil {
+		s.metrics.EncryptFailure.Inc()
+		return errors.Wrapf(ErrFailedToEncrypt, err, "failed to encrypt data for [%v]", reference)
+	}
+
+	metadata := make(map[string]interface{}, len(opts.Metadata)+2)
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+
+	metadata[MetadataKeyEncryptionKeyID] = keyID
+	metadata[MetadataKeyEncryptionNonce] = base64.StdEncoding.EncodeToString(nonce)
+	opts.Metadata = metadata
+	return s.RawStore.WriteRaw(ctx, reference, int64(len(envelope)), opts, bytes.NewReader(envelope))
+}
+
+// OpenWriter buffers the written data in memory and encrypts it once the writer is closed.
+func (s *encryptingRawStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	return &bufferedWriter{
+		close: func(raw []byte) error {
+			return s.WriteRaw(ctx, reference, int64(len(raw)), opts, bytes.NewReader(raw))
+		},
+	}, nil
+}
+
+func (s *encryptingRawStore) readAndDecrypt(ctx context.Context, reference DataReference) ([]byte, error) {
+	// The whole object is buffered to be authenticated, even if the caller streams the plaintext.
+	rc, err := s.RawStore.ReadRaw(withDownloadLimit(ctx), reference)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := rc.Close(); err != nil {
+			logger.Warnf(ctx, "Failed to close reader [%v]. Error: %v", reference, err)
+		}
+	}()
+
+	envelope, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := s.decrypt(ctx, envelope)
+	if err != nil {
+		s.metrics.DecryptFailure.Inc()
+		return nil, errors.Wrapf(ErrFailedToDecrypt, err, "failed to decrypt data for [%v]", reference)
+	}
+
+	return plaintext, nil
+}
+
+// encrypt seals plaintext with a new data key and returns the envelope:
+// magic | version | len(keyID) | keyID | len(wrappedKey) | wrappedKey | nonce | ciphertext
+func (s *encryptingRawStore) encrypt(ctx context.Context, plaintext []byte) (envelope []byte, keyID string, nonce []byte, err error) {
+	dataKey := make([]byte, aes256KeySize)
+	if _, err = io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, "", nil, err
+	}
+
+	keyID, wrappedKey, err := s.keyProvider.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	aead, err := newAESGCM(dataKey)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, "", nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(envelopeMagic)
+	buf.WriteByte(envelopeVersion)
+	writeLengthPrefixed(&buf, []byte(keyID))
+	writeLengthPrefixed(&buf, wrappedKey)
+	buf.Write(nonce)
+	header := buf.Bytes()
+	return aead.Seal(header, nonce, plaintext, header), keyID, nonce, nil
+}
+
+func (s *encryptingRawStore) decrypt(ctx context.Context, envelope []byte) ([]byte, error) {
+	r := bytes.NewReader(envelope)
+	magic := make([]byte, len(envelopeMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, envelopeMagic) {
+		return nil, fmt.Errorf("data is not encrypted")
+	}
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	} else if version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version [%v]", version)
+	}
+
+	keyID, err := readLengthPrefixed(r)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := readLengthPrefixed(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := s.keyProvider.UnwrapKey(ctx, string(keyID), wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAESGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(r, nonce); err != nil {
+		return nil, err
+	}
+
+	headerSize := len(envelope) - r.Len()
+	return aead.Open(nil, nonce, envelope[headerSize:], envelope[:headerSize])
+}
+
+func writeLengthPrefixed(buf *bytes.Buffer, data []byte) {
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(data)))
+	buf.Write(length[:])
+	buf.Write(data)
+}
+
+func readLengthPrefixed(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// bufferedWriter is an io.WriteCloser that accumulates all written bytes in memory and hands them over once closed.
+type bufferedWriter struct {
+	buf    bytes.Buffer
+	close  func(raw []byte) error
+	closed bool
+}
+
+func (w *bufferedWriter) Write(p []byte) (n int, err error) {
+	if w.closed {
+		return 0, fmt.Errorf("write to a closed writer")
+	}
+
+	return w.buf.Write(p)
+}
+
+func (w *bufferedWriter) Close() error {
+	if w.closed {
+		return fmt.Errorf("writer is already closed")
+	}
+
+	w.closed = true
+	return w.close(w.buf.Bytes())
+}
+
+func newEncryptionMetrics(scope promutils.Scope) *encryptionMetrics {
+	return &encryptionMetrics{
+		EncryptFailure: scope.MustNewCounter("encrypt_failure", "Failures when encrypting data before writing"),
+		DecryptFailure: scope.MustNewCounter("decrypt_failure", "Failures when decrypting data after reading"),
+	}
+}
+
+// Creates an encryptingRawStore if encryption is enabled, otherwise returns the RawStore unchanged.
+func newEncryptingRawStore(ctx context.Context, cfg *Config, store RawStore, metrics *encryptionMetrics) (RawStore, error) {
+	if !cfg.Encryption.Enabled {
+		return store, nil
+	}
+
+	fn, found := keyProviders[cfg.Encryption.KeyProvider]
+	if !found {
+		return nil, fmt.Errorf("unknown key provider [%v]", cfg.Encryption.KeyProvider)
+	}
+
+	keyProvider, err := fn(ctx, cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptingRawStore{
+		RawStore:    store,
+		keyProvider: keyProvider,
+		metrics:     metrics,
+	}, nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// metadataRecordingStore records the options of the last write to the underlying store.
+type metadataRecordingStore struct {
+	RawStore
+	lastOpts Options
+}
+
+func (s *metadataRecordingStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	s.lastOpts = opts
+	return s.RawStore.WriteRaw(ctx, reference, size, opts, raw)
+}
+
+func TestNewEncryptingRawStore(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		memStore, err := NewInMemoryRawStore(context.TODO(), &Config{}, metrics)
+		assert.NoError(t, err)
+		store, err := newEncryptingRawStore(context.TODO(), &Config{}, memStore, metrics.encryptionMetrics)
+		assert.NoError(t, err)
+		assert.Equal(t, memStore, store)
+	})
+
+	t.Run("Unknown key provider", func(t *testing.T) {
+		_, err := newEncryptingRawStore(context.TODO(), &Config{
+			Encryption: EncryptionConfig{Enabled: true, KeyProvider: "unknown"},
+		}, nil, metrics.encryptionMetrics)
+		assert.Error(t, err)
+	})
+}
+
+func TestEncryptingRawStore(t *testing.T) {
+	ctx := context.TODO()
+	data := []byte("sensitive inputs")
+	t.Setenv("STDLIB_TEST_KEY", testEncryptionKey)
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+
+	underlying := &metadataRecordingStore{RawStore: memStore}
+	store, err := newEncryptingRawStore(ctx, &Config{
+		Encryption: EncryptionConfig{
+			Enabled:     true,
+			KeyProvider: KeyProviderEnv,
+			KeyID:       "key1",
+			KeyEnvVar:   "STDLIB_TEST_KEY",
+		},
+	}, underlying, metrics.encryptionMetrics)
+	assert.NoError(t, err)
+
+	t.Run("Round trip", func(t *testing.T) {
+		assert.NoError(t, store.WriteRaw(ctx, "s3://container/a", int64(len(data)), Options{
+			Metadata: map[string]interface{}{"owner": "me"},
+		}, bytes.NewReader(data)))
+
+		assert.Equal(t, "key1", underlying.lastOpts.Metadata[MetadataKeyEncryptionKeyID])
+		assert.NotEmpty(t, underlying.lastOpts.Metadata[MetadataKeyEncryptionNonce])
+		assert.Equal(t, "me", underlying.lastOpts.Metadata["owner"])
+
+		rc, err := underlying.ReadRaw(ctx, "s3://container/a")
+		assert.NoError(t, err)
+		ciphertext, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(ciphertext), string(data))
+
+		rc, err = store.ReadRaw(ctx, "s3://container/a")
+		assert.NoError(t, err)
+		plaintext, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, data, plaintext)
+
+		rc, err = store.ReadRawRange(ctx, "s3://container/a", 10, 6)
+		assert.NoError(t, err)
+		plaintext, err = ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, "inputs", string(plaintext))
+	})
+
+	t.Run("Copy stays decryptable", func(t *testing.T) {
+		assert.NoError(t, store.WriteRaw(ctx, "s3://container/a", int64(len(data)), Options{}, bytes.NewReader(data)))
+		assert.NoError(t, store.CopyRaw(ctx, "s3://container/a", "s3://container/b", Options{}))
+
+		rc, err := store.ReadRaw(ctx, "s3://container/b")
+		assert.NoError(t, err)
+		plaintext, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, data, plaintext)
+	})
+
+	t.Run("Writer", func(t *testing.T) {
+		w, err := store.OpenWriter(ctx, "s3://container/a", Options{})
+		assert.NoError(t, err)
+		_, err = w.Write(data)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		rc, err := store.ReadRaw(ctx, "s3://container/a")
+		assert.NoError(t, err)
+		plaintext, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, data, plaintext)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		assert.NoError(t, store.WriteRaw(ctx, "s3://container/a", int64(len(data)), Options{}, bytes.NewReader(data)))
+
+		rc, err := underlying.ReadRaw(ctx, "s3://container/a")
+		assert.NoError(t, err)
+		ciphertext, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		ciphertext[len(ciphertext)-1] ^= 0xff
+		assert.NoError(t, underlying.WriteRaw(ctx, "s3://container/a", int64(len(ciphertext)), Options{}, bytes.NewReader(ciphertext)))
+
+		_, err = store.ReadRaw(ctx, "s3://container/a")
+		assert.Error(t, err)
+		assert.True(t, IsFailedToDecrypt(err))
+	})
+
+	t.Run("Not encrypted", func(t *testing.T) {
+		assert.NoError(t, underlying.WriteRaw(ctx, "s3://container/a", int64(len(data)), Options{}, bytes.NewReader(data)))
+
+		_, err := store.ReadRaw(ctx, "s3://container/a")
+		assert.True(t, IsFailedToDecrypt(err))
+	})
+
+	t.Run("Key rotation", func(t *testing.T) {
+		assert.NoError(t, store.WriteRaw(ctx, "s3://container/a", int64(len(data)), Options{}, bytes.NewReader(data)))
+
+		t.Setenv("STDLIB_TEST_NEW_KEY", base64.StdEncoding.EncodeToString([]byte("abcdef0123456789abcdef0123456789")))
+		rotated, err := newEncryptingRawStore(ctx, &Config{
+			Encryption: EncryptionConfig{
+				Enabled:            true,
+				KeyProvider:        KeyProviderEnv,
+				KeyID:              "key2",
+				KeyEnvVar:          "STDLIB_TEST_NEW_KEY",
+				PreviousKeyEnvVars: map[string]string{"key1": "STDLIB_TEST_KEY"},
+			},
+		}, underlying, metrics.encryptionMetrics)
+		assert.NoError(t, err)
+
+		rc, err := rotated.ReadRaw(ctx, "s3://container/a")
+		assert.NoError(t, err)
+		plaintext, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, data, plaintext)
+
+		assert.NoError(t, rotated.WriteRaw(ctx, "s3://container/b", int64(len(data)), Options{}, bytes.NewReader(data)))
+		assert.Equal(t, "key2", underlying.lastOpts.Metadata[MetadataKeyEncryptionKeyID])
+		_, err = store.ReadRaw(ctx, "s3://container/b")
+		assert.True(t, IsFailedToDecrypt(err))
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := store.ReadRaw(ctx, "s3://container/missing")
+		assert.True(t, IsNotFound(err))
+	})
+}
@@ -0,0 +1,324 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/flyteorg/flytestdlib/contextutils"
+	stdErrs "github.com/flyteorg/flytestdlib/errors"
+	"github.com/flyteorg/flytestdlib/promutils"
+	"github.com/flyteorg/flytestdlib/promutils/labeled"
+)
+
+const (
+	FaultLabel contextutils.Key = "fault"
+)
+
+const (
+	faultError       = "error"
+	faultNotFound    = "not_found"
+	faultPartialRead = "partial_read"
+	faultLatency     = "latency"
+)
+
+func init() {
+	// Registered here rather than in the stores map since creating the underlying backend looks it up in that map.
+	stores[TypeFaulty] = newFaultyRawStore
+}
+
+type faultMetrics struct {
+	Injected labeled.Counter
+}
+
+// FaultInjectingRawStore injects errors, latency, partial reads and Not Found responses into the operations of the
+// underlying store according to a list of rules. Faults are drawn from a seeded source so that a sequence of
+// operations made by a single goroutine fails the same way on every run.
+type FaultInjectingRawStore struct {
+	RawStore
+	rules   []FaultRule
+	metrics *faultMetrics
+
+	// lock guards random, which isn't safe for concurrent use.
+	lock   sync.Mutex
+	random *rand.Rand
+}
+
+// rule returns the first rule matching the operation on reference, or nil if none does.
+func (s *FaultInjectingRawStore) rule(operation string, reference DataReference) *FaultRule {
+	for i, rule := range s.rules {
+		if len(rule.Operations) > 0 && !contains(rule.Operations, operation) {
+			continue
+		}
+
+		if rule.References != "" {
+			if matched, _ := path.Match(rule.References, reference.String()); !matched {
+				continue
+			}
+		}
+
+		return &s.rules[i]
+	}
+
+	return nil
+}
+
+// chance returns true with the given probability.
+func (s *FaultInjectingRawStore) chance(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.random.Float64() < probability
+}
+
+func (s *FaultInjectingRawStore) record(ctx context.Context, fault string) {
+	s.metrics.Injected.Inc(context.WithValue(ctx, FaultLabel, fault))
+}
+
+// inject delays and fails the operation on reference according to the first matching rule, which is returned so that
+// reads can also be cut short.
+func (s *FaultInjectingRawStore) inject(ctx context.Context, operation string, reference DataReference) (*FaultRule, error) {
+	rule := s.rule(operation, reference)
+	if rule == nil {
+		return nil, nil
+	}
+
+	ctx = context.WithValue(ctx, OperationLabel, operation)
+	if s.chance(rule.LatencyProbability) {
+		s.record(ctx, faultLatency)
+		timer := time.NewTimer(rule.Latency.Duration)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if s.chance(rule.ErrorProbability) {
+		s.record(ctx, faultError)
+		return nil, stdErrs.Errorf(ErrInjectedFault, "injected failure to %v [%v]", operation, reference)
+	}
+
+	if s.chance(rule.NotFoundProbability) {
+		s.record(ctx, faultNotFound)
+		return nil, stdErrs.Wrapf(ErrInjectedFault, os.ErrNotExist, "injected not found for %v [%v]", operation,
+			reference)
+	}
+
+	return rule, nil
+}
+
+// truncate returns a reader that fails with io.ErrUnexpectedEOF after a random part of the data in rc is read if the
+// rule calls for a partial read. Otherwise, rc is returned as is.
+func (s *FaultInjectingRawStore) truncate(ctx context.Context, operation string, reference DataReference,
+	rule *FaultRule, rc io.ReadCloser) (io.ReadCloser, error) {
+
+	if rule == nil || !s.chance(rule.PartialReadProbability) {
+		return rc, nil
+	}
+
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	cut := 0
+	if len(data) > 0 {
+		cut = s.random.Intn(len(data))
+	}
+	s.lock.Unlock()
+
+	s.record(context.WithValue(ctx, OperationLabel, operation), faultPartialRead)
+	return ioutil.NopCloser(io.MultiReader(bytes.NewReader(data[:cut]), errReader{
+		err: stdErrs.Wrapf(ErrInjectedFault, io.ErrUnexpectedEOF, "injected partial read of [%v]", reference),
+	})), nil
+}
+
+// Head reports that the reference doesn't exist if a Not Found fault is injected.
+func (s *FaultInjectingRawStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+	if _, err := s.inject(ctx, "head", reference); err != nil {
+		if IsNotFound(err) {
+			return MemoryMetadata{}, nil
+		}
+
+		return nil, err
+	}
+
+	return s.RawStore.Head(ctx, reference)
+}
+
+func (s *FaultInjectingRawStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	rule, err := s.inject(ctx, "read", reference)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := s.RawStore.ReadRaw(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.truncate(ctx, "read", reference, rule, rc)
+}
+
+func (s *FaultInjectingRawStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (
+	io.ReadCloser, error) {
+	rule, err := s.inject(ctx, "read_range", reference)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := s.RawStore.ReadRawRange(ctx, reference, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.truncate(ctx, "read_range", reference, rule, rc)
+}
+
+func (s *FaultInjectingRawStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	if _, err := s.inject(ctx, "write", reference); err != nil {
+		return err
+	}
+
+	return s.RawStore.WriteRaw(ctx, reference, size, opts, raw)
+}
+
+func (s *FaultInjectingRawStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	if _, err := s.inject(ctx, "open_writer", reference); err != nil {
+		return nil, err
+	}
+
+	return s.RawStore.OpenWriter(ctx, reference, opts)
+}
+
+// CopyRaw injects faults matching the source reference.
+func (s *FaultInjectingRawStore) CopyRaw(ctx context.Context, source, destination DataReference, opts Options) error {
+	if _, err := s.inject(ctx, "copy", source); err != nil {
+		return err
+	}
+
+	return s.RawStore.CopyRaw(ctx, source, destination, opts)
+}
+
+func (s *FaultInjectingRawStore) Delete(ctx context.Context, reference DataReference) error {
+	if _, err := s.inject(ctx, "delete", reference); err != nil {
+		return err
+	}
+
+	return s.RawStore.Delete(ctx, reference)
+}
+
+// List lists nothing under prefix if a Not Found fault is injected.
+func (s *FaultInjectingRawStore) List(ctx context.Context, prefix DataReference, cursor Cursor, limit int) (
+	[]DataReference, Cursor, error) {
+	if _, err := s.inject(ctx, "list", prefix); err != nil {
+		if IsNotFound(err) {
+			return nil, NewCursorAtEnd(), nil
+		}
+
+		return nil, NewCursorAtStart(), err
+	}
+
+	return s.RawStore.List(ctx, prefix, cursor, limit)
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newFaultMetrics(scope promutils.Scope) *faultMetrics {
+	return &faultMetrics{
+		Injected: labeled.NewCounter("injected", "Number of faults injected into storage operations", scope,
+			labeled.EmitUnlabeledMetric, labeled.AdditionalLabelsOption{
+				Labels: []string{OperationLabel.String(), FaultLabel.String()},
+			}),
+	}
+}
+
+// NewFaultInjectingRawStore creates a RawStore that injects faults into the operations of store according to cfg.
+// Type in cfg is ignored.
+func NewFaultInjectingRawStore(store RawStore, cfg FaultsConfig, scope promutils.Scope) (*FaultInjectingRawStore, error) {
+	return newFaultInjectingRawStore(store, cfg, newFaultMetrics(scope))
+}
+
+func newFaultInjectingRawStore(store RawStore, cfg FaultsConfig, metrics *faultMetrics) (*FaultInjectingRawStore, error) {
+	for i, rule := range cfg.Rules {
+		for _, operation := range rule.Operations {
+			if !contains(rawStoreOperations, operation) {
+				return nil, fmt.Errorf("fault rule [%v] has an invalid operation [%v]", i, operation)
+			}
+		}
+
+		if _, err := path.Match(rule.References, ""); err != nil {
+			return nil, fmt.Errorf("fault rule [%v] has an invalid references glob [%v]. Error: %w", i,
+				rule.References, err)
+		}
+
+		for _, probability := range []float64{rule.ErrorProbability, rule.NotFoundProbability,
+			rule.PartialReadProbability, rule.LatencyProbability} {
+			if probability < 0 || probability > 1 {
+				return nil, fmt.Errorf("fault rule [%v] has a probability out of [0, 1]", i)
+			}
+		}
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &FaultInjectingRawStore{
+		RawStore: store,
+		rules:    cfg.Rules,
+		metrics:  metrics,
+		// #nosec G404
+		random: rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// newFaultyRawStore creates the backend configured in cfg.Faults and injects faults into it. Failed operations are
+// retried around the injected faults according to the retry config.
+func newFaultyRawStore(ctx context.Context, cfg *Config, metrics *dataStoreMetrics) (RawStore, error) {
+	if cfg.Faults.Type == TypeFaulty {
+		return nil, fmt.Errorf("faults can't be injected into a store of type [%v]", TypeFaulty)
+	}
+
+	backendCfg := *cfg
+	backendCfg.Type = cfg.Faults.Type
+	backendCfg.Retry = RetryConfig{}
+	backendCfg.RateLimits = RateLimitsConfig{}
+	backend, err := newBackendRawStore(ctx, &backendCfg, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFaultInjectingRawStore(backend, cfg.Faults, metrics.faultMetrics)
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flyteorg/flytestdlib/config"
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+func TestFaultInjectingRawStore(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	require.NoError(t, err)
+	for _, ref := range []DataReference{"mem://container/fail/a", "mem://container/ok/a"} {
+		require.NoError(t, memStore.WriteRaw(ctx, ref, 5, Options{}, bytes.NewReader([]byte("hello"))))
+	}
+
+	t.Run("Matches operations and references", func(t *testing.T) {
+		store, err := newFaultInjectingRawStore(memStore, FaultsConfig{Seed: 1, Rules: []FaultRule{{
+			Operations:       []string{"read"},
+			References:       "mem://container/fail/*",
+			ErrorProbability: 1,
+		}}}, metrics.faultMetrics)
+		require.NoError(t, err)
+
+		_, err = store.ReadRaw(ctx, "mem://container/fail/a")
+		assert.True(t, IsInjectedFault(err), err)
+		assert.True(t, IsRetryable(err))
+
+		_, err = store.ReadRaw(ctx, "mem://container/ok/a")
+		assert.NoError(t, err)
+		md, err := store.Head(ctx, "mem://container/fail/a")
+		assert.NoError(t, err)
+		assert.True(t, md.Exists())
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		store, err := newFaultInjectingRawStore(memStore, FaultsConfig{Seed: 1, Rules: []FaultRule{{
+			NotFoundProbability: 1,
+		}}}, metrics.faultMetrics)
+		require.NoError(t, err)
+
+		md, err := store.Head(ctx, "mem://container/ok/a")
+		assert.NoError(t, err)
+		assert.False(t, md.Exists())
+
+		_, err = store.ReadRaw(ctx, "mem://container/ok/a")
+		assert.True(t, IsNotFound(err), err)
+		assert.False(t, IsRetryable(err))
+
+		refs, cursor, err := store.List(ctx, "mem://container", NewCursorAtStart(), 10)
+		assert.NoError(t, err)
+		assert.Empty(t, refs)
+		assert.True(t, IsCursorEnd(cursor))
+	})
+
+	t.Run("Partial read", func(t *testing.T) {
+		store, err := newFaultInjectingRawStore(memStore, FaultsConfig{Seed: 1, Rules: []FaultRule{{
+			PartialReadProbability: 1,
+		}}}, metrics.faultMetrics)
+		require.NoError(t, err)
+
+		rc, err := store.ReadRaw(ctx, "mem://container/ok/a")
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(rc)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Less(t, len(data), 5)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		store, err := newFaultInjectingRawStore(memStore, FaultsConfig{Seed: 1, Rules: []FaultRule{{
+			LatencyProbability: 1,
+			Latency:            config.Duration{Duration: time.Hour},
+		}}}, metrics.faultMetrics)
+		require.NoError(t, err)
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		err = store.WriteRaw(cancelledCtx, "mem://container/ok/a", 1, Options{}, bytes.NewReader([]byte("a")))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Seeded", func(t *testing.T) {
+		run := func() []bool {
+			store, err := newFaultInjectingRawStore(memStore, FaultsConfig{Seed: 1, Rules: []FaultRule{{
+				ErrorProbability: 0.5,
+			}}}, metrics.faultMetrics)
+			require.NoError(t, err)
+			failures := make([]bool, 0, 20)
+			for i := 0; i < 20; i++ {
+				_, err := store.Head(ctx, "mem://container/ok/a")
+				failures = append(failures, err != nil)
+			}
+
+			return failures
+		}
+
+		failures := run()
+		assert.Contains(t, failures, true)
+		assert.Contains(t, failures, false)
+		assert.Equal(t, failures, run())
+	})
+
+	t.Run("Invalid rules", func(t *testing.T) {
+		for _, rule := range []FaultRule{
+			{Operations: []string{"fetch"}},
+			{References: "mem://container/["},
+			{ErrorProbability: 1.5},
+		} {
+			_, err := NewFaultInjectingRawStore(&dummyStore{}, FaultsConfig{Rules: []FaultRule{rule}},
+				promutils.NewTestScope())
+			assert.Error(t, err)
+		}
+	})
+}
+
+func TestNewDataStore_Faulty(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Retries injected faults", func(t *testing.T) {
+		s, err := NewDataStore(&Config{
+			Type: TypeFaulty,
+			Faults: FaultsConfig{
+				Type: TypeMemory,
+				Seed: 1,
+				Rules: []FaultRule{
+					{Operations: []string{"write"}, ErrorProbability: 0.5},
+				},
+			},
+			Retry: RetryConfig{MaxAttempts: 20},
+		}, promutils.NewTestScope())
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, s.WriteRaw(ctx, "mem://container/a", 1, Options{}, bytes.NewReader([]byte("a"))))
+		}
+	})
+
+	t.Run("Faulty backend", func(t *testing.T) {
+		_, err := NewDataStore(&Config{Type: TypeFaulty, Faults: FaultsConfig{Type: TypeFaulty}},
+			promutils.NewTestScope())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"github.com/flyteorg/stow"
+	"github.com/flyteorg/stow/google"
+	errs "github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+
+	stdErrs "github.com/flyteorg/flytestdlib/errors"
+)
+
+// gcsLocation serves the containers of a stow GCS location through gcsContainer, which implements
+// ConditionalContainer and CopyContainer on top of the GCS client of the location.
+type gcsLocation struct {
+	stow.Location
+}
+
+func (l gcsLocation) Container(id string) (stow.Container, error) {
+	c, err := l.Location.Container(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGCSContainer(c), nil
+}
+
+func (l gcsLocation) CreateContainer(name string) (stow.Container, error) {
+	c, err := l.Location.CreateContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGCSContainer(c), nil
+}
+
+// gcsContainer implements ConditionalContainer and CopyContainer for a GCS bucket. Everything else is served by the stow container.
+type gcsContainer struct {
+	stow.Container
+	bucket *storage.BucketHandle
+}
+
+// PutIf writes the item with a generation precondition so that GCS checks the conditions atomically. GCS doesn't
+// accept Etags as preconditions: the generation of the item is looked up first and the write only succeeds if the
+// item wasn't replaced since, i.e. its Etag still matches.
+func (c *gcsContainer) PutIf(name string, r io.Reader, size int64, metadata map[string]interface{}, ifNotExists bool,
+	ifMatch string) (stow.Item, error) {
+
+	ctx := context.Background()
+	md, err := toStringMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := c.bucket.Object(name)
+	conditions := storage.Conditions{DoesNotExist: ifNotExists}
+	if len(ifMatch) > 0 {
+		attrs, err := obj.Attrs(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, stdErrs.Errorf(ErrPreconditionFailed, "[%v] doesn't exist", name)
+		} else if err != nil {
+			return nil, errs.Wrapf(err, "failed to get attributes of [%v]", name)
+		}
+
+		if attrs.Etag != ifMatch {
+			return nil, stdErrs.Errorf(ErrPreconditionFailed, "Etag of [%v] doesn't match [%v]", name, ifMatch)
+		}
+
+		if !ifNotExists {
+			conditions = storage.Conditions{GenerationMatch: attrs.Generation}
+		}
+	}
+
+	w := obj.If(conditions).NewWriter(ctx)
+	w.Metadata = md
+	if _, err = io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return nil, errs.Wrapf(err, "failed to put item [%v]", name)
+	}
+
+	if err = w.Close(); err != nil {
+		if isGCSStatus(err, http.StatusPreconditionFailed) {
+			return nil, stdErrs.Wrapf(ErrPreconditionFailed, err, "conditions of put to [%v] don't hold", name)
+		}
+
+		return nil, errs.Wrapf(err, "failed to put item [%v]", name)
+	}
+
+	return c.Container.Item(name)
+}
+
+// CopyItem rewrites the source item to destination server-side. The metadata of the source item is preserved unless
+// metadata isn't nil.
+func (c *gcsContainer) CopyItem(source, destination string, metadata map[string]interface{}) (stow.Item, error) {
+	copier := c.bucket.Object(destination).CopierFrom(c.bucket.Object(source))
+	if metadata != nil {
+		md, err := toStringMetadata(metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		copier.Metadata = md
+	}
+
+	if _, err := copier.Run(context.Background()); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) || isGCSStatus(err, http.StatusNotFound) {
+			return nil, errs.Wrapf(stow.ErrNotFound, "failed to copy item [%v] to [%v]", source, destination)
+		}
+
+		return nil, errs.Wrapf(err, "failed to copy item [%v] to [%v]", source, destination)
+	}
+
+	return c.Container.Item(destination)
+}
+
+// newGCSContainer wraps c if it's a stow GCS container. Other containers are returned as is.
+func newGCSContainer(c stow.Container) stow.Container {
+	gc, ok := c.(*google.Container)
+	if !ok {
+		return c
+	}
+
+	return &gcsContainer{Container: gc, bucket: gc.Bucket()}
+}
+
+// isGCSStatus gets a value indicating whether err is a GCS API error with the given HTTP status code.
+func isGCSStatus(err error, code int) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeGCSObject struct {
+	data       []byte
+	generation int64
+	metadata   map[string]string
+}
+
+// fakeGCS serves the subset of the GCS JSON API used by gcsContainer.
+type fakeGCS struct {
+	lock       sync.Mutex
+	objects    map[string]fakeGCSObject
+	generation int64
+	// onUpload is invoked when an upload is received, before its preconditions are checked.
+	onUpload func()
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	const objectsPath = "/storage/v1/b/bucket/o/"
+	switch {
+	case r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/rewriteTo/"):
+		names := strings.SplitN(strings.TrimPrefix(r.URL.Path, objectsPath), "/rewriteTo/b/bucket/o/", 2)
+		source, found := f.objects[names[0]]
+		if !found {
+			writeGCSError(w, http.StatusNotFound)
+			return
+		}
+
+		attrs := struct{ Metadata map[string]string }{}
+		if err := json.NewDecoder(r.Body).Decode(&attrs); err != nil {
+			writeGCSError(w, http.StatusBadRequest)
+			return
+		}
+
+		if attrs.Metadata != nil {
+			source.metadata = attrs.Metadata
+		}
+
+		f.generation++
+		source.generation = f.generation
+		f.objects[names[1]] = source
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"done":     true,
+			"resource": f.objectAttrs(names[1], source),
+		})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, objectsPath):
+		name := strings.TrimPrefix(r.URL.Path, objectsPath)
+		object, found := f.objects[name]
+		if !found {
+			writeGCSError(w, http.StatusNotFound)
+			return
+		}
+
+		f.writeObject(w, name, object)
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			writeGCSError(w, http.StatusBadRequest)
+			return
+		}
+
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		attrsPart, err := reader.NextPart()
+		if err != nil {
+			writeGCSError(w, http.StatusBadRequest)
+			return
+		}
+
+		attrs := struct {
+			Name     string
+			Metadata map[string]string
+		}{}
+		if err = json.NewDecoder(attrsPart).Decode(&attrs); err != nil {
+			writeGCSError(w, http.StatusBadRequest)
+			return
+		}
+
+		dataPart, err := reader.NextPart()
+		if err != nil {
+			writeGCSError(w, http.StatusBadRequest)
+			return
+		}
+
+		data, err := ioutil.ReadAll(dataPart)
+		if err != nil {
+			writeGCSError(w, http.StatusBadRequest)
+			return
+		}
+
+		if f.onUpload != nil {
+			f.onUpload()
+		}
+
+		if generationMatch := r.URL.Query().Get("ifGenerationMatch"); len(generationMatch) > 0 {
+			generation, _ := strconv.ParseInt(generationMatch, 10, 64)
+			if f.objects[attrs.Name].generation != generation {
+				writeGCSError(w, http.StatusPreconditionFailed)
+				return
+			}
+		}
+
+		f.generation++
+		object := fakeGCSObject{data: data, generation: f.generation, metadata: attrs.Metadata}
+		f.objects[attrs.Name] = object
+		f.writeObject(w, attrs.Name, object)
+	default:
+		writeGCSError(w, http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeGCS) writeObject(w http.ResponseWriter, name string, object fakeGCSObject) {
+	_ = json.NewEncoder(w).Encode(f.objectAttrs(name, object))
+}
+
+func (f *fakeGCS) objectAttrs(name string, object fakeGCSObject) map[string]interface{} {
+	generation := strconv.FormatInt(object.generation, 10)
+	return map[string]interface{}{
+		"bucket":     "bucket",
+		"name":       name,
+		"generation": generation,
+		"etag":       "etag-" + generation,
+		"size":       strconv.Itoa(len(object.data)),
+		"metadata":   object.metadata,
+	}
+}
+
+func writeGCSError(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": http.StatusText(code)},
+	})
+}
+
+func TestGCSContainer_PutIf(t *testing.T) {
+	ctx := context.TODO()
+	fake := &fakeGCS{objects: map[string]fakeGCSObject{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+	client, err := storage.NewClient(ctx)
+	require.NoError(t, err)
+
+	stowContainer := newMockStowContainer("bucket")
+	stowContainer.items["a"] = mockStowItem{}
+	c := &gcsContainer{Container: stowContainer, bucket: client.Bucket("bucket")}
+
+	t.Run("If not exists", func(t *testing.T) {
+		_, err := c.PutIf("a", bytes.NewReader([]byte("hello")), 5, nil, true, "")
+		assert.NoError(t, err)
+
+		_, err = c.PutIf("a", bytes.NewReader([]byte("world")), 5, nil, true, "")
+		assert.True(t, IsPreconditionFailed(err))
+		assert.Equal(t, "hello", string(fake.objects["a"].data))
+	})
+
+	t.Run("If match", func(t *testing.T) {
+		_, err := c.PutIf("a", bytes.NewReader([]byte("world")), 5, map[string]interface{}{"owner": "me"}, false,
+			"etag-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "world", string(fake.objects["a"].data))
+
+		_, err = c.PutIf("a", bytes.NewReader([]byte("again")), 5, nil, false, "etag-1")
+		assert.True(t, IsPreconditionFailed(err))
+
+		_, err = c.PutIf("b", bytes.NewReader([]byte("again")), 5, nil, false, "etag-1")
+		assert.True(t, IsPreconditionFailed(err))
+	})
+
+	t.Run("Replaced concurrently", func(t *testing.T) {
+		// The generation the write is conditioned on is looked up first, the server rejects it if it changed since.
+		fake.lock.Lock()
+		object := fake.objects["a"]
+		fake.onUpload = func() {
+			fake.objects["a"] = fakeGCSObject{data: []byte("other"), generation: object.generation + 10}
+		}
+		fake.lock.Unlock()
+
+		_, err := c.PutIf("a", bytes.NewReader([]byte("mine!")), 5, nil, false,
+			"etag-"+strconv.FormatInt(object.generation, 10))
+		assert.True(t, IsPreconditionFailed(err))
+		assert.Equal(t, "other", string(fake.objects["a"].data))
+	})
+}
+
+func TestGCSContainer_CopyItem(t *testing.T) {
+	ctx := context.TODO()
+	fake := &fakeGCS{objects: map[string]fakeGCSObject{
+		"a": {data: []byte("hello"), generation: 1, metadata: map[string]string{"owner": "me"}},
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+	client, err := storage.NewClient(ctx)
+	require.NoError(t, err)
+
+	stowContainer := newMockStowContainer("bucket")
+	stowContainer.items["b"] = mockStowItem{}
+	stowContainer.items["c"] = mockStowItem{}
+	c := &gcsContainer{Container: stowContainer, bucket: client.Bucket("bucket")}
+
+	_, err = c.CopyItem("a", "b", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(fake.objects["b"].data))
+	assert.Equal(t, "me", fake.objects["b"].metadata["owner"])
+
+	_, err = c.CopyItem("a", "c", map[string]interface{}{"owner": "you"})
+	assert.NoError(t, err)
+	assert.Equal(t, "you", fake.objects["c"].metadata["owner"])
+
+	_, err = c.CopyItem("missing", "d", nil)
+	assert.True(t, IsNotFound(err), err)
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	KeyProviderFile = "file"
+	KeyProviderEnv  = "env"
+)
+
+// aes256KeySize is the size of keys used for both data and key encryption.
+const aes256KeySize = 32
+
+//go:generate mockery -name KeyProvider -case=underscore
+
+// KeyProvider supplies the key encryption keys used to wrap the per-object data keys of encrypted objects. It can be
+// backed by local keys or an external KMS.
+type KeyProvider interface {
+	// WrapKey encrypts dataKey with the current key encryption key and returns the id of the key used.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrappedKey []byte, err error)
+
+	// UnwrapKey decrypts a data key previously wrapped with the key identified by keyID.
+	UnwrapKey(ctx context.Context, keyID string, wrappedKey []byte) (dataKey []byte, err error)
+}
+
+type keyProviderCreateFn func(ctx context.Context, cfg EncryptionConfig) (KeyProvider, error)
+
+var keyProviders = map[string]keyProviderCreateFn{
+	KeyProviderFile: newFileKeyProvider,
+	KeyProviderEnv:  newEnvKeyProvider,
+}
+
+// RegisterKeyProvider registers a new kind of key provider (e.g. backed by a KMS) that can then be selected through
+// the encryption config.
+func RegisterKeyProvider(kind string, fn func(ctx context.Context, cfg EncryptionConfig) (KeyProvider, error)) error {
+	if _, ok := keyProviders[kind]; ok {
+		return fmt.Errorf("key provider [%v] already registered", kind)
+	}
+
+	keyProviders[kind] = fn
+	return nil
+}
+
+// staticKeyProvider wraps data keys locally using AES-256 keys. New data keys are wrapped with the active key, while
+// retired keys can still unwrap the data keys of objects written before the active key was rotated in.
+type staticKeyProvider struct {
+	activeKeyID string
+	aeads       map[string]cipher.AEAD
+}
+
+func (p staticKeyProvider) WrapKey(_ context.Context, dataKey []byte) (keyID string, wrappedKey []byte, err error) {
+	aead := p.aeads[p.activeKeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", nil, err
+	}
+
+	return p.activeKeyID, aead.Seal(nonce, nonce, dataKey, []byte(p.activeKeyID)), nil
+}
+
+func (p staticKeyProvider) UnwrapKey(_ context.Context, keyID string, wrappedKey []byte) (dataKey []byte, err error) {
+	aead, found := p.aeads[keyID]
+	if !found {
+		return nil, fmt.Errorf("unknown key id [%v]", keyID)
+	}
+
+	if len(wrappedKey) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+
+	nonce, ciphertext := wrappedKey[:aead.NonceSize()], wrappedKey[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(keyID))
+}
+
+// newStaticKeyProvider creates a staticKeyProvider from base64 encoded keys by id. New data keys are wrapped with the
+// key identified by activeKeyID.
+func newStaticKeyProvider(activeKeyID string, encodedKeys map[string]string) (KeyProvider, error) {
+	if len(activeKeyID) == 0 {
+		return nil, fmt.Errorf("keyId is required")
+	}
+
+	if _, found := encodedKeys[activeKeyID]; !found {
+		return nil, fmt.Errorf("key [%v] is missing", activeKeyID)
+	}
+
+	aeads := make(map[string]cipher.AEAD, len(encodedKeys))
+	for keyID, encodedKey := range encodedKeys {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+		if err != nil {
+			return nil, fmt.Errorf("key [%v] must be base64 encoded. Error: %v", keyID, err)
+		}
+
+		if len(key) != aes256KeySize {
+			return nil, fmt.Errorf("key [%v] must be %v bytes long, found [%v]", keyID, aes256KeySize, len(key))
+		}
+
+		if aeads[keyID], err = newAESGCM(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return staticKeyProvider{
+		activeKeyID: activeKeyID,
+		aeads:       aeads,
+	}, nil
+}
+
+// loadKeys loads the active key and the retired keys of cfg by id through load, which gets a key from its source (e.g.
+// a file path).
+func loadKeys(cfg EncryptionConfig, activeSource string, retiredSources map[string]string,
+	load func(source string) (string, error)) (map[string]string, error) {
+
+	if _, found := retiredSources[cfg.KeyID]; found {
+		return nil, fmt.Errorf("key [%v] can't be both active and retired", cfg.KeyID)
+	}
+
+	keys := make(map[string]string, len(retiredSources)+1)
+	for keyID, source := range retiredSources {
+		key, err := load(source)
+		if err != nil {
+			return nil, err
+		}
+
+		keys[keyID] = key
+	}
+
+	key, err := load(activeSource)
+	if err != nil {
+		return nil, err
+	}
+
+	keys[cfg.KeyID] = key
+	return keys, nil
+}
+
+func newFileKeyProvider(_ context.Context, cfg EncryptionConfig) (KeyProvider, error) {
+	keys, err := loadKeys(cfg, cfg.KeyFile, cfg.PreviousKeyFiles, func(keyFile string) (string, error) {
+		raw, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read key file [%v]. Error: %v", keyFile, err)
+		}
+
+		return string(raw), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newStaticKeyProvider(cfg.KeyID, keys)
+}
+
+func newEnvKeyProvider(_ context.Context, cfg EncryptionConfig) (KeyProvider, error) {
+	keys, err := loadKeys(cfg, cfg.KeyEnvVar, cfg.PreviousKeyEnvVars, func(keyEnvVar string) (string, error) {
+		encodedKey, found := os.LookupEnv(keyEnvVar)
+		if !found {
+			return "", fmt.Errorf("environment variable [%v] is not set", keyEnvVar)
+		}
+
+		return encodedKey, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newStaticKeyProvider(cfg.KeyID, keys)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
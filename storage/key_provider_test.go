@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testEncryptionKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestStaticKeyProvider(t *testing.T) {
+	ctx := context.TODO()
+	p, err := newStaticKeyProvider("key1", map[string]string{"key1": testEncryptionKey})
+	assert.NoError(t, err)
+
+	dataKey := []byte("fedcba9876543210fedcba9876543210")
+	keyID, wrapped, err := p.WrapKey(ctx, dataKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", keyID)
+	assert.NotContains(t, string(wrapped), string(dataKey))
+
+	unwrapped, err := p.UnwrapKey(ctx, keyID, wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	_, err = p.UnwrapKey(ctx, "key2", wrapped)
+	assert.Error(t, err)
+
+	_, err = p.UnwrapKey(ctx, keyID, wrapped[:5])
+	assert.Error(t, err)
+}
+
+func TestStaticKeyProvider_Rotation(t *testing.T) {
+	ctx := context.TODO()
+	oldProvider, err := newStaticKeyProvider("key1", map[string]string{"key1": testEncryptionKey})
+	assert.NoError(t, err)
+
+	dataKey := []byte("fedcba9876543210fedcba9876543210")
+	oldKeyID, oldWrapped, err := oldProvider.WrapKey(ctx, dataKey)
+	assert.NoError(t, err)
+
+	p, err := newStaticKeyProvider("key2", map[string]string{
+		"key1": testEncryptionKey,
+		"key2": base64.StdEncoding.EncodeToString([]byte("abcdef0123456789abcdef0123456789")),
+	})
+	assert.NoError(t, err)
+
+	unwrapped, err := p.UnwrapKey(ctx, oldKeyID, oldWrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	keyID, wrapped, err := p.WrapKey(ctx, dataKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "key2", keyID)
+	unwrapped, err = p.UnwrapKey(ctx, keyID, wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	_, err = oldProvider.UnwrapKey(ctx, keyID, wrapped)
+	assert.Error(t, err)
+}
+
+func TestNewStaticKeyProvider(t *testing.T) {
+	_, err := newStaticKeyProvider("", map[string]string{"": testEncryptionKey})
+	assert.Error(t, err)
+
+	_, err = newStaticKeyProvider("key1", map[string]string{"key1": "not base64!"})
+	assert.Error(t, err)
+
+	_, err = newStaticKeyProvider("key1", map[string]string{
+		"key1": base64.StdEncoding.EncodeToString([]byte("short")),
+	})
+	assert.Error(t, err)
+}
+
+func TestNewFileKeyProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stdlib_keys")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(dir))
+	}()
+
+	keyFile := filepath.Join(dir, "key")
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte(testEncryptionKey+"\n"), 0600))
+
+	p, err := newFileKeyProvider(context.TODO(), EncryptionConfig{KeyID: "key1", KeyFile: keyFile})
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+
+	_, err = newFileKeyProvider(context.TODO(), EncryptionConfig{KeyID: "key1", KeyFile: filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+}
+
+func TestNewEnvKeyProvider(t *testing.T) {
+	t.Setenv("STDLIB_TEST_KEY", testEncryptionKey)
+	p, err := newEnvKeyProvider(context.TODO(), EncryptionConfig{KeyID: "key1", KeyEnvVar: "STDLIB_TEST_KEY"})
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+
+	_, err = newEnvKeyProvider(context.TODO(), EncryptionConfig{KeyID: "key1", KeyEnvVar: "STDLIB_TEST_MISSING_KEY"})
+	assert.Error(t, err)
+
+	t.Run("Retired keys", func(t *testing.T) {
+		t.Setenv("STDLIB_TEST_NEW_KEY", base64.StdEncoding.EncodeToString([]byte("abcdef0123456789abcdef0123456789")))
+		p, err := newEnvKeyProvider(context.TODO(), EncryptionConfig{
+			KeyID:              "key2",
+			KeyEnvVar:          "STDLIB_TEST_NEW_KEY",
+			PreviousKeyEnvVars: map[string]string{"key1": "STDLIB_TEST_KEY"},
+		})
+		assert.NoError(t, err)
+		keyID, _, err := p.WrapKey(context.TODO(), []byte("fedcba9876543210fedcba9876543210"))
+		assert.NoError(t, err)
+		assert.Equal(t, "key2", keyID)
+
+		_, err = newEnvKeyProvider(context.TODO(), EncryptionConfig{
+			KeyID:              "key2",
+			KeyEnvVar:          "STDLIB_TEST_NEW_KEY",
+			PreviousKeyEnvVars: map[string]string{"key1": "STDLIB_TEST_MISSING_KEY"},
+		})
+		assert.Error(t, err)
+
+		_, err = newEnvKeyProvider(context.TODO(), EncryptionConfig{
+			KeyID:              "key1",
+			KeyEnvVar:          "STDLIB_TEST_KEY",
+			PreviousKeyEnvVars: map[string]string{"key1": "STDLIB_TEST_KEY"},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestRegisterKeyProvider(t *testing.T) {
+	assert.Error(t, RegisterKeyProvider(KeyProviderFile, newFileKeyProvider))
+	assert.NoError(t, RegisterKeyProvider("test-kms", func(ctx context.Context, cfg EncryptionConfig) (KeyProvider, error) {
+		return newStaticKeyProvider(cfg.KeyID, map[string]string{cfg.KeyID: cfg.Config["key"]})
+	}))
+
+	defer delete(keyProviders, "test-kms")
+	store, err := newEncryptingRawStore(context.TODO(), &Config{
+		Encryption: EncryptionConfig{
+			Enabled:     true,
+			KeyProvider: "test-kms",
+			KeyID:       "kms-key",
+			Config:      map[string]string{"key": testEncryptionKey},
+		},
+	}, nil, metrics.encryptionMetrics)
+	assert.NoError(t, err)
+	assert.IsType(t, &encryptingRawStore{}, store)
+}
@@ -9,19 +9,35 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 type rawFile = []byte
 
 type InMemoryStore struct {
 	copyImpl
-	cache map[DataReference]rawFile
+	// lock guards cache and objectInfos.
+	lock         sync.RWMutex
+	cache        map[DataReference]rawFile
+	objectInfos  map[DataReference]objectInfo
+	multipartCfg MultipartUploadConfig
+}
+
+// objectInfo holds the metadata of an object stored in memory.
+type objectInfo struct {
+	userMetadata map[string]string
+	lastModified time.Time
 }
 
 type MemoryMetadata struct {
-	exists bool
-	size   int64
-	etag   string
+	exists       bool
+	size         int64
+	etag         string
+	userMetadata map[string]string
+	lastModified time.Time
 }
 
 func (m MemoryMetadata) Size() int64 {
@@ -36,20 +52,49 @@ func (m MemoryMetadata) Etag() string {
 	return m.etag
 }
 
+func (m MemoryMetadata) UserMetadata() map[string]string {
+	return m.userMetadata
+}
+
+func (m MemoryMetadata) ContentType() string {
+	return m.userMetadata[MetadataKeyContentType]
+}
+
+func (m MemoryMetadata) LastModified() time.Time {
+	return m.lastModified
+}
+
+// ContentMD5 returns the MD5 recorded in the user metadata or, failing that, the Etag which is the MD5 of the object.
+func (m MemoryMetadata) ContentMD5() string {
+	return contentMD5(m.userMetadata, m.etag)
+}
+
 func (s *InMemoryStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.head(reference), nil
+}
+
+// head must be called with the lock held.
+func (s *InMemoryStore) head(reference DataReference) MemoryMetadata {
 	data, found := s.cache[reference]
 	var hash [md5.Size]byte
 	if found {
 		hash = md5.Sum(data) // #nosec
 	}
 
+	info := s.objectInfos[reference]
 	return MemoryMetadata{
 		exists: found, size: int64(len(data)),
-		etag: hex.EncodeToString(hash[:]),
-	}, nil
+		etag:         hex.EncodeToString(hash[:]),
+		userMetadata: info.userMetadata,
+		lastModified: info.lastModified,
+	}
 }
 
 func (s *InMemoryStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	if raw, found := s.cache[reference]; found {
 		return ioutil.NopCloser(bytes.NewReader(raw)), nil
 	}
@@ -57,13 +102,32 @@ func (s *InMemoryStore) ReadRaw(ctx context.Context, reference DataReference) (i
 	return nil, os.ErrNotExist
 }
 
+func (s *InMemoryStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	raw, found := s.cache[reference]
+	if !found {
+		return nil, os.ErrNotExist
+	}
+
+	start, end, err := rangeBounds(int64(len(raw)), offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(raw[start:end])), nil
+}
+
 // Delete removes the referenced data from the cache map.
 func (s *InMemoryStore) Delete(ctx context.Context, reference DataReference) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	if _, found := s.cache[reference]; !found {
 		return os.ErrNotExist
 	}
 
 	delete(s.cache, reference)
+	delete(s.objectInfos, reference)
 
 	return nil
 }
@@ -76,12 +140,78 @@ func (s *InMemoryStore) WriteRaw(ctx context.Context, reference DataReference, s
 		return err
 	}
 
-	s.cache[reference] = rawBytes
+	return s.put(reference, rawBytes, opts)
+}
+
+// put stores the object if the write conditions in opts hold. The check and the write are atomic.
+func (s *InMemoryStore) put(reference DataReference, raw rawFile, opts Options) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := checkWriteConditions(reference, s.head(reference), opts); err != nil {
+		return err
+	}
+
+	s.cache[reference] = raw
+	s.objectInfos[reference] = objectInfo{
+		userMetadata: toUserMetadata(opts.Metadata),
+		lastModified: time.Now(),
+	}
+
 	return nil
 }
 
+// List retrieves up to limit references, in lexical order, that start with the given prefix.
+func (s *InMemoryStore) List(ctx context.Context, prefix DataReference, cursor Cursor, limit int) ([]DataReference, Cursor, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if IsCursorEnd(cursor) {
+		return []DataReference{}, cursor, nil
+	}
+
+	if limit <= 0 {
+		return nil, cursor, fmt.Errorf("limit must be positive, found [%v]", limit)
+	}
+
+	keys := make([]DataReference, 0, len(s.cache))
+	for ref := range s.cache {
+		if !strings.HasPrefix(ref.String(), prefix.String()) {
+			continue
+		}
+
+		if cursor.cursorState == AtCustomPosCursorState && ref.String() <= cursor.customPosition {
+			continue
+		}
+
+		keys = append(keys, ref)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	if len(keys) <= limit {
+		return keys, NewCursorAtEnd(), nil
+	}
+
+	keys = keys[:limit]
+	return keys, NewCursorFromCustomPosition(keys[len(keys)-1].String()), nil
+}
+
+// OpenWriter returns a writer that uploads data in parallel parts, mirroring multipart uploads in remote stores.
+func (s *InMemoryStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	return newMultipartWriter(ctx, &inMemoryMultipartUpload{
+		store:     s,
+		reference: reference,
+		opts:      opts,
+		parts:     map[int][]byte{},
+	}, s.multipartCfg), nil
+}
+
 func (s *InMemoryStore) Clear(ctx context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	s.cache = map[DataReference]rawFile{}
+	s.objectInfos = map[DataReference]objectInfo{}
 	return nil
 }
 
@@ -94,11 +224,64 @@ func (s *InMemoryStore) CreateSignedURL(ctx context.Context, reference DataRefer
 	return SignedURLResponse{}, fmt.Errorf("unsupported")
 }
 
-func NewInMemoryRawStore(_ context.Context, _ *Config, metrics *dataStoreMetrics) (RawStore, error) {
+func NewInMemoryRawStore(_ context.Context, cfg *Config, metrics *dataStoreMetrics) (RawStore, error) {
 	self := &InMemoryStore{
-		cache: map[DataReference]rawFile{},
+		cache:       map[DataReference]rawFile{},
+		objectInfos: map[DataReference]objectInfo{},
+	}
+
+	if cfg != nil {
+		self.multipartCfg = cfg.MultipartUpload
 	}
 
 	self.copyImpl = newCopyImpl(self, metrics.copyMetrics)
 	return self, nil
 }
+
+// inMemoryMultipartUpload holds uploaded parts in memory until the upload is completed.
+type inMemoryMultipartUpload struct {
+	store     *InMemoryStore
+	reference DataReference
+	opts      Options
+	lock      sync.Mutex
+	parts     map[int][]byte
+}
+
+func (u *inMemoryMultipartUpload) UploadPart(ctx context.Context, partNumber int, raw []byte) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.parts == nil {
+		return fmt.Errorf("multipart upload to [%v] was aborted", u.reference)
+	}
+
+	u.parts[partNumber] = raw
+	return nil
+}
+
+func (u *inMemoryMultipartUpload) Complete(ctx context.Context, partCount int) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	var buf bytes.Buffer
+	for i := 1; i <= partCount; i++ {
+		part, found := u.parts[i]
+		if !found {
+			return fmt.Errorf("part [%v] of [%v] is missing", i, partCount)
+		}
+
+		buf.Write(part)
+	}
+
+	if err := u.store.put(u.reference, buf.Bytes(), u.opts); err != nil {
+		return err
+	}
+
+	u.parts = nil
+	return nil
+}
+
+func (u *inMemoryMultipartUpload) Abort(ctx context.Context) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.parts = nil
+	return nil
+}
@@ -3,7 +3,11 @@ package storage
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -27,6 +31,28 @@ func TestInMemoryStore_Head(t *testing.T) {
 		assert.NoError(t, err)
 		assert.True(t, metadata.Exists())
 	})
+
+	t.Run("Object metadata", func(t *testing.T) {
+		s, err := NewInMemoryRawStore(context.TODO(), &Config{}, metrics)
+		assert.NoError(t, err)
+		before := time.Now()
+		err = s.WriteRaw(context.TODO(), DataReference("hello"), 5, Options{
+			Metadata: map[string]interface{}{MetadataKeyContentType: "text/plain", "Owner": "someone"},
+		}, bytes.NewReader([]byte("hello")))
+		assert.NoError(t, err)
+
+		metadata, err := s.Head(context.TODO(), DataReference("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, "text/plain", metadata.ContentType())
+		assert.Equal(t, map[string]string{"content-type": "text/plain", "owner": "someone"}, metadata.UserMetadata())
+		assert.False(t, metadata.LastModified().Before(before))
+		assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", metadata.ContentMD5())
+
+		assert.NoError(t, s.Delete(context.TODO(), DataReference("hello")))
+		metadata, err = s.Head(context.TODO(), DataReference("hello"))
+		assert.NoError(t, err)
+		assert.Empty(t, metadata.UserMetadata())
+	})
 }
 
 func TestInMemoryStore_ReadRaw(t *testing.T) {
@@ -51,6 +77,25 @@ func TestInMemoryStore_ReadRaw(t *testing.T) {
 	})
 }
 
+func TestInMemoryStore_ReadRawRange(t *testing.T) {
+	ctx := context.TODO()
+	s, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+	assert.NoError(t, s.WriteRaw(ctx, "hello", 0, Options{}, bytes.NewReader([]byte("hello world"))))
+
+	rc, err := s.ReadRawRange(ctx, "hello", 0, 5)
+	assert.NoError(t, err)
+	raw, err := ioutil.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(raw))
+
+	_, err = s.ReadRawRange(ctx, "hello", 12, 5)
+	assert.Error(t, err)
+
+	_, err = s.ReadRawRange(ctx, "world", 0, 5)
+	assert.True(t, IsNotFound(err))
+}
+
 func TestInMemoryStore_Clear(t *testing.T) {
 	m, err := NewInMemoryRawStore(context.TODO(), &Config{}, metrics)
 	assert.NoError(t, err)
@@ -92,3 +137,127 @@ func TestInMemoryStore_Delete(t *testing.T) {
 	assert.Error(t, err)
 	assert.True(t, IsNotFound(err))
 }
+
+func TestInMemoryStore_List(t *testing.T) {
+	ctx := context.TODO()
+	s, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+
+	for _, ref := range []DataReference{"s3://container/a/1", "s3://container/a/2", "s3://container/a/3", "s3://container/b/1"} {
+		assert.NoError(t, s.WriteRaw(ctx, ref, 0, Options{}, bytes.NewReader([]byte{})))
+	}
+
+	t.Run("Paginated", func(t *testing.T) {
+		refs, cursor, err := s.List(ctx, "s3://container/a", NewCursorAtStart(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"s3://container/a/1", "s3://container/a/2"}, refs)
+		assert.False(t, IsCursorEnd(cursor))
+
+		refs, cursor, err = s.List(ctx, "s3://container/a", cursor, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"s3://container/a/3"}, refs)
+		assert.True(t, IsCursorEnd(cursor))
+	})
+
+	t.Run("No matches", func(t *testing.T) {
+		refs, cursor, err := s.List(ctx, "s3://container/c", NewCursorAtStart(), 2)
+		assert.NoError(t, err)
+		assert.Empty(t, refs)
+		assert.True(t, IsCursorEnd(cursor))
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		_, _, err := s.List(ctx, "s3://container/a", NewCursorAtStart(), -1)
+		assert.Error(t, err)
+	})
+}
+
+func TestInMemoryStore_OpenWriter(t *testing.T) {
+	ctx := context.TODO()
+	s, err := NewInMemoryRawStore(ctx, &Config{
+		MultipartUpload: MultipartUploadConfig{PartSizeMegabytes: 1, Concurrency: 2},
+	}, metrics)
+	assert.NoError(t, err)
+
+	data := bytes.Repeat([]byte("abc"), int(MiB))
+	w, err := s.OpenWriter(ctx, "hello", Options{})
+	assert.NoError(t, err)
+	_, err = w.Write(data)
+	assert.NoError(t, err)
+
+	metadata, err := s.Head(ctx, "hello")
+	assert.NoError(t, err)
+	assert.False(t, metadata.Exists())
+
+	assert.NoError(t, w.Close())
+	rc, err := s.ReadRaw(ctx, "hello")
+	assert.NoError(t, err)
+	actual, err := ioutil.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, data, actual)
+}
+
+func TestInMemoryStore_ConditionalWrite(t *testing.T) {
+	ctx := context.TODO()
+	write := func(s RawStore, data string, opts Options) error {
+		return s.WriteRaw(ctx, "hello", int64(len(data)), opts, bytes.NewReader([]byte(data)))
+	}
+
+	t.Run("If not exists", func(t *testing.T) {
+		s, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+		assert.NoError(t, err)
+		assert.NoError(t, write(s, "a", Options{IfNotExists: true}))
+		assert.True(t, IsPreconditionFailed(write(s, "b", Options{IfNotExists: true})))
+
+		w, err := s.OpenWriter(ctx, "hello", Options{IfNotExists: true})
+		assert.NoError(t, err)
+		_, err = w.Write([]byte("c"))
+		assert.NoError(t, err)
+		assert.True(t, IsPreconditionFailed(w.Close()))
+
+		rc, err := s.ReadRaw(ctx, "hello")
+		assert.NoError(t, err)
+		actual, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", string(actual))
+	})
+
+	t.Run("If match", func(t *testing.T) {
+		s, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+		assert.NoError(t, err)
+		assert.True(t, IsPreconditionFailed(write(s, "a", Options{IfMatch: "etag"})))
+		assert.NoError(t, write(s, "a", Options{}))
+
+		metadata, err := s.Head(ctx, "hello")
+		assert.NoError(t, err)
+		assert.NoError(t, write(s, "b", Options{IfMatch: metadata.Etag()}))
+		assert.True(t, IsPreconditionFailed(write(s, "c", Options{IfMatch: metadata.Etag()})))
+	})
+
+	t.Run("Concurrent writers", func(t *testing.T) {
+		s, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+		assert.NoError(t, err)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 10)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = write(s, fmt.Sprint(i), Options{IfNotExists: true})
+			}(i)
+		}
+
+		wg.Wait()
+		succeeded := 0
+		for _, err := range errs {
+			if err == nil {
+				succeeded++
+			} else {
+				assert.True(t, IsPreconditionFailed(err))
+			}
+		}
+
+		assert.Equal(t, 1, succeeded)
+	})
+}
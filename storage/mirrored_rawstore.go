@@ -0,0 +1,345 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/flyteorg/flytestdlib/logger"
+	"github.com/flyteorg/flytestdlib/promutils"
+	"github.com/flyteorg/flytestdlib/promutils/labeled"
+)
+
+// MirrorMode defines how writes are replicated to the secondary backend of a mirrored store.
+type MirrorMode = string
+
+const (
+	// MirrorModeSync replicates writes before returning. Writes fail if either backend fails.
+	MirrorModeSync MirrorMode = "sync"
+	// MirrorModeAsync replicates writes in the background once they succeed on the primary.
+	MirrorModeAsync MirrorMode = "async"
+)
+
+// MirrorRepairHook is called with references that exist in the primary but are missing from the secondary backend.
+type MirrorRepairHook func(ctx context.Context, reference DataReference) error
+
+type mirrorMetrics struct {
+	ReplicationLag      promutils.StopWatch
+	ReplicationFailures prometheus.Counter
+	Failovers           labeled.Counter
+	Repairs             prometheus.Counter
+	RepairFailures      prometheus.Counter
+}
+
+// mirroredRawStore writes every object to a primary and a secondary backend. Reads are served from the primary and
+// fail over to the secondary if the primary fails or doesn't have the object. Writes are replicated by reading the
+// object back from the primary, so readers passed to WriteRaw are only consumed once. Asynchronous replications and
+// repairs run in the background, Close waits for them to finish.
+type mirroredRawStore struct {
+	RawStore
+	secondary    RawStore
+	async        bool
+	repairOnRead bool
+	repair       MirrorRepairHook
+	metrics      *mirrorMetrics
+
+	// lock guards closed, background work is tracked in pending until the store is closed.
+	lock    sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+// replicate copies the referenced object from the primary to the secondary backend.
+func (s *mirroredRawStore) replicate(ctx context.Context, reference DataReference) error {
+	md, err := s.RawStore.Head(ctx, reference)
+	if err != nil {
+		return err
+	}
+
+	if !md.Exists() {
+		return fmt.Errorf("can't replicate [%v], it doesn't exist in the primary store", reference)
+	}
+
+	rc, err := s.RawStore.ReadRaw(ctx, reference)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := rc.Close(); err != nil {
+			logger.Warnf(ctx, "Failed to close reference [%v]. Error: %v", reference, err)
+		}
+	}()
+
+	opts := Options{}
+	if userMetadata := md.UserMetadata(); len(userMetadata) > 0 {
+		opts.Metadata = make(map[string]interface{}, len(userMetadata))
+		for k, v := range userMetadata {
+			opts.Metadata[k] = v
+		}
+	}
+
+	return s.secondary.WriteRaw(ctx, reference, md.Size(), opts, rc)
+}
+
+// afterWrite replicates a reference that was just written to the primary according to the replication mode.
+func (s *mirroredRawStore) afterWrite(ctx context.Context, reference DataReference, fn func(ctx context.Context) error) error {
+	lag := s.metrics.ReplicationLag.Start()
+	do := func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			s.metrics.ReplicationFailures.Inc()
+			logger.Errorf(ctx, "Failed to replicate [%v] to the secondary store. Error: %v", reference, err)
+			return err
+		}
+
+		lag.Stop()
+		return nil
+	}
+
+	if !s.async {
+		return do(ctx)
+	}
+
+	// The write has already returned so replication must not be cancelled with the caller's context.
+	s.background(func(ctx context.Context) {
+		_ = do(ctx)
+	})
+
+	return nil
+}
+
+// background runs fn in the background and tracks it until it's done. Once the store is closed, fn runs before
+// returning instead so that no work outlives Close.
+func (s *mirroredRawStore) background(fn func(ctx context.Context)) {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		fn(context.Background())
+		return
+	}
+
+	s.pending.Add(1)
+	s.lock.Unlock()
+	go func() {
+		defer s.pending.Done()
+		fn(context.Background())
+	}()
+}
+
+// Wait blocks until all background replications and repairs started so far are done.
+func (s *mirroredRawStore) Wait() {
+	s.pending.Wait()
+}
+
+// Close waits for all background replications and repairs to finish. Calls made after the store is closed replicate
+// and repair before returning.
+func (s *mirroredRawStore) Close() error {
+	s.lock.Lock()
+	s.closed = true
+	s.lock.Unlock()
+
+	s.Wait()
+	return nil
+}
+
+func (s *mirroredRawStore) shouldFailover(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == nil
+}
+
+// Head returns the metadata from the primary, or the secondary if the primary fails or doesn't have the object.
+func (s *mirroredRawStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+	md, err := s.RawStore.Head(ctx, reference)
+	if err == nil && md.Exists() {
+		return md, nil
+	} else if err != nil && !s.shouldFailover(ctx, err) {
+		return nil, err
+	}
+
+	secondaryMd, secondaryErr := s.secondary.Head(ctx, reference)
+	if secondaryErr != nil || !secondaryMd.Exists() {
+		return md, err
+	}
+
+	s.metrics.Failovers.Inc(context.WithValue(ctx, OperationLabel, "head"))
+	return secondaryMd, nil
+}
+
+// ReadRaw reads the object from the primary, or the secondary if the primary fails.
+func (s *mirroredRawStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	rc, err := s.RawStore.ReadRaw(ctx, reference)
+	if err == nil {
+		s.checkSecondary(ctx, reference)
+		return rc, nil
+	}
+
+	if !s.shouldFailover(ctx, err) {
+		return nil, err
+	}
+
+	rc, secondaryErr := s.secondary.ReadRaw(ctx, reference)
+	if secondaryErr != nil {
+		return nil, err
+	}
+
+	logger.Warnf(ctx, "Read [%v] from the secondary store. Primary store error: %v", reference, err)
+	s.metrics.Failovers.Inc(context.WithValue(ctx, OperationLabel, "read"))
+	return rc, nil
+}
+
+// ReadRawRange reads the range from the primary, or the secondary if the primary fails.
+func (s *mirroredRawStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	rc, err := s.RawStore.ReadRawRange(ctx, reference, offset, length)
+	if err == nil || !s.shouldFailover(ctx, err) {
+		return rc, err
+	}
+
+	rc, secondaryErr := s.secondary.ReadRawRange(ctx, reference, offset, length)
+	if secondaryErr != nil {
+		return nil, err
+	}
+
+	logger.Warnf(ctx, "Read range of [%v] from the secondary store. Primary store error: %v", reference, err)
+	s.metrics.Failovers.Inc(context.WithValue(ctx, OperationLabel, "read_range"))
+	return rc, nil
+}
+
+// checkSecondary calls the repair hook in the background if reference is missing from the secondary and
+// RepairOnRead is enabled.
+func (s *mirroredRawStore) checkSecondary(ctx context.Context, reference DataReference) {
+	if !s.repairOnRead {
+		return
+	}
+
+	s.background(func(ctx context.Context) {
+		md, err := s.secondary.Head(ctx, reference)
+		if err != nil || md.Exists() {
+			return
+		}
+
+		logger.Infof(ctx, "Repairing [%v], it's missing from the secondary store.", reference)
+		if err = s.repair(ctx, reference); err != nil {
+			s.metrics.RepairFailures.Inc()
+			logger.Errorf(ctx, "Failed to repair [%v]. Error: %v", reference, err)
+			return
+		}
+
+		s.metrics.Repairs.Inc()
+	})
+}
+
+// WriteRaw writes the object to the primary and replicates it to the secondary.
+func (s *mirroredRawStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	if err := s.RawStore.WriteRaw(ctx, reference, size, opts, raw); err != nil {
+		return err
+	}
+
+	return s.afterWrite(ctx, reference, func(ctx context.Context) error {
+		return s.replicate(ctx, reference)
+	})
+}
+
+// OpenWriter opens a writer to the primary. The object is replicated to the secondary when the writer is closed.
+func (s *mirroredRawStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	w, err := s.RawStore.OpenWriter(ctx, reference, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mirroringWriter{WriteCloser: w, ctx: ctx, reference: reference, store: s}, nil
+}
+
+// CopyRaw copies the object in both backends. If the source is missing from the secondary, the destination is
+// replicated from the primary instead.
+func (s *mirroredRawStore) CopyRaw(ctx context.Context, source, destination DataReference, opts Options) error {
+	if err := s.RawStore.CopyRaw(ctx, source, destination, opts); err != nil {
+		return err
+	}
+
+	return s.afterWrite(ctx, destination, func(ctx context.Context) error {
+		if err := s.secondary.CopyRaw(ctx, source, destination, opts); err != nil {
+			logger.Debugf(ctx, "Failed to copy [%v] in the secondary store, replicating it instead. Error: %v",
+				source, err)
+			return s.replicate(ctx, destination)
+		}
+
+		return nil
+	})
+}
+
+// Delete deletes the object from both backends. Objects missing from the secondary are ignored.
+func (s *mirroredRawStore) Delete(ctx context.Context, reference DataReference) error {
+	if err := s.RawStore.Delete(ctx, reference); err != nil {
+		return err
+	}
+
+	return s.afterWrite(ctx, reference, func(ctx context.Context) error {
+		if err := s.secondary.Delete(ctx, reference); err != nil && !IsNotFound(err) {
+			return err
+		}
+
+		return nil
+	})
+}
+
+type mirroringWriter struct {
+	io.WriteCloser
+	ctx       context.Context
+	reference DataReference
+	store     *mirroredRawStore
+}
+
+func (w *mirroringWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+
+	return w.store.afterWrite(w.ctx, w.reference, func(ctx context.Context) error {
+		return w.store.replicate(ctx, w.reference)
+	})
+}
+
+func newMirrorMetrics(scope promutils.Scope) *mirrorMetrics {
+	return &mirrorMetrics{
+		ReplicationLag: scope.MustNewStopWatch("replication_lag",
+			"Time between an object being written to the primary and replicated to the secondary", time.Millisecond),
+		ReplicationFailures: scope.MustNewCounter("replication_failures",
+			"Number of writes that failed to replicate to the secondary"),
+		Failovers: labeled.NewCounter("failovers", "Number of reads served by the secondary", scope,
+			labeled.EmitUnlabeledMetric, labeled.AdditionalLabelsOption{Labels: []string{OperationLabel.String()}}),
+		Repairs:        scope.MustNewCounter("repairs", "Number of objects repaired in the secondary"),
+		RepairFailures: scope.MustNewCounter("repair_failures", "Number of objects that failed to be repaired"),
+	}
+}
+
+// NewMirroredRawStore creates a RawStore that mirrors objects written to primary to secondary according to cfg.
+// Secondary in cfg is ignored. If repair is nil, objects missing from the secondary are replicated from the primary.
+func NewMirroredRawStore(primary, secondary RawStore, cfg MirrorConfig, repair MirrorRepairHook, scope promutils.Scope) (
+	RawStore, error) {
+	return newMirroredRawStore(primary, secondary, cfg, repair, newMirrorMetrics(scope))
+}
+
+func newMirroredRawStore(primary, secondary RawStore, cfg MirrorConfig, repair MirrorRepairHook, metrics *mirrorMetrics) (
+	*mirroredRawStore, error) {
+	if cfg.Mode != MirrorModeSync && cfg.Mode != MirrorModeAsync {
+		return nil, fmt.Errorf("mirror mode is of an invalid value [%v]", cfg.Mode)
+	}
+
+	s := &mirroredRawStore{
+		RawStore:     primary,
+		secondary:    secondary,
+		async:        cfg.Mode == MirrorModeAsync,
+		repairOnRead: cfg.RepairOnRead,
+		repair:       repair,
+		metrics:      metrics,
+	}
+
+	if s.repair == nil {
+		s.repair = s.replicate
+	}
+
+	return s, nil
+}
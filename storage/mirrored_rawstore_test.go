@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+// unavailableStore fails every read as if the backend were down.
+type unavailableStore struct {
+	RawStore
+}
+
+var errUnavailable = fmt.Errorf("unavailable")
+
+func (s unavailableStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+	return nil, errUnavailable
+}
+
+func (s unavailableStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	return nil, errUnavailable
+}
+
+func (s unavailableStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	return nil, errUnavailable
+}
+
+func TestNewMirroredRawStore(t *testing.T) {
+	_, err := NewMirroredRawStore(nil, nil, MirrorConfig{Mode: "bad"}, nil, promutils.NewTestScope())
+	assert.Error(t, err)
+
+	s, err := NewDataStore(&Config{
+		Type: TypeMemory,
+		Mirror: MirrorConfig{
+			Mode:      MirrorModeSync,
+			Secondary: &Config{Type: TypeMemory},
+		},
+	}, promutils.NewTestScope())
+	assert.NoError(t, err)
+	assert.NoError(t, s.WriteRaw(context.TODO(), "mem://container/a", 5, Options{}, bytes.NewReader([]byte("hello"))))
+}
+
+func TestMirroredRawStore(t *testing.T) {
+	ctx := context.TODO()
+	write := func(t *testing.T, store RawStore, reference DataReference, data string) {
+		assert.NoError(t, store.WriteRaw(ctx, reference, int64(len(data)), Options{}, bytes.NewReader([]byte(data))))
+	}
+
+	for _, mode := range []MirrorMode{MirrorModeSync, MirrorModeAsync} {
+		t.Run(mode, func(t *testing.T) {
+			primary, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+			assert.NoError(t, err)
+			secondary, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+			assert.NoError(t, err)
+			store, err := newMirroredRawStore(primary, secondary, MirrorConfig{Mode: mode}, nil,
+				newMirrorMetrics(promutils.NewTestScope()))
+			assert.NoError(t, err)
+
+			write(t, store, "mem://container/a", "hello")
+			w, err := store.OpenWriter(ctx, "mem://container/b", Options{})
+			assert.NoError(t, err)
+			_, err = w.Write([]byte("world"))
+			assert.NoError(t, err)
+			assert.NoError(t, w.Close())
+			assert.NoError(t, store.CopyRaw(ctx, "mem://container/a", "mem://container/c", Options{}))
+			store.Wait()
+
+			assert.Equal(t, "hello", readString(t, secondary, "mem://container/a"))
+			assert.Equal(t, "world", readString(t, secondary, "mem://container/b"))
+			assert.Equal(t, "hello", readString(t, secondary, "mem://container/c"))
+			assert.Equal(t, 0.0, testutil.ToFloat64(store.metrics.ReplicationFailures))
+
+			assert.NoError(t, store.Delete(ctx, "mem://container/a"))
+			store.Wait()
+			md, err := secondary.Head(ctx, "mem://container/a")
+			assert.NoError(t, err)
+			assert.False(t, md.Exists())
+		})
+	}
+
+	t.Run("Fails over", func(t *testing.T) {
+		primary, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+		assert.NoError(t, err)
+		secondary, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+		assert.NoError(t, err)
+		store, err := newMirroredRawStore(primary, secondary, MirrorConfig{Mode: MirrorModeSync}, nil,
+			newMirrorMetrics(promutils.NewTestScope()))
+		assert.NoError(t, err)
+		write(t, store, "mem://container/a", "hello")
+
+		assert.NoError(t, primary.Delete(ctx, "mem://container/a"))
+		md, err := store.Head(ctx, "mem://container/a")
+		assert.NoError(t, err)
+		assert.True(t, md.Exists())
+		assert.Equal(t, "hello", readString(t, store, "mem://container/a"))
+
+		store.RawStore = unavailableStore{RawStore: primary}
+		assert.Equal(t, "hello", readString(t, store, "mem://container/a"))
+		rc, err := store.ReadRawRange(ctx, "mem://container/a", 1, 3)
+		assert.NoError(t, err)
+		raw, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, "ell", string(raw))
+
+		_, err = store.ReadRaw(ctx, "mem://container/missing")
+		assert.Equal(t, errUnavailable, err)
+	})
+
+	t.Run("Repairs on read", func(t *testing.T) {
+		var repaired []DataReference
+		lock := sync.Mutex{}
+		primary, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+		assert.NoError(t, err)
+		secondary, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+		assert.NoError(t, err)
+		store, err := newMirroredRawStore(primary, secondary, MirrorConfig{Mode: MirrorModeSync, RepairOnRead: true},
+			func(ctx context.Context, reference DataReference) error {
+				lock.Lock()
+				defer lock.Unlock()
+				repaired = append(repaired, reference)
+				return nil
+			}, newMirrorMetrics(promutils.NewTestScope()))
+		assert.NoError(t, err)
+
+		write(t, store, "mem://container/a", "hello")
+		write(t, primary, "mem://container/b", "world")
+		readString(t, store, "mem://container/a")
+		readString(t, store, "mem://container/b")
+		store.Wait()
+		assert.Equal(t, []DataReference{"mem://container/b"}, repaired)
+
+		store.repair = store.replicate
+		readString(t, store, "mem://container/b")
+		store.Wait()
+		assert.Equal(t, "world", readString(t, secondary, "mem://container/b"))
+	})
+}
+
+func TestMirroredRawStore_Close(t *testing.T) {
+	ctx := context.TODO()
+	primary, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+	secondary, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+	store, err := newMirroredRawStore(primary, secondary, MirrorConfig{Mode: MirrorModeAsync}, nil,
+		newMirrorMetrics(promutils.NewTestScope()))
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.WriteRaw(ctx, "mem://container/a", 5, Options{}, bytes.NewReader([]byte("hello"))))
+	assert.NoError(t, store.Close())
+	assert.Equal(t, "hello", readString(t, secondary, "mem://container/a"))
+
+	// Writes still in flight when the store is closed replicate before returning.
+	assert.NoError(t, store.WriteRaw(ctx, "mem://container/b", 5, Options{}, bytes.NewReader([]byte("world"))))
+	assert.Equal(t, "world", readString(t, secondary, "mem://container/b"))
+}
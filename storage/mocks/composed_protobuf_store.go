@@ -194,6 +194,177 @@ func (_m *ComposedProtobufStore) Head(ctx context.Context, reference storage.Dat
 	return r0, r1
 }
 
+type ComposedProtobufStore_List struct {
+	*mock.Call
+}
+
+func (_m ComposedProtobufStore_List) Return(_a0 []storage.DataReference, _a1 storage.Cursor, _a2 error) *ComposedProtobufStore_List {
+	return &ComposedProtobufStore_List{Call: _m.Call.Return(_a0, _a1, _a2)}
+}
+
+func (_m *ComposedProtobufStore) OnList(ctx context.Context, prefix storage.DataReference, cursor storage.Cursor, limit int) *ComposedProtobufStore_List {
+	c := _m.On("List", ctx, prefix, cursor, limit)
+	return &ComposedProtobufStore_List{Call: c}
+}
+
+func (_m *ComposedProtobufStore) OnListMatch(matchers ...interface{}) *ComposedProtobufStore_List {
+	c := _m.On("List", matchers...)
+	return &ComposedProtobufStore_List{Call: c}
+}
+
+// List provides a mock function with given fields: ctx, prefix, cursor, limit
+func (_m *ComposedProtobufStore) List(ctx context.Context, prefix storage.DataReference, cursor storage.Cursor, limit int) ([]storage.DataReference, storage.Cursor, error) {
+	ret := _m.Called(ctx, prefix, cursor, limit)
+
+	var r0 []storage.DataReference
+	if rf, ok := ret.Get(0).(func(context.Context, storage.DataReference, storage.Cursor, int) []storage.DataReference); ok {
+		r0 = rf(ctx, prefix, cursor, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.DataReference)
+		}
+	}
+
+	var r1 storage.Cursor
+	if rf, ok := ret.Get(1).(func(context.Context, storage.DataReference, storage.Cursor, int) storage.Cursor); ok {
+		r1 = rf(ctx, prefix, cursor, limit)
+	} else {
+		r1 = ret.Get(1).(storage.Cursor)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, storage.DataReference, storage.Cursor, int) error); ok {
+		r2 = rf(ctx, prefix, cursor, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+type ComposedProtobufStore_OpenProtobufStreamReader struct {
+	*mock.Call
+}
+
+func (_m ComposedProtobufStore_OpenProtobufStreamReader) Return(_a0 storage.ProtobufStreamReader, _a1 error) *ComposedProtobufStore_OpenProtobufStreamReader {
+	return &ComposedProtobufStore_OpenProtobufStreamReader{Call: _m.Call.Return(_a0, _a1)}
+}
+
+func (_m *ComposedProtobufStore) OnOpenProtobufStreamReader(ctx context.Context, reference storage.DataReference) *ComposedProtobufStore_OpenProtobufStreamReader {
+	c := _m.On("OpenProtobufStreamReader", ctx, reference)
+	return &ComposedProtobufStore_OpenProtobufStreamReader{Call: c}
+}
+
+func (_m *ComposedProtobufStore) OnOpenProtobufStreamReaderMatch(matchers ...interface{}) *ComposedProtobufStore_OpenProtobufStreamReader {
+	c := _m.On("OpenProtobufStreamReader", matchers...)
+	return &ComposedProtobufStore_OpenProtobufStreamReader{Call: c}
+}
+
+// OpenProtobufStreamReader provides a mock function with given fields: ctx, reference
+func (_m *ComposedProtobufStore) OpenProtobufStreamReader(ctx context.Context, reference storage.DataReference) (storage.ProtobufStreamReader, error) {
+	ret := _m.Called(ctx, reference)
+
+	var r0 storage.ProtobufStreamReader
+	if rf, ok := ret.Get(0).(func(context.Context, storage.DataReference) storage.ProtobufStreamReader); ok {
+		r0 = rf(ctx, reference)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(storage.ProtobufStreamReader)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.DataReference) error); ok {
+		r1 = rf(ctx, reference)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type ComposedProtobufStore_OpenProtobufStreamWriter struct {
+	*mock.Call
+}
+
+func (_m ComposedProtobufStore_OpenProtobufStreamWriter) Return(_a0 storage.ProtobufStreamWriter, _a1 error) *ComposedProtobufStore_OpenProtobufStreamWriter {
+	return &ComposedProtobufStore_OpenProtobufStreamWriter{Call: _m.Call.Return(_a0, _a1)}
+}
+
+func (_m *ComposedProtobufStore) OnOpenProtobufStreamWriter(ctx context.Context, reference storage.DataReference, opts storage.Options) *ComposedProtobufStore_OpenProtobufStreamWriter {
+	c := _m.On("OpenProtobufStreamWriter", ctx, reference, opts)
+	return &ComposedProtobufStore_OpenProtobufStreamWriter{Call: c}
+}
+
+func (_m *ComposedProtobufStore) OnOpenProtobufStreamWriterMatch(matchers ...interface{}) *ComposedProtobufStore_OpenProtobufStreamWriter {
+	c := _m.On("OpenProtobufStreamWriter", matchers...)
+	return &ComposedProtobufStore_OpenProtobufStreamWriter{Call: c}
+}
+
+// OpenProtobufStreamWriter provides a mock function with given fields: ctx, reference, opts
+func (_m *ComposedProtobufStore) OpenProtobufStreamWriter(ctx context.Context, reference storage.DataReference, opts storage.Options) (storage.ProtobufStreamWriter, error) {
+	ret := _m.Called(ctx, reference, opts)
+
+	var r0 storage.ProtobufStreamWriter
+	if rf, ok := ret.Get(0).(func(context.Context, storage.DataReference, storage.Options) storage.ProtobufStreamWriter); ok {
+		r0 = rf(ctx, reference, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(storage.ProtobufStreamWriter)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.DataReference, storage.Options) error); ok {
+		r1 = rf(ctx, reference, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type ComposedProtobufStore_OpenWriter struct {
+	*mock.Call
+}
+
+func (_m ComposedProtobufStore_OpenWriter) Return(_a0 io.WriteCloser, _a1 error) *ComposedProtobufStore_OpenWriter {
+	return &ComposedProtobufStore_OpenWriter{Call: _m.Call.Return(_a0, _a1)}
+}
+
+func (_m *ComposedProtobufStore) OnOpenWriter(ctx context.Context, reference storage.DataReference, opts storage.Options) *ComposedProtobufStore_OpenWriter {
+	c := _m.On("OpenWriter", ctx, reference, opts)
+	return &ComposedProtobufStore_OpenWriter{Call: c}
+}
+
+func (_m *ComposedProtobufStore) OnOpenWriterMatch(matchers ...interface{}) *ComposedProtobufStore_OpenWriter {
+	c := _m.On("OpenWriter", matchers...)
+	return &ComposedProtobufStore_OpenWriter{Call: c}
+}
+
+// OpenWriter provides a mock function with given fields: ctx, reference, opts
+func (_m *ComposedProtobufStore) OpenWriter(ctx context.Context, reference storage.DataReference, opts storage.Options) (io.WriteCloser, error) {
+	ret := _m.Called(ctx, reference, opts)
+
+	var r0 io.WriteCloser
+	if rf, ok := ret.Get(0).(func(context.Context, storage.DataReference, storage.Options) io.WriteCloser); ok {
+		r0 = rf(ctx, reference, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.WriteCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.DataReference, storage.Options) error); ok {
+		r1 = rf(ctx, reference, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type ComposedProtobufStore_ReadProtobuf struct {
 	*mock.Call
 }
@@ -267,6 +438,47 @@ func (_m *ComposedProtobufStore) ReadRaw(ctx context.Context, reference storage.
 	return r0, r1
 }
 
+type ComposedProtobufStore_ReadRawRange struct {
+	*mock.Call
+}
+
+func (_m ComposedProtobufStore_ReadRawRange) Return(_a0 io.ReadCloser, _a1 error) *ComposedProtobufStore_ReadRawRange {
+	return &ComposedProtobufStore_ReadRawRange{Call: _m.Call.Return(_a0, _a1)}
+}
+
+func (_m *ComposedProtobufStore) OnReadRawRange(ctx context.Context, reference storage.DataReference, offset int64, length int64) *ComposedProtobufStore_ReadRawRange {
+	c := _m.On("ReadRawRange", ctx, reference, offset, length)
+	return &ComposedProtobufStore_ReadRawRange{Call: c}
+}
+
+func (_m *ComposedProtobufStore) OnReadRawRangeMatch(matchers ...interface{}) *ComposedProtobufStore_ReadRawRange {
+	c := _m.On("ReadRawRange", matchers...)
+	return &ComposedProtobufStore_ReadRawRange{Call: c}
+}
+
+// ReadRawRange provides a mock function with given fields: ctx, reference, offset, length
+func (_m *ComposedProtobufStore) ReadRawRange(ctx context.Context, reference storage.DataReference, offset int64, length int64) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, reference, offset, length)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(context.Context, storage.DataReference, int64, int64) io.ReadCloser); ok {
+		r0 = rf(ctx, reference, offset, length)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.DataReference, int64, int64) error); ok {
+		r1 = rf(ctx, reference, offset, length)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type ComposedProtobufStore_WriteProtobuf struct {
 	*mock.Call
 }
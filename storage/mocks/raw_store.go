@@ -192,6 +192,95 @@ func (_m *RawStore) Head(ctx context.Context, reference storage.DataReference) (
 	return r0, r1
 }
 
+type RawStore_List struct {
+	*mock.Call
+}
+
+func (_m RawStore_List) Return(_a0 []storage.DataReference, _a1 storage.Cursor, _a2 error) *RawStore_List {
+	return &RawStore_List{Call: _m.Call.Return(_a0, _a1, _a2)}
+}
+
+func (_m *RawStore) OnList(ctx context.Context, prefix storage.DataReference, cursor storage.Cursor, limit int) *RawStore_List {
+	c := _m.On("List", ctx, prefix, cursor, limit)
+	return &RawStore_List{Call: c}
+}
+
+func (_m *RawStore) OnListMatch(matchers ...interface{}) *RawStore_List {
+	c := _m.On("List", matchers...)
+	return &RawStore_List{Call: c}
+}
+
+// List provides a mock function with given fields: ctx, prefix, cursor, limit
+func (_m *RawStore) List(ctx context.Context, prefix storage.DataReference, cursor storage.Cursor, limit int) ([]storage.DataReference, storage.Cursor, error) {
+	ret := _m.Called(ctx, prefix, cursor, limit)
+
+	var r0 []storage.DataReference
+	if rf, ok := ret.Get(0).(func(context.Context, storage.DataReference, storage.Cursor, int) []storage.DataReference); ok {
+		r0 = rf(ctx, prefix, cursor, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.DataReference)
+		}
+	}
+
+	var r1 storage.Cursor
+	if rf, ok := ret.Get(1).(func(context.Context, storage.DataReference, storage.Cursor, int) storage.Cursor); ok {
+		r1 = rf(ctx, prefix, cursor, limit)
+	} else {
+		r1 = ret.Get(1).(storage.Cursor)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, storage.DataReference, storage.Cursor, int) error); ok {
+		r2 = rf(ctx, prefix, cursor, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+type RawStore_OpenWriter struct {
+	*mock.Call
+}
+
+func (_m RawStore_OpenWriter) Return(_a0 io.WriteCloser, _a1 error) *RawStore_OpenWriter {
+	return &RawStore_OpenWriter{Call: _m.Call.Return(_a0, _a1)}
+}
+
+func (_m *RawStore) OnOpenWriter(ctx context.Context, reference storage.DataReference, opts storage.Options) *RawStore_OpenWriter {
+	c := _m.On("OpenWriter", ctx, reference, opts)
+	return &RawStore_OpenWriter{Call: c}
+}
+
+func (_m *RawStore) OnOpenWriterMatch(matchers ...interface{}) *RawStore_OpenWriter {
+	c := _m.On("OpenWriter", matchers...)
+	return &RawStore_OpenWriter{Call: c}
+}
+
+// OpenWriter provides a mock function with given fields: ctx, reference, opts
+func (_m *RawStore) OpenWriter(ctx context.Context, reference storage.DataReference, opts storage.Options) (io.WriteCloser, error) {
+	ret := _m.Called(ctx, reference, opts)
+
+	var r0 io.WriteCloser
+	if rf, ok := ret.Get(0).(func(context.Context, storage.DataReference, storage.Options) io.WriteCloser); ok {
+		r0 = rf(ctx, reference, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.WriteCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.DataReference, storage.Options) error); ok {
+		r1 = rf(ctx, reference, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type RawStore_ReadRaw struct {
 	*mock.Call
 }
@@ -233,6 +322,47 @@ func (_m *RawStore) ReadRaw(ctx context.Context, reference storage.DataReference
 	return r0, r1
 }
 
+type RawStore_ReadRawRange struct {
+	*mock.Call
+}
+
+func (_m RawStore_ReadRawRange) Return(_a0 io.ReadCloser, _a1 error) *RawStore_ReadRawRange {
+	return &RawStore_ReadRawRange{Call: _m.Call.Return(_a0, _a1)}
+}
+
+func (_m *RawStore) OnReadRawRange(ctx context.Context, reference storage.DataReference, offset int64, length int64) *RawStore_ReadRawRange {
+	c := _m.On("ReadRawRange", ctx, reference, offset, length)
+	return &RawStore_ReadRawRange{Call: c}
+}
+
+func (_m *RawStore) OnReadRawRangeMatch(matchers ...interface{}) *RawStore_ReadRawRange {
+	c := _m.On("ReadRawRange", matchers...)
+	return &RawStore_ReadRawRange{Call: c}
+}
+
+// ReadRawRange provides a mock function with given fields: ctx, reference, offset, length
+func (_m *RawStore) ReadRawRange(ctx context.Context, reference storage.DataReference, offset int64, length int64) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, reference, offset, length)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(context.Context, storage.DataReference, int64, int64) io.ReadCloser); ok {
+		r0 = rf(ctx, reference, offset, length)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, storage.DataReference, int64, int64) error); ok {
+		r1 = rf(ctx, reference, offset, length)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type RawStore_WriteRaw struct {
 	*mock.Call
 }
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/flyteorg/stow"
+	errs "github.com/pkg/errors"
+
+	"github.com/flyteorg/flytestdlib/logger"
+)
+
+// MultipartUpload represents an in-progress upload of a single object in independently uploaded parts.
+type MultipartUpload interface {
+	// UploadPart uploads a single part of the object. Part numbers start at 1 and parts may be uploaded concurrently
+	// and out of order.
+	UploadPart(ctx context.Context, partNumber int, raw []byte) error
+
+	// Complete assembles all uploaded parts, in part number order, into the final object.
+	Complete(ctx context.Context, partCount int) error
+
+	// Abort discards all the parts uploaded so far.
+	Abort(ctx context.Context) error
+}
+
+// MultipartContainer can be implemented by a stow.Container to allow StowStore to upload objects in parallel parts.
+// Containers that don't implement it are written by spooling the stream to a local temp file first.
+type MultipartContainer interface {
+	stow.Container
+
+	// InitiateMultipartUpload starts a new multipart upload for the named item.
+	InitiateMultipartUpload(name string, metadata map[string]interface{}) (MultipartUpload, error)
+}
+
+// multipartWriter is an io.WriteCloser that buffers written bytes into parts of a fixed size and uploads each part
+// as soon as it's full, with up to cfg.Concurrency parts in flight. If any part fails to upload, the upload is aborted
+// and the error is returned from all subsequent calls.
+type multipartWriter struct {
+	parent   context.Context
+	ctx      context.Context
+	cancel   context.CancelFunc
+	upload   MultipartUpload
+	partSize int
+	buf      []byte
+	parts    int
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+	errLock  sync.Mutex
+	err      error
+	closed   bool
+}
+
+func (w *multipartWriter) Write(p []byte) (n int, err error) {
+	if w.closed {
+		return 0, fmt.Errorf("write to a closed writer")
+	}
+
+	for len(p) > 0 {
+		if err := w.getErr(); err != nil {
+			return n, err
+		}
+
+		written := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+written]
+		p = p[written:]
+		n += written
+
+		if len(w.buf) == cap(w.buf) {
+			w.flush()
+		}
+	}
+
+	return n, nil
+}
+
+// Close uploads any remaining bytes, waits for all parts and completes the upload. If any part failed, the upload
+// is aborted instead and the failure is returned.
+func (w *multipartWriter) Close() error {
+	if w.closed {
+		return fmt.Errorf("writer is already closed")
+	}
+
+	w.closed = true
+	defer w.cancel()
+
+	// Always upload at least one (possibly empty) part so that empty objects can be written.
+	if len(w.buf) > 0 || w.parts == 0 {
+		w.flush()
+	}
+
+	w.wg.Wait()
+	if err := w.getErr(); err != nil {
+		w.abort()
+		return err
+	}
+
+	if err := w.upload.Complete(w.ctx, w.parts); err != nil {
+		w.abort()
+		return errs.Wrapf(err, "failed to complete multipart upload of [%v] parts", w.parts)
+	}
+
+	return nil
+}
+
+func (w *multipartWriter) flush() {
+	w.parts++
+	partNumber := w.parts
+	data := w.buf
+	w.buf = make([]byte, 0, w.partSize)
+
+	select {
+	case w.inFlight <- struct{}{}:
+	case <-w.ctx.Done():
+		w.setErr(w.ctx.Err())
+		return
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() { <-w.inFlight }()
+
+		if err := w.upload.UploadPart(w.ctx, partNumber, data); err != nil {
+			w.setErr(errs.Wrapf(err, "failed to upload part [%v]", partNumber))
+		}
+	}()
+}
+
+func (w *multipartWriter) abort() {
+	// The writer's own context is cancelled on the first failure, so clean up using the parent context unless
+	// that's been cancelled as well.
+	ctx := w.parent
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+
+	if err := w.upload.Abort(ctx); err != nil {
+		logger.Warnf(ctx, "Failed to abort multipart upload. Error: %v", err)
+	}
+}
+
+func (w *multipartWriter) getErr() error {
+	w.errLock.Lock()
+	defer w.errLock.Unlock()
+	if w.err == nil && w.ctx.Err() != nil {
+		w.err = w.ctx.Err()
+	}
+
+	return w.err
+}
+
+func (w *multipartWriter) setErr(err error) {
+	w.errLock.Lock()
+	defer w.errLock.Unlock()
+	if w.err == nil {
+		w.err = err
+		// Stop any other in-flight parts early, the upload will be aborted anyway.
+		w.cancel()
+	}
+}
+
+func newMultipartWriter(ctx context.Context, upload MultipartUpload, cfg MultipartUploadConfig) *multipartWriter {
+	partSize := int(cfg.PartSizeMegabytes * MiB)
+	if partSize <= 0 {
+		partSize = int(defaultConfig.MultipartUpload.PartSizeMegabytes * MiB)
+	}
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConfig.MultipartUpload.Concurrency
+	}
+
+	childCtx, cancel := context.WithCancel(ctx)
+	return &multipartWriter{
+		parent:   ctx,
+		ctx:      childCtx,
+		cancel:   cancel,
+		upload:   upload,
+		partSize: partSize,
+		buf:      make([]byte, 0, partSize),
+		inFlight: make(chan struct{}, concurrency),
+	}
+}
+
+// spoolingWriter is an io.WriteCloser that buffers all written bytes in a local temp file and writes them in a single
+// call once closed. It's used for backends that can only accept an object with a known size. Nothing is written if ctx
+// is done by the time the writer is closed.
+type spoolingWriter struct {
+	ctx   context.Context
+	file  *os.File
+	size  int64
+	write func(size int64, raw io.Reader) error
+}
+
+func (w *spoolingWriter) Write(p []byte) (n int, err error) {
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *spoolingWriter) Close() error {
+	defer func() {
+		if err := w.file.Close(); err != nil {
+			logger.Warnf(context.TODO(), "Failed to close spool file [%v]. Error: %v", w.file.Name(), err)
+		}
+
+		if err := os.Remove(w.file.Name()); err != nil {
+			logger.Warnf(context.TODO(), "Failed to remove spool file [%v]. Error: %v", w.file.Name(), err)
+		}
+	}()
+
+	if err := w.ctx.Err(); err != nil {
+		return errs.Wrap(err, "spooled write aborted")
+	}
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return w.write(w.size, w.file)
+}
+
+func newSpoolingWriter(ctx context.Context, write func(size int64, raw io.Reader) error) (*spoolingWriter, error) {
+	f, err := ioutil.TempFile("", "flytestdlib_spool")
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to create spool file")
+	}
+
+	return &spoolingWriter{
+		ctx:   ctx,
+		file:  f,
+		write: write,
+	}, nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMultipartUpload struct {
+	lock        sync.Mutex
+	parts       map[int][]byte
+	inFlight    int
+	maxInFlight int
+	failPart    int
+	completed   bool
+	aborted     bool
+	unblock     chan struct{}
+}
+
+func (f *fakeMultipartUpload) UploadPart(ctx context.Context, partNumber int, raw []byte) error {
+	f.lock.Lock()
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.lock.Unlock()
+
+	if f.unblock != nil {
+		<-f.unblock
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.inFlight--
+	if partNumber == f.failPart {
+		return fmt.Errorf("failed to upload part")
+	}
+
+	f.parts[partNumber] = append([]byte{}, raw...)
+	return nil
+}
+
+func (f *fakeMultipartUpload) Complete(ctx context.Context, partCount int) error {
+	f.completed = true
+	return nil
+}
+
+func (f *fakeMultipartUpload) Abort(ctx context.Context) error {
+	f.aborted = true
+	return nil
+}
+
+func TestMultipartWriter(t *testing.T) {
+	ctx := context.TODO()
+	cfg := MultipartUploadConfig{PartSizeMegabytes: 1, Concurrency: 2}
+
+	t.Run("Splits into parts", func(t *testing.T) {
+		upload := &fakeMultipartUpload{parts: map[int][]byte{}}
+		w := newMultipartWriter(ctx, upload, cfg)
+		data := bytes.Repeat([]byte("a"), int(2*MiB+10))
+		n, err := w.Write(data)
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+		assert.NoError(t, w.Close())
+
+		assert.True(t, upload.completed)
+		assert.False(t, upload.aborted)
+		assert.Len(t, upload.parts, 3)
+		assert.Equal(t, int(MiB), len(upload.parts[1]))
+		assert.Equal(t, int(MiB), len(upload.parts[2]))
+		assert.Equal(t, 10, len(upload.parts[3]))
+	})
+
+	t.Run("Empty object", func(t *testing.T) {
+		upload := &fakeMultipartUpload{parts: map[int][]byte{}}
+		w := newMultipartWriter(ctx, upload, cfg)
+		assert.NoError(t, w.Close())
+		assert.True(t, upload.completed)
+		assert.Len(t, upload.parts, 1)
+	})
+
+	t.Run("Bounded concurrency", func(t *testing.T) {
+		upload := &fakeMultipartUpload{parts: map[int][]byte{}, unblock: make(chan struct{})}
+		w := newMultipartWriter(ctx, upload, cfg)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, err := w.Write(bytes.Repeat([]byte("a"), int(5*MiB)))
+			assert.NoError(t, err)
+			assert.NoError(t, w.Close())
+		}()
+
+		assert.Eventually(t, func() bool {
+			upload.lock.Lock()
+			defer upload.lock.Unlock()
+			return upload.inFlight == 2
+		}, time.Second, time.Millisecond)
+
+		for i := 0; i < 5; i++ {
+			upload.unblock <- struct{}{}
+		}
+
+		<-done
+		assert.True(t, upload.completed)
+		assert.Equal(t, 2, upload.maxInFlight)
+	})
+
+	t.Run("Aborts on failure", func(t *testing.T) {
+		upload := &fakeMultipartUpload{parts: map[int][]byte{}, failPart: 1}
+		w := newMultipartWriter(ctx, upload, cfg)
+		_, err := w.Write(bytes.Repeat([]byte("a"), int(MiB+1)))
+		assert.NoError(t, err)
+		assert.Error(t, w.Close())
+		assert.False(t, upload.completed)
+		assert.True(t, upload.aborted)
+	})
+
+	t.Run("Aborts on cancellation", func(t *testing.T) {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		upload := &fakeMultipartUpload{parts: map[int][]byte{}}
+		w := newMultipartWriter(cancelCtx, upload, cfg)
+		cancel()
+		_, err := w.Write([]byte("a"))
+		assert.Error(t, err)
+		assert.Error(t, w.Close())
+		assert.False(t, upload.completed)
+		assert.True(t, upload.aborted)
+	})
+
+	t.Run("Closed writer", func(t *testing.T) {
+		upload := &fakeMultipartUpload{parts: map[int][]byte{}}
+		w := newMultipartWriter(ctx, upload, cfg)
+		assert.NoError(t, w.Close())
+		_, err := w.Write([]byte("a"))
+		assert.Error(t, err)
+		assert.Error(t, w.Close())
+	})
+}
+
+func TestSpoolingWriter(t *testing.T) {
+	var written []byte
+	w, err := newSpoolingWriter(context.TODO(), func(size int64, raw io.Reader) error {
+		var err error
+		written, err = ioutil.ReadAll(raw)
+		assert.Equal(t, int64(len(written)), size)
+		return err
+	})
+	assert.NoError(t, err)
+
+	_, err = w.Write([]byte("hello "))
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("world"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "hello world", string(written))
+}
+
+func TestSpoolingWriter_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	written := false
+	w, err := newSpoolingWriter(ctx, func(size int64, raw io.Reader) error {
+		written = true
+		return nil
+	})
+	assert.NoError(t, err)
+
+	_, err = w.Write([]byte("hello"))
+	assert.NoError(t, err)
+	cancel()
+	assert.ErrorIs(t, w.Close(), context.Canceled)
+	assert.False(t, written)
+}
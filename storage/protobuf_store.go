@@ -23,12 +23,23 @@ type protoMetrics struct {
 	UnmarshalFailure             prometheus.Counter
 	WriteFailureUnrelatedToCache prometheus.Counter
 	ReadFailureUnrelatedToCache  prometheus.Counter
+	CompressFailure              prometheus.Counter
+	DecompressFailure            prometheus.Counter
+	ChecksumMismatch             prometheus.Counter
 }
 
 // Implements ProtobufStore to marshal and unmarshal protobufs to/from a RawStore
 type DefaultProtobufStore struct {
 	RawStore
 	metrics *protoMetrics
+	// compressor compresses written protobufs. If nil, protobufs are written uncompressed.
+	compressor compressor
+	// wireFormat is the format protobufs are written in unless overridden through Options.WireFormat. Defaults to
+	// binary if empty.
+	wireFormat WireFormat
+	// maxDecompressedSize limits the size of read protobufs once decompressed, and of each message of read protobuf
+	// streams. If 0, there is no limit.
+	maxDecompressedSize int64
 }
 
 func (s DefaultProtobufStore) ReadProtobuf(ctx context.Context, reference DataReference, msg proto.Message) error {
@@ -47,12 +58,21 @@ func (s DefaultProtobufStore) ReadProtobuf(ctx context.Context, reference DataRe
 	}()
 
 	docContents, err := ioutils.ReadAll(rc, s.metrics.FetchLatency.Start())
-	if err != nil {
+	if IsChecksumMismatch(err) {
+		logger.Errorf(ctx, "Protobuf [%v] is corrupted. Error: %v", reference, err)
+		return errs.Wrap(err, fmt.Sprintf("checksum: %v", reference))
+	} else if err != nil {
 		return errs.Wrap(err, fmt.Sprintf("readAll: %v", reference))
 	}
 
+	docContents, err = decompress(docContents, s.maxDecompressedSize)
+	if err != nil {
+		s.metrics.DecompressFailure.Inc()
+		return errs.Wrap(err, fmt.Sprintf("decompress: %v", reference))
+	}
+
 	t := s.metrics.UnmarshalTime.Start()
-	err = proto.Unmarshal(docContents, msg)
+	err = unmarshalSniffed(docContents, msg)
 	t.Stop()
 	if err != nil {
 		s.metrics.UnmarshalFailure.Inc()
@@ -62,15 +82,44 @@ func (s DefaultProtobufStore) ReadProtobuf(ctx context.Context, reference DataRe
 	return nil
 }
 
+// WriteProtobuf writes msg in the wire format of opts, or of the store if opts doesn't set one. Formats other than
+// binary are recorded as the content type of the object for other readers, ReadProtobuf detects the format from the
+// contents regardless.
 func (s DefaultProtobufStore) WriteProtobuf(ctx context.Context, reference DataReference, opts Options, msg proto.Message) error {
+	format := opts.WireFormat
+	if len(format) == 0 {
+		format = s.wireFormat
+	}
+
+	f, err := getWireFormat(format)
+	if err != nil {
+		return err
+	}
+
 	t := s.metrics.MarshalTime.Start()
-	raw, err := proto.Marshal(msg)
+	raw, err := f.marshal(msg)
 	t.Stop()
 	if err != nil {
 		s.metrics.MarshalFailure.Inc()
 		return err
 	}
 
+	if len(f.contentType) > 0 {
+		metadata := make(map[string]interface{}, len(opts.Metadata)+1)
+		for k, v := range opts.Metadata {
+			metadata[k] = v
+		}
+
+		metadata[MetadataKeyContentType] = f.contentType
+		opts.Metadata = metadata
+	}
+
+	raw, err = compress(s.compressor, raw)
+	if err != nil {
+		s.metrics.CompressFailure.Inc()
+		return errs.Wrap(err, fmt.Sprintf("compress: %v", reference))
+	}
+
 	err = s.WriteRaw(ctx, reference, int64(len(raw)), opts, bytes.NewReader(raw))
 	if err != nil && !IsFailedWriteToCache(err) {
 		logger.Errorf(ctx, "Failed to write to the raw store [%s] Error: %v", reference, err)
@@ -89,6 +138,9 @@ func newProtoMetrics(scope promutils.Scope) *protoMetrics {
 		UnmarshalFailure:             scope.MustNewCounter("unmarshal_failure", "Failures when unmarshalling"),
 		WriteFailureUnrelatedToCache: scope.MustNewCounter("write_failure_unrelated_to_cache", "Raw store write failures that are not caused by ErrFailedToWriteCache"),
 		ReadFailureUnrelatedToCache:  scope.MustNewCounter("read_failure_unrelated_to_cache", "Raw store read failures that are not caused by ErrFailedToWriteCache"),
+		CompressFailure:              scope.MustNewCounter("compress_failure", "Failures when compressing data before writing"),
+		DecompressFailure:            scope.MustNewCounter("decompress_failure", "Failures when decompressing read data"),
+		ChecksumMismatch:             scope.MustNewCounter("checksum_mismatch", "Reads whose data didn't match the checksum recorded when written"),
 	}
 }
 
@@ -102,3 +154,34 @@ func NewDefaultProtobufStoreWithMetrics(store RawStore, metrics *protoMetrics) D
 		metrics:  metrics,
 	}
 }
+
+// NewDefaultProtobufStoreFromConfig creates a DefaultProtobufStore that applies the protobuf related settings (e.g.
+// compression, checksums and wire format) of the supplied config.
+func NewDefaultProtobufStoreFromConfig(store RawStore, cfg *Config, scope promutils.Scope) (DefaultProtobufStore, error) {
+	metrics := newProtoMetrics(scope)
+	store, err := newChecksummingRawStore(cfg.Checksum.Algorithm, store, metrics.ChecksumMismatch)
+	if err != nil {
+		return DefaultProtobufStore{}, err
+	}
+
+	return newDefaultProtobufStoreFromConfig(store, cfg, metrics)
+}
+
+// newDefaultProtobufStoreFromConfig applies the protobuf related settings of cfg except checksums, which DataStores
+// verify below their in-memory cache.
+func newDefaultProtobufStoreFromConfig(store RawStore, cfg *Config, metrics *protoMetrics) (DefaultProtobufStore, error) {
+	c, err := getCompressor(cfg.Compression.Codec)
+	if err != nil {
+		return DefaultProtobufStore{}, err
+	}
+
+	if _, err = getWireFormat(cfg.Serialization.Format); err != nil {
+		return DefaultProtobufStore{}, err
+	}
+
+	protoStore := NewDefaultProtobufStoreWithMetrics(store, metrics)
+	protoStore.compressor = c
+	protoStore.wireFormat = cfg.Serialization.Format
+	protoStore.maxDecompressedSize = cfg.Limits.GetLimitMegabytes * MiB
+	return protoStore, nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -65,8 +66,10 @@ func TestDefaultProtobufStore(t *testing.T) {
 		testScope := promutils.NewTestScope()
 		s, err := NewDataStore(&Config{Type: TypeMemory}, testScope)
 		require.NoError(t, err)
-		require.IsType(t, DefaultProtobufStore{}, s.ComposedProtobufStore)
-		require.IsType(t, &InMemoryStore{}, s.ComposedProtobufStore.(DefaultProtobufStore).RawStore)
+		require.IsType(t, &reloadableStore{}, s.ComposedProtobufStore)
+		store := s.ComposedProtobufStore.(*reloadableStore)
+		require.IsType(t, DefaultProtobufStore{}, store.current())
+		require.IsType(t, &InMemoryStore{}, store.current().(DefaultProtobufStore).RawStore)
 
 		oldMetrics := s.metrics
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -86,8 +89,9 @@ func TestDefaultProtobufStore(t *testing.T) {
 			InitContainer: "b"})
 
 		assert.NoError(t, err)
-		require.IsType(t, DefaultProtobufStore{}, s.ComposedProtobufStore)
-		assert.IsType(t, &StowStore{}, s.ComposedProtobufStore.(DefaultProtobufStore).RawStore)
+		assert.Same(t, store, s.ComposedProtobufStore)
+		require.IsType(t, DefaultProtobufStore{}, store.current())
+		assert.IsType(t, &StowStore{}, store.current().(DefaultProtobufStore).RawStore)
 		assert.Equal(t, oldMetrics, s.metrics)
 	})
 
@@ -140,6 +144,58 @@ func TestDefaultProtobufStore_BigDataReadAfterWrite(t *testing.T) {
 	})
 }
 
+func TestDefaultProtobufStore_Compression(t *testing.T) {
+	ctx := context.TODO()
+	rawStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+
+	uncompressedStore, err := newDefaultProtobufStoreFromConfig(rawStore, &Config{}, metrics.protoMetrics)
+	assert.NoError(t, err)
+
+	for _, codec := range []CompressionCodec{CompressionGzip, CompressionZstd, CompressionSnappy} {
+		t.Run(codec, func(t *testing.T) {
+			s, err := newDefaultProtobufStoreFromConfig(rawStore, &Config{
+				Compression: CompressionConfig{Codec: codec},
+			}, metrics.protoMetrics)
+			assert.NoError(t, err)
+
+			bigD := bytes.Repeat([]byte("a"), 1024)
+			assert.NoError(t, s.WriteProtobuf(ctx, "compressed", Options{}, &mockBigDataProtoMessage{X: bigD}))
+
+			metadata, err := s.Head(ctx, "compressed")
+			assert.NoError(t, err)
+			assert.Less(t, metadata.Size(), int64(len(bigD)))
+
+			m := &mockBigDataProtoMessage{}
+			assert.NoError(t, uncompressedStore.ReadProtobuf(ctx, "compressed", m))
+			assert.Equal(t, bigD, m.X)
+
+			assert.NoError(t, uncompressedStore.WriteProtobuf(ctx, "uncompressed", Options{}, &mockProtoMessage{X: 5}))
+			m2 := &mockProtoMessage{}
+			assert.NoError(t, s.ReadProtobuf(ctx, "uncompressed", m2))
+			assert.Equal(t, int64(5), m2.X)
+		})
+	}
+
+	t.Run("Exceeds limit once decompressed", func(t *testing.T) {
+		s, err := newDefaultProtobufStoreFromConfig(rawStore, &Config{
+			Compression: CompressionConfig{Codec: CompressionZstd},
+			Limits:      LimitsConfig{GetLimitMegabytes: 1},
+		}, metrics.protoMetrics)
+		assert.NoError(t, err)
+
+		bigD := bytes.Repeat([]byte("a"), int(2*MiB))
+		assert.NoError(t, s.WriteProtobuf(ctx, "big", Options{}, &mockBigDataProtoMessage{X: bigD}))
+		err = s.ReadProtobuf(ctx, "big", &mockBigDataProtoMessage{})
+		assert.True(t, IsExceedsLimit(err))
+	})
+
+	t.Run("Unsupported codec", func(t *testing.T) {
+		_, err := NewDataStore(&Config{Type: TypeMemory, Compression: CompressionConfig{Codec: "lz4"}}, promutils.NewTestScope())
+		assert.Error(t, err)
+	})
+}
+
 func TestDefaultProtobufStore_HardErrors(t *testing.T) {
 	ctx := context.TODO()
 	k1 := DataReference("k1")
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	errs "github.com/pkg/errors"
+
+	stdErrs "github.com/flyteorg/flytestdlib/errors"
+	"github.com/flyteorg/flytestdlib/logger"
+)
+
+// ProtobufStreamWriter appends protobufs to a single object as a stream of length-delimited messages: each message is
+// prefixed with its size encoded as a varint, like Java's writeDelimitedTo does.
+type ProtobufStreamWriter interface {
+	// Write appends msg to the stream. Once writing to the underlying object fails, the stream is aborted and every
+	// subsequent call fails.
+	Write(msg proto.Message) error
+
+	// Close commits the stream. The messages are only guaranteed to be stored once Close returns without an error.
+	Close() error
+}
+
+// ProtobufStreamReader iterates over a stream of length-delimited protobufs written by a ProtobufStreamWriter.
+type ProtobufStreamReader interface {
+	// Next unmarshals the next message of the stream into msg. It returns io.EOF once all messages are read.
+	Next(msg proto.Message) error
+
+	Close() error
+}
+
+type protobufStreamWriter struct {
+	reference DataReference
+	w         io.WriteCloser
+	cancel    context.CancelFunc
+	metrics   *protoMetrics
+	// err is the error that aborted the stream.
+	err    error
+	length [binary.MaxVarintLen64]byte
+}
+
+func (w *protobufStreamWriter) Write(msg proto.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	t := w.metrics.MarshalTime.Start()
+	raw, err := proto.Marshal(msg)
+	t.Stop()
+	if err != nil {
+		w.metrics.MarshalFailure.Inc()
+		return err
+	}
+
+	n := binary.PutUvarint(w.length[:], uint64(len(raw)))
+	if _, err = w.w.Write(w.length[:n]); err == nil {
+		_, err = w.w.Write(raw)
+	}
+
+	if err != nil {
+		w.metrics.WriteFailureUnrelatedToCache.Inc()
+		w.err = errs.Wrap(err, fmt.Sprintf("write: %v", w.reference))
+		// Cancelling the context aborts the upload so that the partial stream isn't committed.
+		w.cancel()
+	}
+
+	return w.err
+}
+
+func (w *protobufStreamWriter) Close() error {
+	defer w.cancel()
+	err := w.w.Close()
+	if w.err != nil {
+		return w.err
+	}
+
+	if err != nil {
+		w.metrics.WriteFailureUnrelatedToCache.Inc()
+		return errs.Wrap(err, fmt.Sprintf("close: %v", w.reference))
+	}
+
+	return nil
+}
+
+type protobufStreamReader struct {
+	reference DataReference
+	rc        io.ReadCloser
+	r         *bufio.Reader
+	// maxSize limits the size of each message. If 0, there is no limit.
+	maxSize int64
+	metrics *protoMetrics
+}
+
+func (r *protobufStreamReader) Next(msg proto.Message) error {
+	size, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return io.EOF
+	} else if err != nil {
+		return r.wrapReadError(err)
+	}
+
+	if r.maxSize > 0 && size > uint64(r.maxSize) {
+		return stdErrs.Errorf(ErrExceedsLimit, "message of %vb in [%v] exceeds limit of %vb", size, r.reference,
+			r.maxSize)
+	}
+
+	// The size isn't trusted to allocate the message upfront, so a corrupt size fails once the stream runs out of data
+	// instead of allocating it.
+	raw := bytes.Buffer{}
+	if n, err := io.Copy(&raw, io.LimitReader(r.r, int64(size))); err != nil {
+		return r.wrapReadError(err)
+	} else if uint64(n) != size {
+		return r.wrapReadError(io.ErrUnexpectedEOF)
+	}
+
+	t := r.metrics.UnmarshalTime.Start()
+	err = proto.Unmarshal(raw.Bytes(), msg)
+	t.Stop()
+	if err != nil {
+		r.metrics.UnmarshalFailure.Inc()
+		return errs.Wrap(err, fmt.Sprintf("unmarshall: %v", r.reference))
+	}
+
+	return nil
+}
+
+func (r *protobufStreamReader) wrapReadError(err error) error {
+	if IsChecksumMismatch(err) {
+		return errs.Wrap(err, fmt.Sprintf("checksum: %v", r.reference))
+	}
+
+	return errs.Wrap(err, fmt.Sprintf("read: %v", r.reference))
+}
+
+func (r *protobufStreamReader) Close() error {
+	return r.rc.Close()
+}
+
+// OpenProtobufStreamWriter opens a writer that streams length-delimited protobufs to the referenced object through
+// OpenWriter. Messages in streams are never compressed.
+func (s DefaultProtobufStore) OpenProtobufStreamWriter(ctx context.Context, reference DataReference, opts Options) (
+	ProtobufStreamWriter, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	w, err := s.OpenWriter(ctx, reference, opts)
+	if err != nil {
+		cancel()
+		logger.Errorf(ctx, "Failed to open a writer to the raw store [%s] Error: %v", reference, err)
+		s.metrics.WriteFailureUnrelatedToCache.Inc()
+		return nil, err
+	}
+
+	return &protobufStreamWriter{
+		reference: reference,
+		w:         w,
+		cancel:    cancel,
+		metrics:   s.metrics,
+	}, nil
+}
+
+// OpenProtobufStreamReader opens the referenced stream of length-delimited protobufs for reading. The download limit
+// applies to each message rather than to the whole object, unless a store has to buffer the whole object (e.g. to
+// decrypt it). Streams aren't cached.
+func (s DefaultProtobufStore) OpenProtobufStreamReader(ctx context.Context, reference DataReference) (
+	ProtobufStreamReader, error) {
+
+	rc, err := s.ReadRaw(withoutDownloadLimit(ctx), reference)
+	if err != nil && !IsFailedWriteToCache(err) {
+		logger.Errorf(ctx, "Failed to read from the raw store [%s] Error: %v", reference, err)
+		s.metrics.ReadFailureUnrelatedToCache.Inc()
+		return nil, errs.Wrap(err, fmt.Sprintf("path:%v", reference))
+	}
+
+	return &protobufStreamReader{
+		reference: reference,
+		rc:        rc,
+		r:         bufio.NewReader(rc),
+		maxSize:   s.maxDecompressedSize,
+		metrics:   s.metrics,
+	}, nil
+}
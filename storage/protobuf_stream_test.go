@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+type failingWriteCloser struct {
+	closed bool
+}
+
+func (w *failingWriteCloser) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("failed")
+}
+
+func (w *failingWriteCloser) Close() error {
+	w.closed = true
+	return nil
+}
+
+// limitRecordingStore records whether the last read skipped the download limit.
+type limitRecordingStore struct {
+	RawStore
+	skippedLimit bool
+}
+
+func (s *limitRecordingStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	s.skippedLimit = skipsDownloadLimit(ctx)
+	return s.RawStore.ReadRaw(ctx, reference)
+}
+
+func readStream(t *testing.T, s ComposedProtobufStore, reference DataReference) ([]int64, error) {
+	r, err := s.OpenProtobufStreamReader(context.TODO(), reference)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, r.Close())
+	}()
+
+	var values []int64
+	for {
+		m := &mockProtoMessage{}
+		if err := r.Next(m); err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return values, err
+		}
+
+		values = append(values, m.X)
+	}
+}
+
+func TestDefaultProtobufStore_ProtobufStream(t *testing.T) {
+	ctx := context.TODO()
+	s, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+	require.NoError(t, err)
+
+	t.Run("Round trip", func(t *testing.T) {
+		w, err := s.OpenProtobufStreamWriter(ctx, "mem://container/events", Options{})
+		require.NoError(t, err)
+		for _, x := range []int64{1, 0, 300} {
+			assert.NoError(t, w.Write(&mockProtoMessage{X: x}))
+		}
+
+		assert.NoError(t, w.Close())
+		values, err := readStream(t, s, "mem://container/events")
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{1, 0, 300}, values)
+	})
+
+	t.Run("Empty stream", func(t *testing.T) {
+		w, err := s.OpenProtobufStreamWriter(ctx, "mem://container/empty", Options{})
+		require.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		values, err := readStream(t, s, "mem://container/empty")
+		assert.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("Truncated stream", func(t *testing.T) {
+		assert.NoError(t, s.WriteRaw(ctx, "mem://container/truncated", 4, Options{},
+			bytes.NewReader([]byte{0x02, 0x10, 0x05, 0x02})))
+
+		values, err := readStream(t, s, "mem://container/truncated")
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, []int64{5}, values)
+	})
+
+	t.Run("Corrupt size", func(t *testing.T) {
+		for name, size := range map[string]uint64{"Oversized": 1 << 62, "Overflowing": math.MaxUint64} {
+			t.Run(name, func(t *testing.T) {
+				raw := binary.AppendUvarint(nil, size)
+				raw = append(raw, 0x10, 0x05)
+				assert.NoError(t, s.WriteRaw(ctx, "mem://container/corrupt", int64(len(raw)), Options{},
+					bytes.NewReader(raw)))
+
+				values, err := readStream(t, s, "mem://container/corrupt")
+				assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+				assert.Empty(t, values)
+			})
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := s.OpenProtobufStreamReader(ctx, "mem://container/missing")
+		assert.True(t, IsNotFound(err), err)
+	})
+}
+
+func TestDefaultProtobufStore_ProtobufStreamLimit(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	require.NoError(t, err)
+	s := NewDefaultProtobufStoreWithMetrics(memStore, metrics.protoMetrics)
+	s.maxDecompressedSize = 2
+
+	w, err := s.OpenProtobufStreamWriter(ctx, "mem://container/events", Options{})
+	require.NoError(t, err)
+	assert.NoError(t, w.Write(&mockProtoMessage{X: 5}))
+	assert.NoError(t, w.Write(&mockProtoMessage{X: 300}))
+	assert.NoError(t, w.Close())
+
+	values, err := readStream(t, s, "mem://container/events")
+	assert.True(t, IsExceedsLimit(err), err)
+	assert.Equal(t, []int64{5}, values)
+}
+
+func TestDefaultProtobufStore_ProtobufStreamBuffering(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	require.NoError(t, err)
+	backend := &limitRecordingStore{RawStore: memStore}
+
+	t.Run("Bypasses the cache", func(t *testing.T) {
+		cStore := newCachedRawStore(&Config{Cache: CachingConfig{MaxSizeMegabytes: 1}}, backend, metrics.cacheMetrics)
+		s := NewDefaultProtobufStoreWithMetrics(cStore, metrics.protoMetrics)
+		w, err := s.OpenProtobufStreamWriter(ctx, "mem://container/events", Options{})
+		require.NoError(t, err)
+		assert.NoError(t, w.Write(&mockProtoMessage{X: 5}))
+		assert.NoError(t, w.Close())
+
+		values, err := readStream(t, s, "mem://container/events")
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{5}, values)
+		assert.True(t, backend.skippedLimit)
+		_, err = cStore.(*cachedRawStore).cache.Get([]byte("mem://container/events"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Limits encrypted streams", func(t *testing.T) {
+		t.Setenv("STDLIB_TEST_KEY", testEncryptionKey)
+		store, err := newEncryptingRawStore(ctx, &Config{
+			Encryption: EncryptionConfig{
+				Enabled:     true,
+				KeyProvider: KeyProviderEnv,
+				KeyID:       "key1",
+				KeyEnvVar:   "STDLIB_TEST_KEY",
+			},
+		}, backend, metrics.encryptionMetrics)
+		require.NoError(t, err)
+
+		s := NewDefaultProtobufStoreWithMetrics(store, metrics.protoMetrics)
+		w, err := s.OpenProtobufStreamWriter(ctx, "mem://container/encrypted", Options{})
+		require.NoError(t, err)
+		assert.NoError(t, w.Write(&mockProtoMessage{X: 5}))
+		assert.NoError(t, w.Close())
+
+		values, err := readStream(t, s, "mem://container/encrypted")
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{5}, values)
+		assert.False(t, backend.skippedLimit)
+	})
+}
+
+func TestDefaultProtobufStore_ProtobufStreamWriteFailure(t *testing.T) {
+	writer := &failingWriteCloser{}
+	var writerCtx context.Context
+	s := NewDefaultProtobufStoreWithMetrics(&dummyStore{
+		OpenWriterCb: func(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+			writerCtx = ctx
+			return writer, nil
+		},
+	}, metrics.protoMetrics)
+
+	w, err := s.OpenProtobufStreamWriter(context.TODO(), "dummy/events", Options{})
+	require.NoError(t, err)
+	assert.Error(t, w.Write(&mockProtoMessage{X: 5}))
+	assert.Error(t, writerCtx.Err())
+	assert.Error(t, w.Write(&mockProtoMessage{X: 6}))
+	assert.Error(t, w.Close())
+	assert.True(t, writer.closed)
+}
+
+func TestCheckDownloadLimit(t *testing.T) {
+	ctx := context.TODO()
+	size := (GetConfig().Limits.GetLimitMegabytes + 1) * MiB
+	assert.True(t, IsExceedsLimit(checkDownloadLimit(ctx, size)))
+	assert.NoError(t, checkDownloadLimit(withoutDownloadLimit(ctx), size))
+	assert.True(t, IsExceedsLimit(checkDownloadLimit(withDownloadLimit(withoutDownloadLimit(ctx)), size)))
+}
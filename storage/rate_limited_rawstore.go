@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+	"github.com/flyteorg/flytestdlib/promutils/labeled"
+	"github.com/flyteorg/flytestdlib/utils"
+)
+
+type rateLimitMetrics struct {
+	QueueWait labeled.StopWatch
+}
+
+// limiter enforces one group of limits. Nil fields disable the respective limit.
+type limiter struct {
+	inFlight chan struct{}
+	rate     utils.RateLimiter
+}
+
+// acquire waits until a request is allowed and returns a function that must be called once it's done. If ctx is done
+// first, its error is returned.
+func (l limiter) acquire(ctx context.Context) (release func(), err error) {
+	release = func() {}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+			release = func() { <-l.inFlight }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if l.rate != nil {
+		if err = l.rate.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+func newLimiter(name string, maxInFlight int, requestsPerSecond float64, burst int) limiter {
+	l := limiter{}
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+
+	if requestsPerSecond > 0 {
+		if burst < 1 {
+			burst = 1
+		}
+
+		l.rate = utils.NewRateLimiter(name, requestsPerSecond, burst)
+	}
+
+	return l
+}
+
+// rateLimitedRawStore throttles the requests sent to the underlying store so that they stay within the configured
+// limits. Requests wait for the limits of their operation type, then for those shared by all operations. Only the
+// calls themselves count as in flight: readers returned by ReadRaw are not limited, and neither are the parts uploaded
+// by writers returned by OpenWriter.
+type rateLimitedRawStore struct {
+	RawStore
+	shared     limiter
+	operations map[string]limiter
+	metrics    *rateLimitMetrics
+}
+
+// Head gets metadata about the reference once the limits allow it.
+func (s *rateLimitedRawStore) Head(ctx context.Context, reference DataReference) (md Metadata, err error) {
+	err = s.do(ctx, "head", func() error {
+		md, err = s.RawStore.Head(ctx, reference)
+		return err
+	})
+
+	return md, err
+}
+
+// ReadRaw opens the referenced object for reading once the limits allow it.
+func (s *rateLimitedRawStore) ReadRaw(ctx context.Context, reference DataReference) (rc io.ReadCloser, err error) {
+	err = s.do(ctx, "read", func() error {
+		rc, err = s.RawStore.ReadRaw(ctx, reference)
+		return err
+	})
+
+	return rc, err
+}
+
+// ReadRawRange opens a range of the referenced object for reading once the limits allow it.
+func (s *rateLimitedRawStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (
+	rc io.ReadCloser, err error) {
+	err = s.do(ctx, "read_range", func() error {
+		rc, err = s.RawStore.ReadRawRange(ctx, reference, offset, length)
+		return err
+	})
+
+	return rc, err
+}
+
+// WriteRaw stores the raw data once the limits allow it.
+func (s *rateLimitedRawStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	return s.do(ctx, "write", func() error {
+		return s.RawStore.WriteRaw(ctx, reference, size, opts, raw)
+	})
+}
+
+// OpenWriter opens a writer to the referenced location once the limits allow it.
+func (s *rateLimitedRawStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (
+	w io.WriteCloser, err error) {
+	err = s.do(ctx, "open_writer", func() error {
+		w, err = s.RawStore.OpenWriter(ctx, reference, opts)
+		return err
+	})
+
+	return w, err
+}
+
+// CopyRaw copies source to destination once the limits allow it.
+func (s *rateLimitedRawStore) CopyRaw(ctx context.Context, source, destination DataReference, opts Options) error {
+	return s.do(ctx, "copy", func() error {
+		return s.RawStore.CopyRaw(ctx, source, destination, opts)
+	})
+}
+
+// Delete removes the referenced object once the limits allow it.
+func (s *rateLimitedRawStore) Delete(ctx context.Context, reference DataReference) error {
+	return s.do(ctx, "delete", func() error {
+		return s.RawStore.Delete(ctx, reference)
+	})
+}
+
+// List lists the references under prefix once the limits allow it.
+func (s *rateLimitedRawStore) List(ctx context.Context, prefix DataReference, cursor Cursor, limit int) (
+	refs []DataReference, next Cursor, err error) {
+	err = s.do(ctx, "list", func() error {
+		refs, next, err = s.RawStore.List(ctx, prefix, cursor, limit)
+		return err
+	})
+
+	return refs, next, err
+}
+
+// do waits for the limits of operation, then for the shared ones, recording the time spent waiting, and invokes fn.
+// Waiting for the limits of the operation first avoids holding a shared slot while it's throttled. If ctx is done
+// while waiting, fn isn't invoked and the error of ctx is returned.
+func (s *rateLimitedRawStore) do(ctx context.Context, operation string, fn func() error) error {
+	ctx = context.WithValue(ctx, OperationLabel, operation)
+	t := s.metrics.QueueWait.Start(ctx)
+	releaseOperation, err := s.operations[operation].acquire(ctx)
+	if err != nil {
+		t.Stop()
+		return err
+	}
+
+	defer releaseOperation()
+	releaseShared, err := s.shared.acquire(ctx)
+	t.Stop()
+	if err != nil {
+		return err
+	}
+
+	defer releaseShared()
+	return fn()
+}
+
+func newRateLimitMetrics(scope promutils.Scope) *rateLimitMetrics {
+	operationOption := labeled.AdditionalLabelsOption{Labels: []string{OperationLabel.String()}}
+	return &rateLimitMetrics{
+		QueueWait: labeled.NewStopWatch("queue_wait", "Time requests waited for the rate limits before being sent",
+			time.Millisecond, scope, labeled.EmitUnlabeledMetric, operationOption),
+	}
+}
+
+// newRateLimitedRawStore wraps store so that requests to it are limited according to the rate limits config. If no
+// limit is configured, store is returned as is.
+func newRateLimitedRawStore(cfg *Config, store RawStore, metrics *rateLimitMetrics) (RawStore, error) {
+	limits := cfg.RateLimits
+	for operation := range limits.Operations {
+		if !contains(rawStoreOperations, operation) {
+			return nil, fmt.Errorf("rate limits operation is of an invalid value [%v], must be one of %v", operation,
+				rawStoreOperations)
+		}
+	}
+
+	if limits.MaxInFlight <= 0 && limits.RequestsPerSecond <= 0 && len(limits.Operations) == 0 {
+		return store, nil
+	}
+
+	operations := make(map[string]limiter, len(limits.Operations))
+	for operation, l := range limits.Operations {
+		operations[operation] = newLimiter(fmt.Sprintf("storage_%v", operation), l.MaxInFlight, l.RequestsPerSecond,
+			l.Burst)
+	}
+
+	return &rateLimitedRawStore{
+		RawStore:   store,
+		shared:     newLimiter("storage", limits.MaxInFlight, limits.RequestsPerSecond, limits.Burst),
+		operations: operations,
+		metrics:    metrics,
+	}, nil
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+// blockingStore blocks Head calls until unblocked and records the highest number of calls in flight.
+type blockingStore struct {
+	RawStore
+	unblock     chan struct{}
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+	calls       int
+}
+
+func (s *blockingStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+	s.lock.Lock()
+	s.calls++
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.lock.Unlock()
+
+	<-s.unblock
+
+	s.lock.Lock()
+	s.inFlight--
+	s.lock.Unlock()
+	return MemoryMetadata{}, nil
+}
+
+func (s *blockingStore) stats() (inFlight, maxInFlight, calls int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.inFlight, s.maxInFlight, s.calls
+}
+
+func TestRateLimitedRawStore_MaxInFlight(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	require.NoError(t, err)
+
+	blocking := &blockingStore{RawStore: memStore, unblock: make(chan struct{})}
+	store, err := newRateLimitedRawStore(&Config{RateLimits: RateLimitsConfig{MaxInFlight: 2}}, blocking,
+		metrics.rateLimitMetrics)
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Head(ctx, "mem://container/a")
+			assert.NoError(t, err)
+		}()
+	}
+
+	assert.Eventually(t, func() bool {
+		inFlight, _, _ := blocking.stats()
+		return inFlight == 2
+	}, time.Second, time.Millisecond)
+
+	close(blocking.unblock)
+	wg.Wait()
+	_, maxInFlight, calls := blocking.stats()
+	assert.Equal(t, 2, maxInFlight)
+	assert.Equal(t, 5, calls)
+}
+
+func TestRateLimitedRawStore_Operations(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	require.NoError(t, err)
+
+	blocking := &blockingStore{RawStore: memStore, unblock: make(chan struct{})}
+	store, err := newRateLimitedRawStore(&Config{RateLimits: RateLimitsConfig{
+		Operations: map[string]RateLimitConfig{
+			"head": {MaxInFlight: 1},
+		},
+	}}, blocking, metrics.rateLimitMetrics)
+	require.NoError(t, err)
+
+	go func() {
+		_, err := store.Head(ctx, "mem://container/a")
+		assert.NoError(t, err)
+	}()
+
+	assert.Eventually(t, func() bool {
+		inFlight, _, _ := blocking.stats()
+		return inFlight == 1
+	}, time.Second, time.Millisecond)
+
+	t.Run("Other operations aren't limited", func(t *testing.T) {
+		_, _, err := store.List(ctx, "mem://container/", NewCursorAtStart(), 10)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Cancelled while waiting", func(t *testing.T) {
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := store.Head(timeoutCtx, "mem://container/a")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		_, _, calls := blocking.stats()
+		assert.Equal(t, 1, calls)
+	})
+
+	close(blocking.unblock)
+}
+
+func TestRateLimitedRawStore_RequestsPerSecond(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	require.NoError(t, err)
+
+	blocking := &blockingStore{RawStore: memStore, unblock: make(chan struct{})}
+	store, err := newRateLimitedRawStore(&Config{RateLimits: RateLimitsConfig{RequestsPerSecond: 50, Burst: 2}}, blocking,
+		metrics.rateLimitMetrics)
+	require.NoError(t, err)
+	close(blocking.unblock)
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		_, err := store.Head(ctx, "mem://container/a")
+		assert.NoError(t, err)
+	}
+
+	// The burst allows the first 2 requests right away, the remaining 4 are spaced 20ms apart.
+	assert.GreaterOrEqual(t, time.Since(start), 70*time.Millisecond)
+}
+
+func TestNewRateLimitedRawStore(t *testing.T) {
+	memStore, err := NewInMemoryRawStore(context.TODO(), &Config{}, metrics)
+	require.NoError(t, err)
+
+	t.Run("Disabled", func(t *testing.T) {
+		store, err := newRateLimitedRawStore(&Config{}, memStore, metrics.rateLimitMetrics)
+		assert.NoError(t, err)
+		assert.Same(t, memStore, store)
+	})
+
+	t.Run("Invalid operation", func(t *testing.T) {
+		_, err := newRateLimitedRawStore(&Config{RateLimits: RateLimitsConfig{
+			Operations: map[string]RateLimitConfig{"upload": {MaxInFlight: 1}},
+		}}, memStore, metrics.rateLimitMetrics)
+		assert.Error(t, err)
+	})
+
+	t.Run("DataStore", func(t *testing.T) {
+		s, err := NewDataStore(&Config{
+			Type:       TypeMemory,
+			RateLimits: RateLimitsConfig{MaxInFlight: 1, RequestsPerSecond: 1000},
+		}, promutils.NewTestScope())
+		require.NoError(t, err)
+
+		assert.NoError(t, s.WriteProtobuf(context.TODO(), "mem://container/a", Options{}, &mockProtoMessage{X: 5}))
+		m := &mockProtoMessage{}
+		assert.NoError(t, s.ReadProtobuf(context.TODO(), "mem://container/a", m))
+		assert.Equal(t, int64(5), m.X)
+	})
+}
@@ -3,8 +3,10 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 
+	"github.com/flyteorg/flytestdlib/logger"
 	"github.com/flyteorg/flytestdlib/promutils"
 )
 
@@ -40,6 +42,15 @@ func applyDefaultHeaders(r *http.Request, headers map[string][]string) {
 	}
 }
 
+// newHTTPClient creates a client that applies cfg, or returns nil if cfg doesn't differ from the default client.
+func newHTTPClient(cfg HTTPClientConfig) *http.Client {
+	if cfg.Timeout.Duration == 0 && len(cfg.Headers) == 0 {
+		return nil
+	}
+
+	return createHTTPClient(cfg)
+}
+
 func createHTTPClient(cfg HTTPClientConfig) *http.Client {
 	c := &http.Client{
 		Timeout: cfg.Timeout.Duration,
@@ -56,19 +67,29 @@ func createHTTPClient(cfg HTTPClientConfig) *http.Client {
 }
 
 type dataStoreMetrics struct {
-	cacheMetrics *cacheMetrics
-	protoMetrics *protoMetrics
-	copyMetrics  *copyMetrics
-	stowMetrics  *stowMetrics
+	cacheMetrics      *cacheMetrics
+	protoMetrics      *protoMetrics
+	copyMetrics       *copyMetrics
+	stowMetrics       *stowMetrics
+	encryptionMetrics *encryptionMetrics
+	retryMetrics      *retryMetrics
+	rateLimitMetrics  *rateLimitMetrics
+	mirrorMetrics     *mirrorMetrics
+	faultMetrics      *faultMetrics
 }
 
 // newDataStoreMetrics initialises all metrics required for DataStore
 func newDataStoreMetrics(scope promutils.Scope) *dataStoreMetrics {
 	return &dataStoreMetrics{
-		cacheMetrics: newCacheMetrics(scope),
-		protoMetrics: newProtoMetrics(scope),
-		copyMetrics:  newCopyMetrics(scope.NewSubScope("copy")),
-		stowMetrics:  newStowMetrics(scope),
+		cacheMetrics:      newCacheMetrics(scope),
+		protoMetrics:      newProtoMetrics(scope),
+		copyMetrics:       newCopyMetrics(scope.NewSubScope("copy")),
+		stowMetrics:       newStowMetrics(scope),
+		encryptionMetrics: newEncryptionMetrics(scope.NewSubScope("encryption")),
+		retryMetrics:      newRetryMetrics(scope.NewSubScope("retry")),
+		rateLimitMetrics:  newRateLimitMetrics(scope.NewSubScope("rate_limit")),
+		mirrorMetrics:     newMirrorMetrics(scope.NewSubScope("mirror")),
+		faultMetrics:      newFaultMetrics(scope.NewSubScope("faults")),
 	}
 }
 
@@ -91,30 +112,121 @@ func NewCompositeDataStore(refConstructor ReferenceConstructor, composedProtobuf
 	}
 }
 
-// RefreshConfig re-initialises the data store client leaving metrics untouched.
-// This is NOT thread-safe!
-func (ds *DataStore) RefreshConfig(ctx context.Context, cfg *Config) error {
-	defaultClient := http.DefaultClient
-	defer func() {
-		http.DefaultClient = defaultClient
-	}()
-
-	http.DefaultClient = createHTTPClient(cfg.DefaultHTTPClient)
-
+// newBackendRawStore creates the RawStore of the backend in cfg, limiting requests according to its rate limits config
+// and retrying failed operations according to its retry config. Retries are subject to the rate limits too.
+func newBackendRawStore(ctx context.Context, cfg *Config, metrics *dataStoreMetrics) (RawStore, error) {
 	fn, found := stores[cfg.Type]
 	if !found {
-		return fmt.Errorf("type is of an invalid value [%v]", cfg.Type)
+		return nil, fmt.Errorf("type is of an invalid value [%v]", cfg.Type)
 	}
 
-	rawStore, err := fn(ctx, cfg, ds.metrics)
+	rawStore, err := fn(ctx, cfg, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	rawStore, err = newRateLimitedRawStore(cfg, rawStore, metrics.rateLimitMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRetryingRawStore(cfg, rawStore, metrics.retryMetrics), nil
+}
+
+// RefreshConfig re-initialises the data store client leaving metrics untouched. Once a DataStore created with
+// NewDataStore is in use, the store is swapped atomically: calls in flight finish with the previous config and
+// subsequent calls use the new one. If the new config is invalid, the previous one stays in use. Background work of the
+// previous store is drained before returning. The reference constructor is only configured the first time, changes to
+// its config are logged and ignored afterwards.
+func (ds *DataStore) RefreshConfig(ctx context.Context, cfg *Config) error {
+	ds.refreshLock.Lock()
+	defer ds.refreshLock.Unlock()
+
+	protoStore, closer, err := newComposedProtobufStore(ctx, cfg, ds.metrics)
+	if err != nil {
+		return err
+	}
+
+	refConstructor, err := newReferenceConstructor(cfg.ReferenceConstructor)
 	if err != nil {
 		return err
 	}
 
-	rawStore = newCachedRawStore(cfg, rawStore, ds.metrics.cacheMetrics)
-	protoStore := NewDefaultProtobufStoreWithMetrics(rawStore, ds.metrics.protoMetrics)
-	newDS := NewCompositeDataStore(NewURLPathConstructor(), protoStore)
-	newDS.metrics = ds.metrics
-	*ds = *newDS
+	if reloadable, ok := ds.ComposedProtobufStore.(*reloadableStore); ok {
+		if cfg.ReferenceConstructor != ds.refConstructorCfg {
+			logger.Warnf(ctx, "Ignoring the changed reference constructor config [%+v], the DataStore keeps using [%+v].",
+				cfg.ReferenceConstructor, ds.refConstructorCfg)
+		}
+
+		previous := reloadable.swap(protoStore, closer)
+		if previous.closer != nil {
+			// Let the background work of the previous store (e.g. asynchronous mirroring) finish, calls still in flight
+			// on it do their work before returning.
+			if err = previous.closer.Close(); err != nil {
+				logger.Warnf(ctx, "Failed to close the previous store. Error: %v", err)
+			}
+		}
+
+		return nil
+	}
+
+	ds.ComposedProtobufStore = newReloadableStore(protoStore, closer)
+	ds.ReferenceConstructor = refConstructor
+	ds.refConstructorCfg = cfg.ReferenceConstructor
 	return nil
 }
+
+// newComposedProtobufStore creates the store described by cfg, with all the configured decorators, for a DataStore.
+// If the store runs work in the background, it also returns a closer that waits for it to finish.
+func newComposedProtobufStore(ctx context.Context, cfg *Config, metrics *dataStoreMetrics) (
+	protoStore ComposedProtobufStore, closer io.Closer, err error) {
+
+	rawStore, err := newBackendRawStore(ctx, cfg, metrics)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Mirror.Secondary != nil {
+		secondary, err := newBackendRawStore(ctx, cfg.Mirror.Secondary, metrics)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create secondary store. Error: %w", err)
+		}
+
+		mirrored, err := newMirroredRawStore(rawStore, secondary, cfg.Mirror, nil, metrics.mirrorMetrics)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rawStore, closer = mirrored, mirrored
+	}
+
+	rawStore, err = newRoutingRawStore(ctx, cfg, rawStore, metrics)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The disk cache sits below encryption so that objects are cached on disk encrypted.
+	rawStore, err = newDiskCachedRawStore(cfg, rawStore, metrics.cacheMetrics)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawStore, err = newEncryptingRawStore(ctx, cfg, rawStore, metrics.encryptionMetrics)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Checksums are verified below the in-memory cache so that cache hits don't need to look up the recorded checksum.
+	rawStore, err = newChecksummingRawStore(cfg.Checksum.Algorithm, rawStore, metrics.protoMetrics.ChecksumMismatch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawStore = newCachedRawStore(cfg, rawStore, metrics.cacheMetrics)
+	protoStore, err = newDefaultProtobufStoreFromConfig(rawStore, cfg, metrics.protoMetrics)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return protoStore, closer, nil
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/flyteorg/flytestdlib/logger"
+)
+
+type storeHolder struct {
+	store ComposedProtobufStore
+	// closer waits for the background work of store to finish, if it runs any.
+	closer io.Closer
+}
+
+// reloadableStore is a ComposedProtobufStore whose underlying store can be swapped while it's in use. Every call is
+// served entirely by the store that was current when it started, so in-flight calls are never affected by a swap.
+type reloadableStore struct {
+	value atomic.Value
+}
+
+func (s *reloadableStore) current() ComposedProtobufStore {
+	return s.value.Load().(storeHolder).store
+}
+
+// swap makes store current and returns the holder of the previous one, which is empty on the first call.
+func (s *reloadableStore) swap(store ComposedProtobufStore, closer io.Closer) storeHolder {
+	previous, _ := s.value.Swap(storeHolder{store: store, closer: closer}).(storeHolder)
+	return previous
+}
+
+func (s *reloadableStore) GetBaseContainerFQN(ctx context.Context) DataReference {
+	return s.current().GetBaseContainerFQN(ctx)
+}
+
+func (s *reloadableStore) CreateSignedURL(ctx context.Context, reference DataReference, properties SignedURLProperties) (SignedURLResponse, error) {
+	return s.current().CreateSignedURL(ctx, reference, properties)
+}
+
+func (s *reloadableStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+	return s.current().Head(ctx, reference)
+}
+
+func (s *reloadableStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	return s.current().ReadRaw(ctx, reference)
+}
+
+func (s *reloadableStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	return s.current().ReadRawRange(ctx, reference, offset, length)
+}
+
+func (s *reloadableStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	return s.current().WriteRaw(ctx, reference, size, opts, raw)
+}
+
+func (s *reloadableStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	return s.current().OpenWriter(ctx, reference, opts)
+}
+
+func (s *reloadableStore) CopyRaw(ctx context.Context, source, destination DataReference, opts Options) error {
+	return s.current().CopyRaw(ctx, source, destination, opts)
+}
+
+func (s *reloadableStore) Delete(ctx context.Context, reference DataReference) error {
+	return s.current().Delete(ctx, reference)
+}
+
+func (s *reloadableStore) List(ctx context.Context, prefix DataReference, cursor Cursor, limit int) ([]DataReference, Cursor, error) {
+	return s.current().List(ctx, prefix, cursor, limit)
+}
+
+func (s *reloadableStore) ReadProtobuf(ctx context.Context, reference DataReference, msg proto.Message) error {
+	return s.current().ReadProtobuf(ctx, reference, msg)
+}
+
+func (s *reloadableStore) WriteProtobuf(ctx context.Context, reference DataReference, opts Options, msg proto.Message) error {
+	return s.current().WriteProtobuf(ctx, reference, opts, msg)
+}
+
+func (s *reloadableStore) OpenProtobufStreamWriter(ctx context.Context, reference DataReference, opts Options) (
+	ProtobufStreamWriter, error) {
+	return s.current().OpenProtobufStreamWriter(ctx, reference, opts)
+}
+
+func (s *reloadableStore) OpenProtobufStreamReader(ctx context.Context, reference DataReference) (
+	ProtobufStreamReader, error) {
+	return s.current().OpenProtobufStreamReader(ctx, reference)
+}
+
+func newReloadableStore(store ComposedProtobufStore, closer io.Closer) *reloadableStore {
+	s := &reloadableStore{}
+	s.swap(store, closer)
+	return s
+}
+
+var (
+	subscribersLock sync.RWMutex
+	subscribers     = map[*DataStore]struct{}{}
+)
+
+// onConfigUpdated refreshes all DataStores subscribed to config updates. Stores that fail to refresh keep serving with
+// their previous config.
+func onConfigUpdated(ctx context.Context, newValue interface{}) {
+	cfg, ok := newValue.(*Config)
+	if !ok {
+		logger.Errorf(ctx, "Received a storage config update of an unexpected type [%T].", newValue)
+		return
+	}
+
+	subscribersLock.RLock()
+	defer subscribersLock.RUnlock()
+	for ds := range subscribers {
+		if err := ds.RefreshConfig(ctx, cfg); err != nil {
+			logger.Errorf(ctx, "Failed to refresh the data store with the updated storage config. Error: %v", err)
+		}
+	}
+}
+
+// SubscribeToConfigUpdates refreshes the DataStore whenever the storage config section is updated until the returned
+// function is called. The DataStore must have been created with NewDataStore.
+func (ds *DataStore) SubscribeToConfigUpdates() (unsubscribe func()) {
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	subscribers[ds] = struct{}{}
+
+	return func() {
+		subscribersLock.Lock()
+		defer subscribersLock.Unlock()
+		delete(subscribers, ds)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flyteorg/flytestdlib/config"
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+func TestDataStore_RefreshConfig_Concurrent(t *testing.T) {
+	ctx := context.TODO()
+	s, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+	require.NoError(t, err)
+	store := s.ComposedProtobufStore.(*reloadableStore)
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					assert.NoError(t, s.WriteRaw(ctx, "mem://container/a", 1, Options{}, bytes.NewReader([]byte("a"))))
+					_, err := s.Head(ctx, "mem://container/a")
+					assert.NoError(t, err)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, s.RefreshConfig(ctx, &Config{Type: TypeMemory}))
+	}
+
+	close(done)
+	wg.Wait()
+	assert.Same(t, store, s.ComposedProtobufStore)
+}
+
+func TestDataStore_RefreshConfig_Invalid(t *testing.T) {
+	s, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+	require.NoError(t, err)
+	previous := s.ComposedProtobufStore.(*reloadableStore).current()
+
+	assert.Error(t, s.RefreshConfig(context.TODO(), &Config{Type: "invalid"}))
+	assert.Same(t, previous.(DefaultProtobufStore).RawStore,
+		s.ComposedProtobufStore.(*reloadableStore).current().(DefaultProtobufStore).RawStore)
+}
+
+func TestDataStore_RefreshConfig_HTTPClient(t *testing.T) {
+	defaultClient := http.DefaultClient
+	s, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+	require.NoError(t, err)
+
+	assert.NoError(t, s.RefreshConfig(context.TODO(), &Config{
+		Type: TypeMemory,
+		DefaultHTTPClient: HTTPClientConfig{
+			Headers: map[string][]string{"k": {"v"}},
+			Timeout: config.Duration{Duration: time.Second},
+		},
+	}))
+	assert.Same(t, defaultClient, http.DefaultClient)
+}
+
+func TestDataStore_SubscribeToConfigUpdates(t *testing.T) {
+	ctx := context.TODO()
+	s, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+	require.NoError(t, err)
+	store := s.ComposedProtobufStore.(*reloadableStore)
+	previous := store.current()
+
+	unsubscribe := s.SubscribeToConfigUpdates()
+	onConfigUpdated(ctx, &Config{Type: TypeMemory})
+	updated := store.current()
+	assert.NotSame(t, previous.(DefaultProtobufStore).RawStore, updated.(DefaultProtobufStore).RawStore)
+
+	unsubscribe()
+	onConfigUpdated(ctx, &Config{Type: TypeMemory})
+	assert.Same(t, updated.(DefaultProtobufStore).RawStore, store.current().(DefaultProtobufStore).RawStore)
+}
+
+type closeRecorder struct {
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestDataStore_RefreshConfig_ClosesPrevious(t *testing.T) {
+	s, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+	assert.NoError(t, err)
+
+	store := s.ComposedProtobufStore.(*reloadableStore)
+	closer := &closeRecorder{}
+	store.swap(store.current(), closer)
+	assert.NoError(t, s.RefreshConfig(context.TODO(), &Config{
+		Type: TypeMemory,
+		Mirror: MirrorConfig{
+			Mode:      MirrorModeAsync,
+			Secondary: &Config{Type: TypeMemory},
+		},
+	}))
+
+	assert.True(t, closer.closed)
+	assert.IsType(t, &mirroredRawStore{}, store.value.Load().(storeHolder).closer)
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/flyteorg/flytestdlib/contextutils"
+	"github.com/flyteorg/flytestdlib/logger"
+	"github.com/flyteorg/flytestdlib/promutils"
+	"github.com/flyteorg/flytestdlib/promutils/labeled"
+)
+
+const (
+	OperationLabel contextutils.Key = "operation"
+)
+
+// rawStoreOperations are the values of OperationLabel, i.e. the RawStore operations decorators can be configured per.
+var rawStoreOperations = []string{"head", "read", "read_range", "write", "open_writer", "copy", "delete", "list"}
+
+type retryMetrics struct {
+	Retries   labeled.Counter
+	Exhausted labeled.Counter
+}
+
+// retryingRawStore retries operations of the underlying store that fail with retryable errors, backing off
+// exponentially (with full jitter) between attempts.
+// Writers returned by OpenWriter are not retried since the streamed data can't be replayed. Neither are WriteRaw calls
+// whose reader doesn't implement io.Seeker.
+type retryingRawStore struct {
+	RawStore
+	cfg         RetryConfig
+	isRetryable func(err error) bool
+	metrics     *retryMetrics
+}
+
+// Head gets metadata about the reference, retrying transient failures.
+func (s *retryingRawStore) Head(ctx context.Context, reference DataReference) (md Metadata, err error) {
+	err = s.do(ctx, "head", reference, func() error {
+		md, err = s.RawStore.Head(ctx, reference)
+		return err
+	})
+
+	return md, err
+}
+
+// ReadRaw opens the referenced object for reading, retrying transient failures.
+func (s *retryingRawStore) ReadRaw(ctx context.Context, reference DataReference) (rc io.ReadCloser, err error) {
+	err = s.do(ctx, "read", reference, func() error {
+		rc, err = s.RawStore.ReadRaw(ctx, reference)
+		return err
+	})
+
+	return rc, err
+}
+
+// ReadRawRange opens a range of the referenced object for reading, retrying transient failures.
+func (s *retryingRawStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (
+	rc io.ReadCloser, err error) {
+	err = s.do(ctx, "read_range", reference, func() error {
+		rc, err = s.RawStore.ReadRawRange(ctx, reference, offset, length)
+		return err
+	})
+
+	return rc, err
+}
+
+// WriteRaw stores the raw data, retrying transient failures if raw can be rewound.
+func (s *retryingRawStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	seeker, ok := raw.(io.Seeker)
+	if !ok {
+		return s.RawStore.WriteRaw(ctx, reference, size, opts, raw)
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return s.RawStore.WriteRaw(ctx, reference, size, opts, raw)
+	}
+
+	return s.do(ctx, "write", reference, func() error {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+
+		return s.RawStore.WriteRaw(ctx, reference, size, opts, raw)
+	})
+}
+
+// Delete removes the referenced object, retrying transient failures.
+func (s *retryingRawStore) Delete(ctx context.Context, reference DataReference) error {
+	return s.do(ctx, "delete", reference, func() error {
+		return s.RawStore.Delete(ctx, reference)
+	})
+}
+
+// List lists the references under prefix, retrying transient failures.
+func (s *retryingRawStore) List(ctx context.Context, prefix DataReference, cursor Cursor, limit int) (
+	refs []DataReference, next Cursor, err error) {
+	err = s.do(ctx, "list", prefix, func() error {
+		refs, next, err = s.RawStore.List(ctx, prefix, cursor, limit)
+		return err
+	})
+
+	return refs, next, err
+}
+
+// CopyRaw copies source to destination, retrying the whole copy on transient failures.
+func (s *retryingRawStore) CopyRaw(ctx context.Context, source, destination DataReference, opts Options) error {
+	return s.do(ctx, "copy", source, func() error {
+		return s.RawStore.CopyRaw(ctx, source, destination, opts)
+	})
+}
+
+// do invokes fn until it succeeds, fails with a non-retryable error, the attempts are exhausted or ctx is done. The
+// last error is returned unchanged.
+func (s *retryingRawStore) do(ctx context.Context, operation string, reference DataReference, fn func() error) error {
+	ctx = context.WithValue(ctx, OperationLabel, operation)
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !s.isRetryable(err) {
+			return err
+		}
+
+		if attempt >= s.cfg.MaxAttempts {
+			s.metrics.Exhausted.Inc(ctx)
+			return err
+		}
+
+		backoff := s.backoff(attempt)
+		logger.Warnf(ctx, "Attempt [%v/%v] to %v [%v] failed, retrying in [%v]. Error: %v", attempt,
+			s.cfg.MaxAttempts, operation, reference, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		s.metrics.Retries.Inc(ctx)
+	}
+}
+
+// backoff returns a random duration between 0 and InitialBackoff*2^(attempt-1), capped at MaxBackoff.
+func (s *retryingRawStore) backoff(attempt int) time.Duration {
+	ceiling := s.cfg.InitialBackoff.Duration
+	for i := 1; i < attempt && ceiling < s.cfg.MaxBackoff.Duration; i++ {
+		ceiling *= 2
+	}
+
+	if ceiling > s.cfg.MaxBackoff.Duration {
+		ceiling = s.cfg.MaxBackoff.Duration
+	}
+
+	if ceiling <= 0 {
+		return 0
+	}
+
+	// #nosec G404
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func newRetryMetrics(scope promutils.Scope) *retryMetrics {
+	operationOption := labeled.AdditionalLabelsOption{Labels: []string{OperationLabel.String()}}
+	return &retryMetrics{
+		Retries: labeled.NewCounter("retries", "Number of times a failed operation was retried", scope,
+			labeled.EmitUnlabeledMetric, operationOption),
+		Exhausted: labeled.NewCounter("exhausted", "Number of operations that still failed after all attempts", scope,
+			labeled.EmitUnlabeledMetric, operationOption),
+	}
+}
+
+// newRetryingRawStore wraps store so that its operations are retried according to the retry config. If retries are
+// disabled, store is returned as is.
+func newRetryingRawStore(cfg *Config, store RawStore, metrics *retryMetrics) RawStore {
+	if cfg.Retry.MaxAttempts <= 1 {
+		return store
+	}
+
+	retryCfg := cfg.Retry
+	if retryCfg.MaxBackoff.Duration < retryCfg.InitialBackoff.Duration {
+		retryCfg.MaxBackoff = retryCfg.InitialBackoff
+	}
+
+	return &retryingRawStore{
+		RawStore:    store,
+		cfg:         retryCfg,
+		isRetryable: IsRetryable,
+		metrics:     metrics,
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flyteorg/flytestdlib/config"
+)
+
+var errTransient = fmt.Errorf("transient failure")
+
+// flakyStore fails the first failures calls of every operation with err before delegating to the underlying store.
+type flakyStore struct {
+	RawStore
+	failures int
+	err      error
+	calls    map[string]int
+}
+
+func (s *flakyStore) fail(operation string) error {
+	s.calls[operation]++
+	if s.calls[operation] <= s.failures {
+		return s.err
+	}
+
+	return nil
+}
+
+func (s *flakyStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+	if err := s.fail("head"); err != nil {
+		return nil, err
+	}
+
+	return s.RawStore.Head(ctx, reference)
+}
+
+func (s *flakyStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	if err := s.fail("read"); err != nil {
+		return nil, err
+	}
+
+	return s.RawStore.ReadRaw(ctx, reference)
+}
+
+func (s *flakyStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	if err := s.fail("write"); err != nil {
+		// Consume part of the data like a failed upload would.
+		_, _ = io.CopyN(ioutil.Discard, raw, 1)
+		return err
+	}
+
+	return s.RawStore.WriteRaw(ctx, reference, size, opts, raw)
+}
+
+func (s *flakyStore) Delete(ctx context.Context, reference DataReference) error {
+	if err := s.fail("delete"); err != nil {
+		return err
+	}
+
+	return s.RawStore.Delete(ctx, reference)
+}
+
+func TestNewRetryingRawStore(t *testing.T) {
+	memStore, err := NewInMemoryRawStore(context.TODO(), &Config{}, metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, memStore, newRetryingRawStore(&Config{}, memStore, metrics.retryMetrics))
+	assert.Equal(t, memStore, newRetryingRawStore(&Config{Retry: RetryConfig{MaxAttempts: 1}}, memStore, metrics.retryMetrics))
+}
+
+func TestRetryingRawStore(t *testing.T) {
+	ctx := context.TODO()
+	data := []byte("hello world")
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+
+	flaky := &flakyStore{}
+	s := newRetryingRawStore(&Config{
+		Retry: RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: config.Duration{Duration: time.Millisecond},
+			MaxBackoff:     config.Duration{Duration: 5 * time.Millisecond},
+		},
+	}, flaky, metrics.retryMetrics)
+	assert.IsType(t, &retryingRawStore{}, s)
+
+	store := s.(*retryingRawStore)
+	store.isRetryable = func(err error) bool {
+		return err == errTransient
+	}
+
+	t.Run("Recovers from transient failures", func(t *testing.T) {
+		*flaky = flakyStore{RawStore: memStore, failures: 2, err: errTransient, calls: map[string]int{}}
+		assert.NoError(t, store.WriteRaw(ctx, "mem://container/a", int64(len(data)), Options{}, bytes.NewReader(data)))
+		assert.Equal(t, 3, flaky.calls["write"])
+
+		md, err := store.Head(ctx, "mem://container/a")
+		assert.NoError(t, err)
+		assert.True(t, md.Exists())
+		assert.Equal(t, 3, flaky.calls["head"])
+
+		rc, err := store.ReadRaw(ctx, "mem://container/a")
+		assert.NoError(t, err)
+		read, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, data, read)
+
+		assert.NoError(t, store.Delete(ctx, "mem://container/a"))
+		assert.Equal(t, 3, flaky.calls["delete"])
+	})
+
+	t.Run("Gives up after max attempts", func(t *testing.T) {
+		*flaky = flakyStore{RawStore: memStore, failures: 5, err: errTransient, calls: map[string]int{}}
+		_, err := store.Head(ctx, "mem://container/a")
+		assert.Equal(t, errTransient, err)
+		assert.Equal(t, 3, flaky.calls["head"])
+	})
+
+	t.Run("Doesn't retry permanent failures", func(t *testing.T) {
+		*flaky = flakyStore{RawStore: memStore, failures: 5, err: errTransient, calls: map[string]int{}}
+		flaky.err = fmt.Errorf("permanent failure")
+		_, err := store.ReadRaw(ctx, "mem://container/a")
+		assert.Error(t, err)
+		assert.Equal(t, 1, flaky.calls["read"])
+	})
+
+	t.Run("Doesn't retry unseekable writes", func(t *testing.T) {
+		*flaky = flakyStore{RawStore: memStore, failures: 1, err: errTransient, calls: map[string]int{}}
+		err := store.WriteRaw(ctx, "mem://container/a", int64(len(data)), Options{}, ioutil.NopCloser(bytes.NewReader(data)))
+		assert.Equal(t, errTransient, err)
+		assert.Equal(t, 1, flaky.calls["write"])
+	})
+
+	t.Run("Stops on cancellation", func(t *testing.T) {
+		*flaky = flakyStore{RawStore: memStore, failures: 10, err: errTransient, calls: map[string]int{}}
+		store.cfg.InitialBackoff.Duration = time.Hour
+		store.cfg.MaxBackoff.Duration = time.Hour
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		err := store.Delete(cancelCtx, "mem://container/a")
+		assert.Equal(t, errTransient, err)
+		assert.Equal(t, 1, flaky.calls["delete"])
+	})
+}
+
+func TestRetryingRawStore_Backoff(t *testing.T) {
+	store := &retryingRawStore{cfg: RetryConfig{
+		InitialBackoff: config.Duration{Duration: 10 * time.Millisecond},
+		MaxBackoff:     config.Duration{Duration: 50 * time.Millisecond},
+	}}
+
+	for i := 0; i < 100; i++ {
+		assert.LessOrEqual(t, store.backoff(1), 10*time.Millisecond)
+		assert.LessOrEqual(t, store.backoff(3), 40*time.Millisecond)
+		assert.LessOrEqual(t, store.backoff(20), 50*time.Millisecond)
+	}
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+type mount struct {
+	prefix DataReference
+	store  RawStore
+}
+
+// matches returns true if reference is the mount prefix itself or nested under it.
+func (m mount) matches(reference DataReference) bool {
+	return m.prefix.IsPrefixOf(reference)
+}
+
+// routingRawStore routes every reference to the store mounted under the longest matching prefix, or the default store
+// if no prefix matches. Copies between references routed to different stores are streamed through this process.
+type routingRawStore struct {
+	// RawStore is the default store.
+	RawStore
+	// mounts are sorted by descending prefix length so the first match is the longest one.
+	mounts   []mount
+	copyImpl copyImpl
+}
+
+// mountIndex returns the index of the mount reference is routed to, or -1 if it's routed to the default store.
+func (s *routingRawStore) mountIndex(reference DataReference) int {
+	for i, m := range s.mounts {
+		if m.matches(reference) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *routingRawStore) route(reference DataReference) RawStore {
+	if i := s.mountIndex(reference); i >= 0 {
+		return s.mounts[i].store
+	}
+
+	return s.RawStore
+}
+
+func (s *routingRawStore) CreateSignedURL(ctx context.Context, reference DataReference, properties SignedURLProperties) (SignedURLResponse, error) {
+	return s.route(reference).CreateSignedURL(ctx, reference, properties)
+}
+
+func (s *routingRawStore) Head(ctx context.Context, reference DataReference) (Metadata, error) {
+	return s.route(reference).Head(ctx, reference)
+}
+
+func (s *routingRawStore) ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error) {
+	return s.route(reference).ReadRaw(ctx, reference)
+}
+
+func (s *routingRawStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	return s.route(reference).ReadRawRange(ctx, reference, offset, length)
+}
+
+func (s *routingRawStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
+	return s.route(reference).WriteRaw(ctx, reference, size, opts, raw)
+}
+
+func (s *routingRawStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	return s.route(reference).OpenWriter(ctx, reference, opts)
+}
+
+// CopyRaw copies within the store both references are routed to, or streams the object from the source store to the
+// destination store otherwise.
+func (s *routingRawStore) CopyRaw(ctx context.Context, source, destination DataReference, opts Options) error {
+	if s.mountIndex(source) == s.mountIndex(destination) {
+		return s.route(source).CopyRaw(ctx, source, destination, opts)
+	}
+
+	return s.copyImpl.CopyRaw(ctx, source, destination, opts)
+}
+
+func (s *routingRawStore) Delete(ctx context.Context, reference DataReference) error {
+	return s.route(reference).Delete(ctx, reference)
+}
+
+// List lists the references under prefix in the store prefix is routed to. Mounts nested under prefix aren't listed.
+func (s *routingRawStore) List(ctx context.Context, prefix DataReference, cursor Cursor, limit int) ([]DataReference, Cursor, error) {
+	return s.route(prefix).List(ctx, prefix, cursor, limit)
+}
+
+// newRoutingRawStore creates a routingRawStore over the mounts in cfg, or returns defaultStore if there are none.
+func newRoutingRawStore(ctx context.Context, cfg *Config, defaultStore RawStore, metrics *dataStoreMetrics) (RawStore, error) {
+	if len(cfg.Mounts) == 0 {
+		return defaultStore, nil
+	}
+
+	s := &routingRawStore{
+		RawStore: defaultStore,
+		mounts:   make([]mount, 0, len(cfg.Mounts)),
+	}
+
+	seen := make(map[DataReference]bool, len(cfg.Mounts))
+	for i := range cfg.Mounts {
+		mountCfg := &cfg.Mounts[i]
+		prefix := DataReference(mountCfg.Prefix)
+		if err := prefix.Validate(); err != nil {
+			return nil, fmt.Errorf("mount prefix [%v] is invalid. Error: %w", prefix, err)
+		}
+
+		if seen[prefix] {
+			return nil, fmt.Errorf("mount prefix [%v] is mounted more than once", prefix)
+		}
+
+		seen[prefix] = true
+		store, err := newBackendRawStore(ctx, &mountCfg.Config, metrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create store mounted at [%v]. Error: %w", prefix, err)
+		}
+
+		s.mounts = append(s.mounts, mount{prefix: prefix, store: store})
+	}
+
+	sort.SliceStable(s.mounts, func(i, j int) bool {
+		return len(s.mounts[i].prefix) > len(s.mounts[j].prefix)
+	})
+
+	s.copyImpl = newCopyImpl(s, metrics.copyMetrics)
+	return s, nil
+}
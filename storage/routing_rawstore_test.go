@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+func TestMount_Matches(t *testing.T) {
+	m := mount{prefix: "s3://bucket/scratch"}
+	assert.True(t, m.matches("s3://bucket/scratch"))
+	assert.True(t, m.matches("s3://bucket/scratch/a"))
+	assert.False(t, m.matches("s3://bucket/scratchy"))
+	assert.False(t, m.matches("s3://bucket"))
+
+	m = mount{prefix: "s3://bucket/"}
+	assert.True(t, m.matches("s3://bucket/a"))
+	assert.False(t, m.matches("s3://bucket2/a"))
+}
+
+func TestNewRoutingRawStore(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+
+	store, err := newRoutingRawStore(ctx, &Config{}, memStore, metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, memStore, store)
+
+	_, err = newRoutingRawStore(ctx, &Config{Mounts: []MountConfig{{Prefix: "bucket/a", Config: Config{Type: TypeMemory}}}},
+		memStore, metrics)
+	assert.Error(t, err)
+
+	_, err = newRoutingRawStore(ctx, &Config{Mounts: []MountConfig{
+		{Prefix: "mem://a", Config: Config{Type: TypeMemory}},
+		{Prefix: "mem://a", Config: Config{Type: TypeMemory}},
+	}}, memStore, metrics)
+	assert.Error(t, err)
+
+	_, err = newRoutingRawStore(ctx, &Config{Mounts: []MountConfig{{Prefix: "mem://a", Config: Config{Type: "bad"}}}},
+		memStore, metrics)
+	assert.Error(t, err)
+}
+
+func TestRoutingRawStore(t *testing.T) {
+	ctx := context.TODO()
+	defaultStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	assert.NoError(t, err)
+
+	s, err := newRoutingRawStore(ctx, &Config{Mounts: []MountConfig{
+		{Prefix: "mem://scratch", Config: Config{Type: TypeMemory}},
+		{Prefix: "mem://scratch/nested", Config: Config{Type: TypeMemory}},
+	}}, defaultStore, metrics)
+	assert.NoError(t, err)
+	store := s.(*routingRawStore)
+	scratch := store.route("mem://scratch/a")
+	nested := store.route("mem://scratch/nested/a")
+	assert.NotSame(t, scratch, nested)
+	assert.Same(t, defaultStore, store.route("mem://data/a"))
+
+	assertExists := func(t *testing.T, s RawStore, reference DataReference, exists bool) {
+		md, err := s.Head(ctx, reference)
+		assert.NoError(t, err)
+		assert.Equal(t, exists, md.Exists(), reference)
+	}
+
+	t.Run("Routes by longest prefix", func(t *testing.T) {
+		for _, ref := range []DataReference{"mem://data/a", "mem://scratch/a", "mem://scratch/nested/a"} {
+			assert.NoError(t, store.WriteRaw(ctx, ref, 5, Options{}, bytes.NewReader([]byte("hello"))))
+			assert.Equal(t, "hello", readString(t, store, ref))
+		}
+
+		assertExists(t, defaultStore, "mem://data/a", true)
+		assertExists(t, defaultStore, "mem://scratch/a", false)
+		assertExists(t, scratch, "mem://scratch/a", true)
+		assertExists(t, scratch, "mem://scratch/nested/a", false)
+		assertExists(t, nested, "mem://scratch/nested/a", true)
+	})
+
+	t.Run("Copies across mounts", func(t *testing.T) {
+		assert.NoError(t, store.CopyRaw(ctx, "mem://data/a", "mem://scratch/b", Options{}))
+		assertExists(t, scratch, "mem://scratch/b", true)
+		assert.NoError(t, store.CopyRaw(ctx, "mem://scratch/b", "mem://scratch/c", Options{}))
+		assert.Equal(t, "hello", readString(t, scratch, "mem://scratch/c"))
+	})
+
+	t.Run("Deletes", func(t *testing.T) {
+		assert.NoError(t, store.Delete(ctx, "mem://scratch/c"))
+		assertExists(t, scratch, "mem://scratch/c", false)
+	})
+}
+
+func TestRoutingRawStore_DataStore(t *testing.T) {
+	ctx := context.TODO()
+	s, err := NewDataStore(&Config{
+		Type:   TypeMemory,
+		Mounts: []MountConfig{{Prefix: "mem://scratch", Config: Config{Type: TypeMemory}}},
+	}, promutils.NewTestScope())
+	assert.NoError(t, err)
+
+	ref, err := s.ConstructReference(ctx, "mem://scratch", "a", "b")
+	assert.NoError(t, err)
+	assert.NoError(t, s.WriteProtobuf(ctx, ref, Options{}, &mockProtoMessage{X: 5}))
+	assert.NoError(t, s.CopyRaw(ctx, ref, "mem://data/b", Options{}))
+
+	msg := &mockProtoMessage{}
+	assert.NoError(t, s.ReadProtobuf(ctx, "mem://data/b", msg))
+	assert.Equal(t, int64(5), msg.X)
+}
@@ -0,0 +1,619 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	s32 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/flyteorg/stow"
+	"github.com/flyteorg/stow/s3"
+	errs "github.com/pkg/errors"
+
+	stdErrs "github.com/flyteorg/flytestdlib/errors"
+)
+
+const (
+	s3AuthTypeAccessKey   = "accesskey"
+	s3RegionLookupTimeout = 5 * time.Second
+)
+
+// s3Location serves the containers of a stow S3 location through s3Container, which implements MultipartContainer,
+// ConditionalContainer and CopyContainer on top of the S3 API. Containers send their requests through the HTTP client of the location.
+// The remaining location operations (e.g. listing containers) are served by stow.
+type s3Location struct {
+	stow.Location
+	cfg        stow.ConfigMap
+	httpClient *http.Client
+	client     *s32.S3
+}
+
+// Container returns the named bucket, using a client for its region unless a custom endpoint is configured.
+func (l *s3Location) Container(id string) (stow.Container, error) {
+	client := l.client
+	region, regionSet := l.cfg.Config(s3.ConfigRegion)
+	if _, endpointSet := l.cfg.Config(s3.ConfigEndpoint); !endpointSet {
+		ctx, cancel := context.WithTimeout(context.Background(), s3RegionLookupTimeout)
+		region, _ = s3manager.GetBucketRegionWithClient(ctx, l.client, id)
+		cancel()
+
+		var err error
+		if client, err = newS3Client(l.cfg, region, l.httpClient); err != nil {
+			return nil, errs.Wrapf(err, "failed to create a client for region [%v]", region)
+		}
+	}
+
+	c := &s3Container{name: id, client: client}
+	if regionSet || len(region) > 0 {
+		return c, nil
+	}
+
+	if _, err := client.GetBucketLocation(&s32.GetBucketLocationInput{Bucket: aws.String(id)}); err != nil {
+		if awsBucketIsNotFound(err) {
+			return nil, stow.ErrNotFound
+		}
+
+		return nil, errs.Wrapf(err, "failed to get the location of bucket [%v]", id)
+	}
+
+	return c, nil
+}
+
+// CreateContainer creates the named bucket.
+func (l *s3Location) CreateContainer(name string) (stow.Container, error) {
+	if _, err := l.client.CreateBucket(&s32.CreateBucketInput{Bucket: aws.String(name)}); err != nil {
+		return nil, errs.Wrapf(err, "failed to create bucket [%v]", name)
+	}
+
+	return &s3Container{name: name, client: l.client}, nil
+}
+
+// newS3Location wraps the stow S3 location loc dialed with cfg. Requests are sent through httpClient, or
+// http.DefaultClient if it's nil.
+func newS3Location(loc stow.Location, cfg stow.ConfigMap, httpClient *http.Client) (stow.Location, error) {
+	client, err := newS3Client(cfg, "", httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Location{
+		Location:   loc,
+		cfg:        cfg,
+		httpClient: httpClient,
+		client:     client,
+	}, nil
+}
+
+// newS3Client creates a client for region configured like the clients of stow S3 locations.
+func newS3Client(cfg stow.ConfigMap, region string, httpClient *http.Client) (*s32.S3, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	awsConfig := aws.NewConfig().
+		WithHTTPClient(httpClient).
+		WithMaxRetries(aws.UseServiceDefaultRetries)
+
+	if len(region) == 0 {
+		region, _ = cfg.Config(s3.ConfigRegion)
+	}
+
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+
+	awsConfig.WithRegion(region)
+	if authType, _ := cfg.Config(s3.ConfigAuthType); len(authType) == 0 || authType == s3AuthTypeAccessKey {
+		accessKeyID, _ := cfg.Config(s3.ConfigAccessKeyID)
+		secretKey, _ := cfg.Config(s3.ConfigSecretKey)
+		awsConfig.WithCredentials(credentials.NewStaticCredentials(accessKeyID, secretKey, ""))
+	}
+
+	if endpoint, ok := cfg.Config(s3.ConfigEndpoint); ok {
+		awsConfig.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+
+	if disableSSL, _ := cfg.Config(s3.ConfigDisableSSL); strings.EqualFold(disableSSL, "true") {
+		awsConfig.WithDisableSSL(true)
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s32.New(sess)
+	if v2Signing, _ := cfg.Config(s3.ConfigV2Signing); strings.EqualFold(v2Signing, "true") {
+		setV2SigningHandlers(client)
+	}
+
+	return client, nil
+}
+
+// setV2SigningHandlers signs the requests of client with the v2 signature like stow does for its own clients.
+func setV2SigningHandlers(client *s32.S3) {
+	client.Handlers.Build.PushBack(func(r *request.Request) {
+		r.HTTPRequest.URL.Opaque = r.HTTPRequest.URL.EscapedPath()
+	})
+
+	client.Handlers.Sign.Clear()
+	client.Handlers.Sign.PushBack(s3.Sign)
+	client.Handlers.Sign.PushBackNamed(corehandlers.BuildContentLengthHandler)
+}
+
+// s3Container implements stow.Container, MultipartContainer, ConditionalContainer and CopyContainer for an S3 bucket.
+type s3Container struct {
+	name   string
+	client *s32.S3
+}
+
+func (c *s3Container) ID() string {
+	return c.name
+}
+
+func (c *s3Container) Name() string {
+	return c.name
+}
+
+// Item gets the metadata of the item with the given id.
+func (c *s3Container) Item(id string) (stow.Item, error) {
+	res, err := c.client.HeadObject(&s32.HeadObjectInput{
+		Bucket: aws.String(c.name),
+		Key:    aws.String(id),
+	})
+
+	if err != nil {
+		return nil, wrapS3Error(err, "failed to get item [%v]", id)
+	}
+
+	return &s3Item{
+		container:    c,
+		key:          id,
+		size:         aws.Int64Value(res.ContentLength),
+		etag:         cleanS3Etag(aws.StringValue(res.ETag)),
+		lastModified: aws.TimeValue(res.LastModified),
+		metadata:     fromS3Metadata(res.Metadata),
+	}, nil
+}
+
+// Items lists up to count items whose id starts with prefix, after the id in cursor. Archived items are skipped.
+func (c *s3Container) Items(prefix, cursor string, count int) ([]stow.Item, string, error) {
+	res, err := c.client.ListObjectsV2(&s32.ListObjectsV2Input{
+		Bucket:     aws.String(c.name),
+		Prefix:     aws.String(prefix),
+		StartAfter: aws.String(cursor),
+		MaxKeys:    aws.Int64(int64(count)),
+	})
+
+	if err != nil {
+		return nil, "", wrapS3Error(err, "failed to list items with prefix [%v]", prefix)
+	}
+
+	items := make([]stow.Item, 0, len(res.Contents))
+	next := stow.CursorStart
+	for _, object := range res.Contents {
+		next = aws.StringValue(object.Key)
+		if aws.StringValue(object.StorageClass) == s32.ObjectStorageClassGlacier {
+			continue
+		}
+
+		items = append(items, &s3Item{
+			container:    c,
+			key:          aws.StringValue(object.Key),
+			size:         aws.Int64Value(object.Size),
+			etag:         cleanS3Etag(aws.StringValue(object.ETag)),
+			lastModified: aws.TimeValue(object.LastModified),
+		})
+	}
+
+	if !aws.BoolValue(res.IsTruncated) {
+		next = stow.CursorStart
+	}
+
+	return items, next, nil
+}
+
+func (c *s3Container) RemoveItem(id string) error {
+	_, err := c.client.DeleteObject(&s32.DeleteObjectInput{
+		Bucket: aws.String(c.name),
+		Key:    aws.String(id),
+	})
+
+	return wrapS3Error(err, "failed to remove item [%v]", id)
+}
+
+// Put uploads the item, in parts if it's large.
+func (c *s3Container) Put(name string, r io.Reader, size int64, metadata map[string]interface{}) (stow.Item, error) {
+	md, err := toS3Metadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s3manager.NewUploaderWithClient(c.client).Upload(&s3manager.UploadInput{
+		Bucket:   aws.String(c.name),
+		Key:      aws.String(name),
+		Body:     r,
+		Metadata: md,
+	})
+
+	if err != nil {
+		return nil, wrapS3Error(err, "failed to put item [%v]", name)
+	}
+
+	return &s3Item{
+		container:    c,
+		key:          name,
+		size:         size,
+		etag:         cleanS3Etag(aws.StringValue(res.ETag)),
+		lastModified: time.Now(),
+		metadata:     metadata,
+	}, nil
+}
+
+// PutIf uploads the item in a single request carrying If-None-Match or If-Match so that S3 checks the conditions
+// atomically. Readers that can't seek are buffered in memory to sign the request.
+func (c *s3Container) PutIf(name string, r io.Reader, size int64, metadata map[string]interface{}, ifNotExists bool,
+	ifMatch string) (stow.Item, error) {
+
+	md, err := toS3Metadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	body, ok := r.(io.ReadSeeker)
+	if !ok {
+		raw, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, errs.Wrapf(err, "failed to read item [%v]", name)
+		}
+
+		body = bytes.NewReader(raw)
+	}
+
+	req, res := c.client.PutObjectRequest(&s32.PutObjectInput{
+		Bucket:   aws.String(c.name),
+		Key:      aws.String(name),
+		Body:     body,
+		Metadata: md,
+	})
+
+	// The SDK version in use doesn't model conditional puts, the headers are added before the request is signed.
+	req.Handlers.Build.PushBack(func(r *request.Request) {
+		if ifNotExists {
+			r.HTTPRequest.Header.Set("If-None-Match", "*")
+		}
+
+		if len(ifMatch) > 0 {
+			r.HTTPRequest.Header.Set("If-Match", `"`+ifMatch+`"`)
+		}
+	})
+
+	if err = req.Send(); err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok {
+			// S3 returns 409 if a concurrent conditional write to the same key won, and NoSuchKey if the item to match
+			// is missing. Other 404s (e.g. NoSuchBucket) aren't about the conditions.
+			switch {
+			case reqErr.StatusCode() == http.StatusPreconditionFailed, reqErr.StatusCode() == http.StatusConflict,
+				reqErr.Code() == s32.ErrCodeNoSuchKey:
+				return nil, stdErrs.Wrapf(ErrPreconditionFailed, err, "conditions of put to [%v] don't hold", name)
+			}
+		}
+
+		return nil, wrapS3Error(err, "failed to put item [%v]", name)
+	}
+
+	return &s3Item{
+		container:    c,
+		key:          name,
+		size:         size,
+		etag:         cleanS3Etag(aws.StringValue(res.ETag)),
+		lastModified: time.Now(),
+		metadata:     metadata,
+	}, nil
+}
+
+// CopyItem copies the source item to destination server-side. The metadata of the source item is preserved unless
+// metadata isn't nil.
+func (c *s3Container) CopyItem(source, destination string, metadata map[string]interface{}) (stow.Item, error) {
+	input := &s32.CopyObjectInput{
+		Bucket:     aws.String(c.name),
+		Key:        aws.String(destination),
+		CopySource: aws.String((&url.URL{Path: c.name + "/" + source}).EscapedPath()),
+	}
+
+	if metadata != nil {
+		md, err := toS3Metadata(metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		input.Metadata = md
+		input.MetadataDirective = aws.String(s32.MetadataDirectiveReplace)
+	}
+
+	if _, err := c.client.CopyObject(input); err != nil {
+		return nil, wrapS3Error(err, "failed to copy item [%v] to [%v]", source, destination)
+	}
+
+	return c.Item(destination)
+}
+
+func (c *s3Container) PreSignRequest(ctx context.Context, clientMethod stow.ClientMethod, id string,
+	params stow.PresignRequestParams) (string, error) {
+
+	var req *request.Request
+	switch clientMethod {
+	case stow.ClientMethodGet:
+		req, _ = c.client.GetObjectRequest(&s32.GetObjectInput{
+			Bucket: aws.String(c.name),
+			Key:    aws.String(id),
+		})
+	case stow.ClientMethodPut:
+		input := &s32.PutObjectInput{
+			Bucket: aws.String(c.name),
+			Key:    aws.String(id),
+		}
+
+		if len(params.ContentMD5) > 0 {
+			input.ContentMD5 = aws.String(params.ContentMD5)
+		}
+
+		req, _ = c.client.PutObjectRequest(input)
+	default:
+		return "", fmt.Errorf("unsupported client method [%v]", clientMethod.String())
+	}
+
+	req.SetContext(ctx)
+	return req.Presign(params.ExpiresIn)
+}
+
+// InitiateMultipartUpload starts an S3 multipart upload of the named item. All parts but the last must be at least
+// 5MB.
+func (c *s3Container) InitiateMultipartUpload(name string, metadata map[string]interface{}) (MultipartUpload, error) {
+	md, err := toS3Metadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.CreateMultipartUpload(&s32.CreateMultipartUploadInput{
+		Bucket:   aws.String(c.name),
+		Key:      aws.String(name),
+		Metadata: md,
+	})
+
+	if err != nil {
+		return nil, wrapS3Error(err, "failed to create multipart upload of [%v]", name)
+	}
+
+	return &s3MultipartUpload{
+		client:   c.client,
+		bucket:   c.name,
+		key:      name,
+		uploadID: aws.StringValue(res.UploadId),
+		etags:    map[int]string{},
+	}, nil
+}
+
+// s3MultipartUpload implements MultipartUpload for an S3 multipart upload.
+type s3MultipartUpload struct {
+	client   *s32.S3
+	bucket   string
+	key      string
+	uploadID string
+	lock     sync.Mutex
+	// etags holds the Etag of every uploaded part by part number. They're needed to complete the upload.
+	etags map[int]string
+}
+
+func (u *s3MultipartUpload) UploadPart(ctx context.Context, partNumber int, raw []byte) error {
+	res, err := u.client.UploadPartWithContext(ctx, &s32.UploadPartInput{
+		Bucket:     aws.String(u.bucket),
+		Key:        aws.String(u.key),
+		UploadId:   aws.String(u.uploadID),
+		PartNumber: aws.Int64(int64(partNumber)),
+		Body:       bytes.NewReader(raw),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.etags[partNumber] = aws.StringValue(res.ETag)
+	return nil
+}
+
+func (u *s3MultipartUpload) Complete(ctx context.Context, partCount int) error {
+	u.lock.Lock()
+	parts := make([]*s32.CompletedPart, 0, partCount)
+	for partNumber := 1; partNumber <= partCount; partNumber++ {
+		etag, found := u.etags[partNumber]
+		if !found {
+			u.lock.Unlock()
+			return fmt.Errorf("part [%v] of [%v] wasn't uploaded", partNumber, u.key)
+		}
+
+		parts = append(parts, &s32.CompletedPart{
+			ETag:       aws.String(etag),
+			PartNumber: aws.Int64(int64(partNumber)),
+		})
+	}
+	u.lock.Unlock()
+
+	_, err := u.client.CompleteMultipartUploadWithContext(ctx, &s32.CompleteMultipartUploadInput{
+		Bucket:          aws.String(u.bucket),
+		Key:             aws.String(u.key),
+		UploadId:        aws.String(u.uploadID),
+		MultipartUpload: &s32.CompletedMultipartUpload{Parts: parts},
+	})
+
+	return err
+}
+
+func (u *s3MultipartUpload) Abort(ctx context.Context) error {
+	_, err := u.client.AbortMultipartUploadWithContext(ctx, &s32.AbortMultipartUploadInput{
+		Bucket:   aws.String(u.bucket),
+		Key:      aws.String(u.key),
+		UploadId: aws.String(u.uploadID),
+	})
+
+	return err
+}
+
+// s3Item implements stow.Item for an S3 object. Items returned by Items don't include user metadata, it's fetched
+// once when first requested.
+type s3Item struct {
+	container    *s3Container
+	key          string
+	size         int64
+	etag         string
+	lastModified time.Time
+	metadataOnce sync.Once
+	metadata     map[string]interface{}
+	metadataErr  error
+}
+
+func (i *s3Item) ID() string {
+	return i.key
+}
+
+func (i *s3Item) Name() string {
+	return i.key
+}
+
+func (i *s3Item) URL() *url.URL {
+	return &url.URL{
+		Scheme: "s3",
+		Host:   i.container.name,
+		Path:   "/" + i.key,
+	}
+}
+
+func (i *s3Item) Size() (int64, error) {
+	return i.size, nil
+}
+
+func (i *s3Item) Open() (io.ReadCloser, error) {
+	res, err := i.container.client.GetObject(&s32.GetObjectInput{
+		Bucket: aws.String(i.container.name),
+		Key:    aws.String(i.key),
+	})
+
+	if err != nil {
+		return nil, wrapS3Error(err, "failed to open item [%v]", i.key)
+	}
+
+	return res.Body, nil
+}
+
+// OpenRange opens the item for reading from byte start to byte end, inclusive.
+func (i *s3Item) OpenRange(start, end uint64) (io.ReadCloser, error) {
+	res, err := i.container.client.GetObject(&s32.GetObjectInput{
+		Bucket: aws.String(i.container.name),
+		Key:    aws.String(i.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+	})
+
+	if err != nil {
+		return nil, wrapS3Error(err, "failed to open range of item [%v]", i.key)
+	}
+
+	return res.Body, nil
+}
+
+func (i *s3Item) ETag() (string, error) {
+	return i.etag, nil
+}
+
+func (i *s3Item) LastMod() (time.Time, error) {
+	return i.lastModified, nil
+}
+
+func (i *s3Item) Metadata() (map[string]interface{}, error) {
+	i.metadataOnce.Do(func() {
+		if i.metadata != nil {
+			return
+		}
+
+		item, err := i.container.Item(i.key)
+		if err != nil {
+			i.metadataErr = err
+			return
+		}
+
+		i.metadata, i.metadataErr = item.Metadata()
+	})
+
+	return i.metadata, i.metadataErr
+}
+
+// wrapS3Error wraps err with the message, translating missing objects to stow.ErrNotFound. It returns nil if err is
+// nil.
+func wrapS3Error(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case "NotFound", s32.ErrCodeNoSuchKey:
+			return errs.Wrapf(stow.ErrNotFound, format, args...)
+		}
+	}
+
+	return errs.Wrapf(err, format, args...)
+}
+
+// cleanS3Etag removes the quotes S3 puts around Etags.
+func cleanS3Etag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
+
+func toS3Metadata(metadata map[string]interface{}) (map[string]*string, error) {
+	md, err := toStringMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return aws.StringMap(md), nil
+}
+
+// toStringMetadata converts metadata to the string values backends accept. It fails if any value isn't a string.
+func toStringMetadata(metadata map[string]interface{}) (map[string]string, error) {
+	md := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of metadata key [%v] must be a string", k)
+		}
+
+		md[k] = s
+	}
+
+	return md, nil
+}
+
+// fromS3Metadata lower-cases the keys S3 returns canonicalized.
+func fromS3Metadata(metadata map[string]*string) map[string]interface{} {
+	md := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		md[strings.ToLower(k)] = aws.StringValue(v)
+	}
+
+	return md
+}
@@ -0,0 +1,432 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" // #nosec
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	s32 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/flyteorg/stow"
+	"github.com/flyteorg/stow/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeS3Object struct {
+	data     []byte
+	metadata http.Header
+}
+
+func (o fakeS3Object) etag() string {
+	sum := md5.Sum(o.data) // #nosec
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// fakeS3 serves the subset of the S3 API used by s3Container for path-style requests.
+type fakeS3 struct {
+	lock     sync.Mutex
+	objects  map[string]fakeS3Object
+	uploads  map[string]map[int][]byte
+	metadata map[string]http.Header
+	requests []string
+	headers  []http.Header
+	buckets  map[string]bool
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	query := r.URL.Query()
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		bucket, key = path[:i], path[i+1:]
+	}
+
+	f.requests = append(f.requests, r.Method+" "+path+"?"+r.URL.RawQuery)
+	f.headers = append(f.headers, r.Header.Clone())
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case !f.buckets[bucket] && len(key) == 0 && r.Method == http.MethodPut:
+		f.buckets[bucket] = true
+	case !f.buckets[bucket]:
+		w.WriteHeader(http.StatusNotFound)
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"Error"`
+			Code    string
+		}{Code: s32.ErrCodeNoSuchBucket})
+	case len(key) == 0 && r.Method == http.MethodGet:
+		f.list(w, query.Get("prefix"))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := strconv.Itoa(len(f.uploads))
+		f.uploads[uploadID] = map[int][]byte{}
+		f.metadata[uploadID] = r.Header.Clone()
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Key: key, UploadID: uploadID})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][partNumber] = body
+		w.Header().Set("ETag", fakeS3Object{data: body}.etag())
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := f.uploads[query.Get("uploadId")]
+		numbers := make([]int, 0, len(parts))
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+
+		sort.Ints(numbers)
+		object := fakeS3Object{metadata: f.metadata[query.Get("uploadId")]}
+		for _, n := range numbers {
+			object.data = append(object.data, parts[n]...)
+		}
+
+		f.objects[key] = object
+		delete(f.uploads, query.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			ETag    string
+		}{ETag: object.etag()})
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && len(r.Header.Get("X-Amz-Copy-Source")) > 0:
+		sourcePath, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		source, found := f.objects[sourcePath[strings.Index(sourcePath, "/")+1:]]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Header.Get("X-Amz-Metadata-Directive") == s32.MetadataDirectiveReplace {
+			source.metadata = r.Header.Clone()
+		}
+
+		f.objects[key] = source
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CopyObjectResult"`
+			ETag    string
+		}{ETag: source.etag()})
+	case r.Method == http.MethodPut:
+		existing, found := f.objects[key]
+		ifMatch := r.Header.Get("If-Match")
+		if (r.Header.Get("If-None-Match") == "*" && found) || (len(ifMatch) > 0 && (!found || existing.etag() != ifMatch)) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		object := fakeS3Object{data: body, metadata: r.Header.Clone()}
+		f.objects[key] = object
+		w.Header().Set("ETag", object.etag())
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		object, found := f.objects[key]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		for k, v := range object.metadata {
+			if strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") {
+				w.Header()[k] = v
+			}
+		}
+
+		w.Header().Set("ETag", object.etag())
+		data := object.data
+		if rng := r.Header.Get("Range"); len(rng) > 0 {
+			var start, end int
+			_, _ = fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			data = data[start : end+1]
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	type content struct {
+		Key  string
+		Size int
+		ETag string
+	}
+
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	contents := make([]content, 0, len(keys))
+	for _, k := range keys {
+		contents = append(contents, content{Key: k, Size: len(f.objects[k].data), ETag: f.objects[k].etag()})
+	}
+
+	writeXML(w, struct {
+		XMLName  xml.Name `xml:"ListBucketResult"`
+		Contents []content
+	}{Contents: contents})
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	raw, err := xml.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	_, _ = w.Write(raw)
+}
+
+func newFakeS3Server(t *testing.T) (*fakeS3, stow.ConfigMap) {
+	f := &fakeS3{
+		objects:  map[string]fakeS3Object{},
+		uploads:  map[string]map[int][]byte{},
+		metadata: map[string]http.Header{},
+		buckets:  map[string]bool{"bucket": true},
+	}
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, stow.ConfigMap{
+		s3.ConfigAccessKeyID: "key",
+		s3.ConfigSecretKey:   "secret",
+		s3.ConfigEndpoint:    server.URL,
+		s3.ConfigRegion:      "us-east-1",
+		s3.ConfigDisableSSL:  "true",
+	}
+}
+
+func TestS3Container(t *testing.T) {
+	ctx := context.TODO()
+	fake, cfgMap := newFakeS3Server(t)
+	s, err := newStowRawStore(ctx, &Config{
+		Stow:            StowConfig{Kind: s3.Kind, Config: cfgMap},
+		InitContainer:   "bucket",
+		MultipartUpload: MultipartUploadConfig{PartSizeMegabytes: 1, Concurrency: 2},
+	}, metrics)
+	require.NoError(t, err)
+
+	t.Run("Multipart upload", func(t *testing.T) {
+		raw := bytes.Repeat([]byte("0123456789"), 250*1024)
+		w, err := s.OpenWriter(ctx, "s3://bucket/multipart", Options{Metadata: map[string]interface{}{"owner": "me"}})
+		require.NoError(t, err)
+		_, err = w.Write(raw)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		fake.lock.Lock()
+		parts := 0
+		for _, r := range fake.requests {
+			if strings.HasPrefix(r, http.MethodPut+" bucket/multipart?") && strings.Contains(r, "partNumber=") {
+				parts++
+			}
+		}
+		fake.lock.Unlock()
+		assert.Equal(t, 3, parts)
+
+		md, err := s.Head(ctx, "s3://bucket/multipart")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(raw)), md.Size())
+		assert.Equal(t, "me", md.UserMetadata()["owner"])
+
+		rc, err := s.ReadRaw(ctx, "s3://bucket/multipart")
+		require.NoError(t, err)
+		read, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.NoError(t, rc.Close())
+		assert.Equal(t, raw, read)
+	})
+
+	t.Run("Aborted upload", func(t *testing.T) {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		w, err := s.OpenWriter(cancelCtx, "s3://bucket/aborted", Options{})
+		require.NoError(t, err)
+		_, err = w.Write(bytes.Repeat([]byte("a"), 1024*1024+1))
+		assert.NoError(t, err)
+		cancel()
+		assert.Error(t, w.Close())
+
+		md, err := s.Head(ctx, "s3://bucket/aborted")
+		assert.NoError(t, err)
+		assert.False(t, md.Exists())
+	})
+
+	t.Run("Read, list and delete", func(t *testing.T) {
+		assert.NoError(t, s.WriteRaw(ctx, "s3://bucket/dir/a", 5, Options{}, bytes.NewReader([]byte("hello"))))
+		assert.NoError(t, s.WriteRaw(ctx, "s3://bucket/dir/b", 5, Options{}, bytes.NewReader([]byte("world"))))
+
+		rc, err := s.ReadRawRange(ctx, "s3://bucket/dir/a", 1, 3)
+		require.NoError(t, err)
+		read, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.NoError(t, rc.Close())
+		assert.Equal(t, "ell", string(read))
+
+		refs, _, err := s.List(ctx, "s3://bucket/dir/", NewCursorAtStart(), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"s3://bucket/dir/a", "s3://bucket/dir/b"}, refs)
+
+		assert.NoError(t, s.Delete(ctx, "s3://bucket/dir/a"))
+		md, err := s.Head(ctx, "s3://bucket/dir/a")
+		assert.NoError(t, err)
+		assert.False(t, md.Exists())
+	})
+	t.Run("Conditional writes", func(t *testing.T) {
+		opts := Options{IfNotExists: true}
+		assert.NoError(t, s.WriteRaw(ctx, "s3://bucket/cond", 5, opts, bytes.NewReader([]byte("hello"))))
+		err := s.WriteRaw(ctx, "s3://bucket/cond", 5, opts, bytes.NewReader([]byte("world")))
+		assert.True(t, IsPreconditionFailed(err))
+
+		md, err := s.Head(ctx, "s3://bucket/cond")
+		require.NoError(t, err)
+		assert.NoError(t, s.WriteRaw(ctx, "s3://bucket/cond", 5, Options{IfMatch: md.Etag()},
+			bytes.NewReader([]byte("world"))))
+		err = s.WriteRaw(ctx, "s3://bucket/cond", 5, Options{IfMatch: md.Etag()}, bytes.NewReader([]byte("again")))
+		assert.True(t, IsPreconditionFailed(err))
+		err = s.WriteRaw(ctx, "s3://bucket/missing", 5, Options{IfMatch: md.Etag()}, bytes.NewReader([]byte("again")))
+		assert.True(t, IsPreconditionFailed(err))
+
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+		assert.Equal(t, "world", string(fake.objects["cond"].data))
+		heads := 0
+		for _, r := range fake.requests {
+			if strings.HasPrefix(r, http.MethodHead+" bucket/cond?") {
+				heads++
+			}
+		}
+
+		// Only the explicit Head above, the conditions are checked by S3.
+		assert.Equal(t, 1, heads)
+	})
+	t.Run("Conditional write to a missing bucket", func(t *testing.T) {
+		multiContainerStore, err := newStowRawStore(ctx, &Config{
+			Stow:                  StowConfig{Kind: s3.Kind, Config: cfgMap},
+			InitContainer:         "bucket",
+			MultiContainerEnabled: true,
+		}, metrics)
+		require.NoError(t, err)
+
+		container, err := multiContainerStore.(*StowStore).getContainer(ctx, locationIDMain, "other")
+		require.NoError(t, err)
+		_, err = container.(*s3Container).PutIf("a", bytes.NewReader([]byte("hello")), 5, nil, true, "")
+		assert.False(t, IsPreconditionFailed(err), err)
+		assert.True(t, awsBucketIsNotFound(err), err)
+
+		// The bucket is created for the write once it's found to be missing.
+		err = multiContainerStore.WriteRaw(ctx, "s3://other/a", 5, Options{IfNotExists: true},
+			bytes.NewReader([]byte("hello")))
+		assert.False(t, IsPreconditionFailed(err), err)
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+		assert.True(t, fake.buckets["other"])
+	})
+	t.Run("Server-side copy", func(t *testing.T) {
+		assert.NoError(t, s.WriteRaw(ctx, "s3://bucket/copy/a b", 5, Options{Metadata: map[string]interface{}{"owner": "me"}},
+			bytes.NewReader([]byte("hello"))))
+		assert.NoError(t, s.CopyRaw(ctx, "s3://bucket/copy/a b", "s3://bucket/copy/c", Options{}))
+		assert.NoError(t, s.CopyRaw(ctx, "s3://bucket/copy/a b", "s3://bucket/copy/d", Options{
+			Metadata: map[string]interface{}{"owner": "you"},
+		}))
+
+		md, err := s.Head(ctx, "s3://bucket/copy/c")
+		assert.NoError(t, err)
+		assert.Equal(t, "me", md.UserMetadata()["owner"])
+		md, err = s.Head(ctx, "s3://bucket/copy/d")
+		assert.NoError(t, err)
+		assert.Equal(t, "you", md.UserMetadata()["owner"])
+
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+		assert.Equal(t, "hello", string(fake.objects["copy/d"].data))
+		for _, r := range fake.requests {
+			assert.False(t, strings.HasPrefix(r, http.MethodGet+" bucket/copy/"), "copies must not be streamed")
+		}
+	})
+}
+
+func TestS3Container_HTTPClient(t *testing.T) {
+	// The SDK can only apply a custom CA bundle to clients using an *http.Transport.
+	t.Setenv("AWS_CA_BUNDLE", "")
+	ctx := context.TODO()
+	defaultClient := http.DefaultClient
+	fake, cfgMap := newFakeS3Server(t)
+	s, err := newStowRawStore(ctx, &Config{
+		Stow:              StowConfig{Kind: s3.Kind, Config: cfgMap},
+		InitContainer:     "bucket",
+		DefaultHTTPClient: HTTPClientConfig{Headers: map[string][]string{"X-Client": {"custom"}}},
+	}, metrics)
+	require.NoError(t, err)
+	assert.Same(t, defaultClient, http.DefaultClient)
+
+	assert.NoError(t, s.WriteRaw(ctx, "s3://bucket/a", 5, Options{}, bytes.NewReader([]byte("hello"))))
+	md, err := s.Head(ctx, "s3://bucket/a")
+	assert.NoError(t, err)
+	assert.True(t, md.Exists())
+
+	fake.lock.Lock()
+	assert.NotEmpty(t, fake.headers)
+	for _, header := range fake.headers {
+		assert.Equal(t, "custom", header.Get("X-Client"))
+	}
+	fake.lock.Unlock()
+
+	t.Run("V2 signing", func(t *testing.T) {
+		fake, cfgMap := newFakeS3Server(t)
+		cfgMap[s3.ConfigV2Signing] = "true"
+		s, err := newStowRawStore(ctx, &Config{
+			Stow:              StowConfig{Kind: s3.Kind, Config: cfgMap},
+			InitContainer:     "bucket",
+			DefaultHTTPClient: HTTPClientConfig{Headers: map[string][]string{"X-Client": {"custom"}}},
+		}, metrics)
+		require.NoError(t, err)
+
+		assert.NoError(t, s.WriteRaw(ctx, "s3://bucket/a", 5, Options{}, bytes.NewReader([]byte("hello"))))
+		md, err := s.Head(ctx, "s3://bucket/a")
+		assert.NoError(t, err)
+		assert.True(t, md.Exists())
+
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+		assert.NotEmpty(t, fake.headers)
+		for _, header := range fake.headers {
+			assert.Equal(t, "custom", header.Get("X-Client"))
+			assert.True(t, strings.HasPrefix(header.Get("Authorization"), "AWS "), header.Get("Authorization"))
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ReferenceConstructorType defines how a DataStore constructs references.
+type ReferenceConstructorType = string
+
+const (
+	// ReferenceConstructorURL joins nested keys to the base reference (see URLPathConstructor).
+	ReferenceConstructorURL ReferenceConstructorType = "url"
+	// ReferenceConstructorSharded inserts hash shards between the base reference and nested keys (see
+	// ShardedURLPathConstructor).
+	ReferenceConstructorSharded ReferenceConstructorType = "sharded"
+)
+
+// ShardedURLPathConstructor implements ReferenceConstructor like URLPathConstructor, except that it inserts Depth
+// segments of Width hex characters between the base reference and the nested keys. The segments are taken from the
+// hash of the reference URLPathConstructor would construct, so that references sharing a base are spread across
+// key prefixes deterministically. Use LogicalReference to map a sharded reference back to that reference.
+type ShardedURLPathConstructor struct {
+	depth int
+	width int
+}
+
+// shards returns the shard segments of the logical reference.
+func (c ShardedURLPathConstructor) shards(logical DataReference) []string {
+	sum := sha256.Sum256([]byte(logical))
+	digest := hex.EncodeToString(sum[:])
+	shards := make([]string, 0, c.depth)
+	for i := 0; i < c.depth; i++ {
+		shards = append(shards, digest[i*c.width:(i+1)*c.width])
+	}
+
+	return shards
+}
+
+// ConstructReference constructs the reference like URLPathConstructor with the shards inserted before the nested keys.
+// If reference is already sharded, the nested keys are joined to its logical reference instead, so that shards aren't
+// nested.
+func (c ShardedURLPathConstructor) ConstructReference(ctx context.Context, reference DataReference, nestedKeys ...string) (DataReference, error) {
+	if logical, err := c.LogicalReference(reference); err == nil {
+		reference = logical
+	}
+
+	logical, err := NewURLPathConstructor().ConstructReference(ctx, reference, nestedKeys...)
+	if err != nil {
+		return "", err
+	}
+
+	keys := append(c.shards(logical), nestedKeys...)
+	if len(nestedKeys) == 0 {
+		// References constructed without nested keys end with a separator.
+		keys = append(keys, "")
+	}
+
+	return NewURLPathConstructor().ConstructReference(ctx, reference, keys...)
+}
+
+// LogicalReference returns the reference URLPathConstructor would have constructed for the sharded reference.
+func (c ShardedURLPathConstructor) LogicalReference(reference DataReference) (DataReference, error) {
+	u, err := url.Parse(reference.String())
+	if err != nil {
+		return "", fmt.Errorf("reference is of an invalid format [%v]. Error: %w", reference, err)
+	}
+
+	// The escaped path is split so that escaped separators within keys are kept.
+	segments := strings.Split(u.EscapedPath(), separator)
+	for i := len(segments) - c.depth; i >= 0; i-- {
+		remaining := append(append([]string{}, segments[:i]...), segments[i+c.depth:]...)
+		u.RawPath = strings.Join(remaining, separator)
+		if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+			return "", fmt.Errorf("reference is of an invalid format [%v]. Error: %w", reference, err)
+		}
+
+		logical := DataReference(u.String())
+		if strings.Join(c.shards(logical), separator) == strings.Join(segments[i:i+c.depth], separator) {
+			return logical, nil
+		}
+	}
+
+	return "", fmt.Errorf("reference [%v] isn't sharded", reference)
+}
+
+// NewShardedURLPathConstructor creates a ShardedURLPathConstructor inserting depth shard segments of width hex
+// characters.
+func NewShardedURLPathConstructor(depth, width int) (ShardedURLPathConstructor, error) {
+	if depth < 1 || width < 1 || depth*width > hex.EncodedLen(sha256.Size) {
+		return ShardedURLPathConstructor{}, fmt.Errorf("shard depth [%v] and width [%v] must be positive and "+
+			"use at most [%v] characters", depth, width, hex.EncodedLen(sha256.Size))
+	}
+
+	return ShardedURLPathConstructor{
+		depth: depth,
+		width: width,
+	}, nil
+}
+
+// newReferenceConstructor creates the ReferenceConstructor configured in cfg.
+func newReferenceConstructor(cfg ReferenceConstructorConfig) (ReferenceConstructor, error) {
+	switch cfg.Type {
+	case "", ReferenceConstructorURL:
+		return NewURLPathConstructor(), nil
+	case ReferenceConstructorSharded:
+		return NewShardedURLPathConstructor(cfg.Shards.Depth, cfg.Shards.Width)
+	default:
+		return nil, fmt.Errorf("reference constructor type is of an invalid value [%v]", cfg.Type)
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+func TestShardedURLPathConstructor(t *testing.T) {
+	ctx := context.TODO()
+	c, err := NewShardedURLPathConstructor(2, 3)
+	require.NoError(t, err)
+
+	t.Run("Inserts shards", func(t *testing.T) {
+		r, err := c.ConstructReference(ctx, "s3://bucket/exec", "node", "outputs.pb")
+		assert.NoError(t, err)
+		parts := strings.Split(strings.TrimPrefix(r.String(), "s3://bucket/exec/"), separator)
+		require.Len(t, parts, 4)
+		assert.Len(t, parts[0], 3)
+		assert.Len(t, parts[1], 3)
+		assert.Equal(t, []string{"node", "outputs.pb"}, parts[2:])
+
+		again, err := c.ConstructReference(ctx, "s3://bucket/exec", "node", "outputs.pb")
+		assert.NoError(t, err)
+		assert.Equal(t, r, again)
+
+		other, err := c.ConstructReference(ctx, "s3://bucket/exec", "node", "inputs.pb")
+		assert.NoError(t, err)
+		assert.NotEqual(t, parts[:2], strings.Split(strings.TrimPrefix(other.String(), "s3://bucket/exec/"), separator)[:2])
+	})
+
+	t.Run("Maps back to logical reference", func(t *testing.T) {
+		for _, keys := range [][]string{{"node", "outputs.pb"}, {"key/"}, {}} {
+			logical, err := NewURLPathConstructor().ConstructReference(ctx, "s3://bucket/exec", keys...)
+			require.NoError(t, err)
+			r, err := c.ConstructReference(ctx, "s3://bucket/exec", keys...)
+			require.NoError(t, err)
+
+			actual, err := c.LogicalReference(r)
+			assert.NoError(t, err)
+			assert.Equal(t, logical, actual)
+		}
+
+		escaped, err := c.ConstructReference(ctx, "s3://bucket/exec", "a%2Fb")
+		require.NoError(t, err)
+		actual, err := c.LogicalReference(escaped)
+		assert.NoError(t, err)
+		assert.Equal(t, DataReference("s3://bucket/exec/a%2Fb"), actual)
+
+		_, err = c.LogicalReference("s3://bucket/exec/node/outputs.pb")
+		assert.Error(t, err)
+	})
+
+	t.Run("Ends with a separator without nested keys", func(t *testing.T) {
+		r, err := c.ConstructReference(ctx, "s3://bucket/exec")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(r.String(), separator), r)
+		assert.Len(t, strings.Split(strings.TrimPrefix(r.String(), "s3://bucket/exec/"), separator), 3)
+	})
+
+	t.Run("Doesn't nest shards", func(t *testing.T) {
+		nested, err := c.ConstructReference(ctx, "s3://bucket/exec", "node")
+		require.NoError(t, err)
+		r, err := c.ConstructReference(ctx, nested, "outputs.pb")
+		require.NoError(t, err)
+
+		expected, err := c.ConstructReference(ctx, "s3://bucket/exec/node", "outputs.pb")
+		require.NoError(t, err)
+		assert.Equal(t, expected, r)
+
+		actual, err := c.LogicalReference(r)
+		assert.NoError(t, err)
+		assert.Equal(t, DataReference("s3://bucket/exec/node/outputs.pb"), actual)
+	})
+
+	t.Run("Invalid layout", func(t *testing.T) {
+		_, err := NewShardedURLPathConstructor(0, 2)
+		assert.Error(t, err)
+		_, err = NewShardedURLPathConstructor(5, 13)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewDataStore_ReferenceConstructor(t *testing.T) {
+	s, err := NewDataStore(&Config{
+		Type: TypeMemory,
+		ReferenceConstructor: ReferenceConstructorConfig{
+			Type:   ReferenceConstructorSharded,
+			Shards: ShardsConfig{Depth: 1, Width: 2},
+		},
+	}, promutils.NewTestScope())
+	require.NoError(t, err)
+	assert.IsType(t, ShardedURLPathConstructor{}, s.ReferenceConstructor)
+
+	// Changing the constructor would move objects written afterwards, so it's kept when the config is refreshed.
+	assert.NoError(t, s.RefreshConfig(context.TODO(), &Config{Type: TypeMemory}))
+	assert.IsType(t, ShardedURLPathConstructor{}, s.ReferenceConstructor)
+
+	_, err = NewDataStore(&Config{
+		Type:                 TypeMemory,
+		ReferenceConstructor: ReferenceConstructorConfig{Type: "hashed"},
+	}, promutils.NewTestScope())
+	assert.Error(t, err)
+}
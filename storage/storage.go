@@ -9,6 +9,7 @@ package storage
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/flyteorg/stow"
@@ -22,19 +23,62 @@ import (
 // DataReference defines a reference to data location.
 type DataReference string
 
-var emptyStore = DataStore{}
+var emptyStore = &DataStore{}
 
 // Options holds storage options. It is used to pass Metadata (like headers for S3) and also tags or labels for
 // objects
 type Options struct {
 	Metadata map[string]interface{}
+
+	// IfNotExists makes a write fail with ErrPreconditionFailed if the object already exists. Only S3 and GCS check
+	// conditions atomically. Other backends (e.g. local or Azure) only Head the object before writing it, which is best
+	// effort: a concurrent write in between isn't detected, so it's no guarantee the condition holds when the object is
+	// written.
+	IfNotExists bool
+	// IfMatch makes a write fail with ErrPreconditionFailed unless the object exists and its Etag matches. It's
+	// checked like IfNotExists, so it's only atomic on S3 and GCS.
+	IfMatch string
+	// WireFormat overrides the format a ProtobufStore writes protobufs in. It's ignored by raw writes.
+	WireFormat WireFormat
+}
+
+// DeleteOptions holds options for deleting objects in bulk.
+type DeleteOptions struct {
+	// Concurrency is the maximum number of deletes in flight. Defaults to 10 if not set.
+	Concurrency int
+	// DryRun returns the references that would be deleted without deleting them.
+	DryRun bool
 }
 
+// hasWriteConditions gets a value indicating whether a write must only happen if the conditions in the options hold.
+func (o Options) hasWriteConditions() bool {
+	return o.IfNotExists || len(o.IfMatch) > 0
+}
+
+const (
+	// MetadataKeyContentType is the Options.Metadata key under which to record the content type of an object.
+	MetadataKeyContentType = "content-type"
+	// MetadataKeyContentMD5 is the Options.Metadata key under which to record the hex encoded MD5 of an object.
+	MetadataKeyContentMD5 = "content-md5"
+	// MetadataKeyContentCRC32C is the Options.Metadata key under which to record the hex encoded CRC32C (Castagnoli) of
+	// an object.
+	MetadataKeyContentCRC32C = "content-crc32c"
+)
+
 // Metadata is a placeholder for data reference metadata.
 type Metadata interface {
 	Exists() bool
 	Size() int64
 	Etag() string
+	// UserMetadata returns the metadata written along with the object through Options.Metadata. Keys are lower case
+	// since not all stores preserve their case.
+	UserMetadata() map[string]string
+	// ContentType returns the content type recorded under MetadataKeyContentType, if any.
+	ContentType() string
+	// LastModified returns the time the object was last written, if known.
+	LastModified() time.Time
+	// ContentMD5 returns the hex encoded MD5 of the object, if known.
+	ContentMD5() string
 }
 
 // DataStore is a simplified interface for accessing and storing data in one of the Cloud stores.
@@ -43,6 +87,10 @@ type DataStore struct {
 	ComposedProtobufStore
 	ReferenceConstructor
 	metrics *dataStoreMetrics
+	// refreshLock serializes RefreshConfig calls.
+	refreshLock sync.Mutex
+	// refConstructorCfg is the config ReferenceConstructor was created with.
+	refConstructorCfg ReferenceConstructorConfig
 }
 
 // SignedURLProperties encapsulates properties about the signedURL operation.
@@ -59,6 +107,48 @@ type SignedURLResponse struct {
 	URL url.URL
 }
 
+// CursorState defines the position of a Cursor within a paginated listing.
+type CursorState int
+
+const (
+	// AtStartCursorState indicates the listing has not started yet.
+	AtStartCursorState CursorState = iota
+	// AtEndCursorState indicates there are no more items to list.
+	AtEndCursorState
+	// AtCustomPosCursorState indicates the listing should resume from a store-specific position.
+	AtCustomPosCursorState
+)
+
+// Cursor is an opaque pointer to a position within a paginated listing. It's obtained from a previous call to
+// RawStore.List or created with NewCursorAtStart for the first page.
+type Cursor struct {
+	cursorState    CursorState
+	customPosition string
+}
+
+// NewCursorAtStart creates a cursor that points to the first page of a listing.
+func NewCursorAtStart() Cursor {
+	return Cursor{cursorState: AtStartCursorState}
+}
+
+// NewCursorAtEnd creates a cursor that indicates there are no more items to list.
+func NewCursorAtEnd() Cursor {
+	return Cursor{cursorState: AtEndCursorState}
+}
+
+// NewCursorFromCustomPosition creates a cursor that resumes listing from a store-specific position.
+func NewCursorFromCustomPosition(customPosition string) Cursor {
+	return Cursor{
+		cursorState:    AtCustomPosCursorState,
+		customPosition: customPosition,
+	}
+}
+
+// IsCursorEnd gets a value indicating whether the cursor indicates there are no more items to list.
+func IsCursorEnd(cursor Cursor) bool {
+	return cursor.cursorState == AtEndCursorState
+}
+
 //go:generate mockery -name RawStore -case=underscore
 
 // RawStore defines a low level interface for accessing and storing bytes.
@@ -75,14 +165,26 @@ type RawStore interface {
 	// ReadRaw retrieves a byte array from the Blob store or an error
 	ReadRaw(ctx context.Context, reference DataReference) (io.ReadCloser, error)
 
+	// ReadRawRange retrieves up to length bytes of the referenced object starting at offset. A negative length reads
+	// until the end of the object.
+	ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error)
+
 	// WriteRaw stores a raw byte array.
 	WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error
 
+	// OpenWriter returns a writer that streams data of unknown length to the referenced location. The data is only
+	// guaranteed to be stored once Close returns without an error. Cancelling ctx aborts the upload.
+	OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error)
+
 	// CopyRaw copies from source to destination.
 	CopyRaw(ctx context.Context, source, destination DataReference, opts Options) error
 
 	// Delete removes the referenced data from the blob store.
 	Delete(ctx context.Context, reference DataReference) error
+
+	// List retrieves up to limit references that start with the given prefix. Pass NewCursorAtStart() to get the
+	// first page and the returned cursor to get subsequent ones, until IsCursorEnd(cursor) returns true.
+	List(ctx context.Context, prefix DataReference, cursor Cursor, limit int) ([]DataReference, Cursor, error)
 }
 
 //go:generate mockery -name ReferenceConstructor -case=underscore
@@ -103,6 +205,13 @@ type ProtobufStore interface {
 
 	// WriteProtobuf serializes and stores the protobuf.
 	WriteProtobuf(ctx context.Context, reference DataReference, opts Options, msg proto.Message) error
+
+	// OpenProtobufStreamWriter opens a writer that stores a stream of length-delimited protobufs in a single object.
+	OpenProtobufStreamWriter(ctx context.Context, reference DataReference, opts Options) (ProtobufStreamWriter, error)
+
+	// OpenProtobufStreamReader opens a stream of length-delimited protobufs written by a ProtobufStreamWriter for
+	// reading.
+	OpenProtobufStreamReader(ctx context.Context, reference DataReference) (ProtobufStreamReader, error)
 }
 
 //go:generate mockery -name ComposedProtobufStore -case=underscore
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flyteorg/stow"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/flyteorg/flytestdlib/config"
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+const (
+	CommandList   = "ls"
+	CommandCat    = "cat"
+	CommandCopy   = "cp"
+	CommandRemove = "rm"
+	CommandStat   = "stat"
+	CommandSign   = "sign"
+)
+
+const listPageSize = 1000
+
+// NewStorageCommand creates a command that runs storage operations against the storage configured in the Storage
+// section of the config loaded through accessorProvider. Metrics of the store are emitted under scope.
+func NewStorageCommand(accessorProvider config.AccessorProvider, scope promutils.Scope) *cobra.Command {
+	var store *DataStore
+	return newStorageCommand(accessorProvider, func(ctx context.Context, cfg *Config) (*DataStore, error) {
+		if store != nil {
+			return store, store.RefreshConfig(ctx, cfg)
+		}
+
+		var err error
+		store, err = NewDataStoreWithContext(ctx, cfg, scope)
+		return store, err
+	})
+}
+
+// newStorageCommand creates the storage command, running operations against the store newStore creates for the loaded
+// config.
+func newStorageCommand(accessorProvider config.AccessorProvider,
+	newStore func(ctx context.Context, cfg *Config) (*DataStore, error)) *cobra.Command {
+
+	opts := config.Options{}
+	var store *DataStore
+	rootCmd := &cobra.Command{
+		Use:       "storage",
+		Short:     "Runs various storage commands, look at the help of this command to get a list of available commands.",
+		ValidArgs: []string{CommandList, CommandCat, CommandCopy, CommandRemove, CommandStat, CommandSign},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := accessorProvider(opts).UpdateConfig(cmd.Context()); err != nil {
+				return err
+			}
+
+			cfg := GetConfig()
+			if cfg == nil {
+				return fmt.Errorf("failed to load the storage config")
+			}
+
+			var err error
+			store, err = newStore(cmd.Context(), cfg)
+			return err
+		},
+	}
+
+	lsCmd := &cobra.Command{
+		Use:   "ls <prefix>",
+		Short: "Lists the references under a prefix.",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	limit := lsCmd.Flags().Int("limit", 0, "Maximum number of references to list. If 0, all references are listed.")
+	lsCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runList(cmd, store, DataReference(args[0]), *limit)
+	}
+
+	catCmd := &cobra.Command{
+		Use:   "cat <reference>",
+		Short: "Prints the content of an object.",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	messageType := catCmd.Flags().String("type", "", `Fully qualified name of the protobuf message stored in the object
+(e.g. google.protobuf.Duration). If set, the message is printed as JSON.`)
+	catCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runCat(cmd, store, DataReference(args[0]), *messageType)
+	}
+
+	cpCmd := &cobra.Command{
+		Use:   "cp <source> <destination>",
+		Short: "Copies an object.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return store.CopyRaw(cmd.Context(), DataReference(args[0]), DataReference(args[1]), Options{})
+		},
+	}
+
+	rmCmd := &cobra.Command{
+		Use:   "rm <reference>...",
+		Short: "Deletes objects and prints the deleted references.",
+		Args:  cobra.MinimumNArgs(1),
+	}
+
+	prefix := rmCmd.Flags().Bool("prefix", false, "Deletes all references under the passed prefixes.")
+	deleteOpts := DeleteOptions{}
+	rmCmd.Flags().IntVar(&deleteOpts.Concurrency, "concurrency", defaultDeleteConcurrency,
+		"Maximum number of deletes in flight.")
+	rmCmd.Flags().BoolVar(&deleteOpts.DryRun, "dry-run", false, "Prints the references that would be deleted.")
+	rmCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runRemove(cmd, store, args, *prefix, deleteOpts)
+	}
+
+	statCmd := &cobra.Command{
+		Use:   "stat <reference>",
+		Short: "Prints the metadata of an object.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStat(cmd, store, DataReference(args[0]))
+		},
+	}
+
+	signCmd := &cobra.Command{
+		Use:   "sign <reference>",
+		Short: "Prints a signed URL for an object.",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	method := signCmd.Flags().String("method", strings.ToLower(stow.ClientMethodGet.String()),
+		"Method allowed by the URL [get/put].")
+	properties := SignedURLProperties{}
+	signCmd.Flags().DurationVar(&properties.ExpiresIn, "expires-in", time.Hour, "Duration the URL is valid for.")
+	signCmd.Flags().StringVar(&properties.ContentMD5, "content-md5", "", "Expected MD5 of the uploaded object.")
+	signCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runSign(cmd, store, DataReference(args[0]), *method, properties)
+	}
+
+	// Configure Root Command
+	rootCmd.PersistentFlags().StringArrayVar(&opts.SearchPaths, config.PathFlag, []string{}, `Passes the config file to load.
+If empty, it'll first search for the config file path then, if found, will load config from there.`)
+
+	rootCmd.AddCommand(lsCmd)
+	rootCmd.AddCommand(catCmd)
+	rootCmd.AddCommand(cpCmd)
+	rootCmd.AddCommand(rmCmd)
+	rootCmd.AddCommand(statCmd)
+	rootCmd.AddCommand(signCmd)
+
+	return rootCmd
+}
+
+func runList(cmd *cobra.Command, store *DataStore, prefix DataReference, limit int) error {
+	listed := 0
+	cursor := NewCursorAtStart()
+	for !IsCursorEnd(cursor) && (limit <= 0 || listed < limit) {
+		pageSize := listPageSize
+		if limit > 0 && limit-listed < pageSize {
+			pageSize = limit - listed
+		}
+
+		references, next, err := store.List(cmd.Context(), prefix, cursor, pageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, reference := range references {
+			fmt.Fprintln(cmd.OutOrStdout(), reference)
+		}
+
+		listed += len(references)
+		cursor = next
+	}
+
+	return nil
+}
+
+func runCat(cmd *cobra.Command, store *DataStore, reference DataReference, messageType string) error {
+	if len(messageType) == 0 {
+		rc, err := store.ReadRaw(cmd.Context(), reference)
+		if err != nil {
+			return err
+		}
+
+		defer rc.Close()
+		_, err = io.Copy(cmd.OutOrStdout(), rc)
+		return err
+	}
+
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(messageType))
+	if err != nil {
+		return fmt.Errorf("failed to find protobuf message type [%v]. Error: %w", messageType, err)
+	}
+
+	msg := proto.MessageV1(mt.New().Interface())
+	if err := store.ReadProtobuf(cmd.Context(), reference, msg); err != nil {
+		return err
+	}
+
+	marshaler := jsonpb.Marshaler{Indent: "  "}
+	if err := marshaler.Marshal(cmd.OutOrStdout(), msg); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout())
+	return nil
+}
+
+func runRemove(cmd *cobra.Command, store *DataStore, args []string, prefix bool, opts DeleteOptions) error {
+	var deleted []DataReference
+	var err error
+	if prefix {
+		for _, arg := range args {
+			var prefixDeleted []DataReference
+			prefixDeleted, err = store.DeletePrefix(cmd.Context(), DataReference(arg), opts)
+			deleted = append(deleted, prefixDeleted...)
+			if err != nil {
+				break
+			}
+		}
+	} else {
+		references := make([]DataReference, 0, len(args))
+		for _, arg := range args {
+			references = append(references, DataReference(arg))
+		}
+
+		deleted, err = store.DeleteMany(cmd.Context(), references, opts)
+	}
+
+	for _, reference := range deleted {
+		fmt.Fprintln(cmd.OutOrStdout(), reference)
+	}
+
+	return err
+}
+
+func runStat(cmd *cobra.Command, store *DataStore, reference DataReference) error {
+	md, err := store.Head(cmd.Context(), reference)
+	if err != nil {
+		return err
+	}
+
+	if !md.Exists() {
+		return fmt.Errorf("[%v] doesn't exist", reference)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Size: %v\n", md.Size())
+	fmt.Fprintf(out, "Etag: %v\n", md.Etag())
+	fmt.Fprintf(out, "Content type: %v\n", md.ContentType())
+	fmt.Fprintf(out, "Last modified: %v\n", md.LastModified().Format(time.RFC3339))
+
+	userMetadata := md.UserMetadata()
+	keys := make([]string, 0, len(userMetadata))
+	for key := range userMetadata {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	fmt.Fprintln(out, "Metadata:")
+	for _, key := range keys {
+		fmt.Fprintf(out, "  %v: %v\n", key, userMetadata[key])
+	}
+
+	return nil
+}
+
+func runSign(cmd *cobra.Command, store *DataStore, reference DataReference, method string,
+	properties SignedURLProperties) error {
+
+	found := false
+	for _, clientMethod := range stow.ClientMethodValues() {
+		if strings.EqualFold(clientMethod.String(), method) {
+			properties.Scope = clientMethod
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("method is of an invalid value [%v]", method)
+	}
+
+	resp, err := store.CreateSignedURL(cmd.Context(), reference, properties)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), resp.URL.String())
+	return nil
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/flyteorg/stow/local"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flyteorg/flytestdlib/config"
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+type mockAccessor struct {
+	config.Accessor
+}
+
+func (mockAccessor) UpdateConfig(ctx context.Context) error {
+	return nil
+}
+
+func newMockAccessor(options config.Options) config.Accessor {
+	return mockAccessor{}
+}
+
+func executeStorageCommand(root *cobra.Command, args ...string) (output string, err error) {
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetArgs(args)
+
+	_, err = root.ExecuteC()
+
+	return buf.String(), err
+}
+
+func TestNewStorageCommand(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "stdlib_storage_cmd")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	cfg := &Config{
+		Type: TypeLocal,
+		Stow: StowConfig{
+			Kind:   local.Kind,
+			Config: map[string]string{local.ConfigKeyPath: tmpDir},
+		},
+		InitContainer: "container",
+	}
+
+	previous := GetConfig()
+	require.NoError(t, ConfigSection.SetConfig(cfg))
+	defer func() {
+		assert.NoError(t, ConfigSection.SetConfig(previous))
+	}()
+
+	s, err := NewDataStore(cfg, promutils.NewTestScope())
+	require.NoError(t, err)
+	require.NoError(t, s.WriteRaw(context.TODO(), "file://container/a", 5, Options{}, bytes.NewReader([]byte("hello"))))
+
+	cmd := NewStorageCommand(newMockAccessor, promutils.NewTestScope())
+	for i := 0; i < 2; i++ {
+		output, err := executeStorageCommand(cmd, CommandStat, "file://container/a")
+		assert.NoError(t, err)
+		assert.Contains(t, output, "Size: 5\n")
+	}
+}
+
+func TestStorageCommand(t *testing.T) {
+	ctx := context.TODO()
+	s, err := NewDataStore(&Config{Type: TypeMemory}, promutils.NewTestScope())
+	require.NoError(t, err)
+	require.NoError(t, s.WriteProtobuf(ctx, "mem://container/dir/a", Options{}, &duration.Duration{Seconds: 5}))
+	require.NoError(t, s.WriteRaw(ctx, "mem://container/dir/b", 5, Options{Metadata: map[string]interface{}{"k": "v"}},
+		bytes.NewReader([]byte("hello"))))
+
+	// Flags keep their values across executions, so every execution uses a new command.
+	run := func(args ...string) (string, error) {
+		return executeStorageCommand(newStorageCommand(newMockAccessor,
+			func(ctx context.Context, cfg *Config) (*DataStore, error) {
+				return s, nil
+			}), args...)
+	}
+
+	t.Run(CommandList, func(t *testing.T) {
+		output, err := run(CommandList, "mem://container/dir")
+		assert.NoError(t, err)
+		assert.Equal(t, "mem://container/dir/a\nmem://container/dir/b\n", output)
+
+		output, err = run(CommandList, "mem://container/dir", "--limit", "1")
+		assert.NoError(t, err)
+		assert.Equal(t, "mem://container/dir/a\n", output)
+	})
+
+	t.Run(CommandCat, func(t *testing.T) {
+		output, err := run(CommandCat, "mem://container/dir/b")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", output)
+
+		output, err = run(CommandCat, "mem://container/dir/a", "--type",
+			"google.protobuf.Duration")
+		assert.NoError(t, err)
+		assert.Equal(t, "\"5s\"\n", output)
+
+		_, err = run(CommandCat, "mem://container/dir/a", "--type", "unknown.Message")
+		assert.Error(t, err)
+	})
+
+	t.Run(CommandStat, func(t *testing.T) {
+		output, err := run(CommandStat, "mem://container/dir/b")
+		assert.NoError(t, err)
+		assert.Contains(t, output, "Size: 5\n")
+		assert.Contains(t, output, "Metadata:\n  k: v\n")
+
+		_, err = run(CommandStat, "mem://container/missing")
+		assert.Error(t, err)
+	})
+
+	t.Run(CommandCopy, func(t *testing.T) {
+		_, err := run(CommandCopy, "mem://container/dir/b", "mem://container/other/b")
+		assert.NoError(t, err)
+
+		output, err := run(CommandCat, "mem://container/other/b")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", output)
+	})
+
+	t.Run(CommandRemove, func(t *testing.T) {
+		output, err := run(CommandRemove, "--prefix", "--dry-run", "mem://container/dir")
+		assert.NoError(t, err)
+		assert.Equal(t, "mem://container/dir/a\nmem://container/dir/b\n", output)
+
+		output, err = run(CommandRemove, "mem://container/other/b")
+		assert.NoError(t, err)
+		assert.Equal(t, "mem://container/other/b\n", output)
+
+		output, err = run(CommandList, "mem://container/other")
+		assert.NoError(t, err)
+		assert.Empty(t, output)
+	})
+
+	t.Run(CommandSign, func(t *testing.T) {
+		_, err := run(CommandSign, "mem://container/dir/b", "--method", "post")
+		assert.EqualError(t, err, "method is of an invalid value [post]")
+
+		_, err = run(CommandSign, "mem://container/dir/b", "--method", "put")
+		assert.EqualError(t, err, "unsupported")
+	})
+}
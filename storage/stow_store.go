@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"strconv"
 	"sync"
@@ -22,6 +24,7 @@ import (
 
 	"github.com/flyteorg/flytestdlib/contextutils"
 	"github.com/flyteorg/flytestdlib/errors"
+	"github.com/flyteorg/flytestdlib/ioutils"
 	"github.com/flyteorg/flytestdlib/logger"
 	"github.com/flyteorg/flytestdlib/promutils"
 	"github.com/flyteorg/flytestdlib/promutils/labeled"
@@ -100,13 +103,42 @@ type stowMetrics struct {
 
 	DeleteFailure labeled.Counter
 	DeleteLatency labeled.StopWatch
+
+	ListFailure labeled.Counter
+	ListLatency labeled.StopWatch
+}
+
+// CopyContainer can be implemented by a stow.Container whose backend can copy items server-side (e.g. S3 CopyObject
+// or GCS rewrite). S3 and GCS containers implement it. Copies within containers that don't are streamed through this
+// process.
+type CopyContainer interface {
+	stow.Container
+
+	// CopyItem copies the source item to destination within the container. If metadata is nil, the metadata of the
+	// source item is preserved. It may return an error for which stow.IsNotSupported is true to fall back to
+	// streaming.
+	CopyItem(source, destination string, metadata map[string]interface{}) (stow.Item, error)
+}
+
+// ConditionalContainer can be implemented by a stow.Container whose backend supports conditional writes natively
+// (e.g. S3 If-None-Match/If-Match or GCS generation preconditions). S3 and GCS containers implement it. Containers that
+// don't have write conditions checked by StowStore right before writing, which isn't atomic.
+type ConditionalContainer interface {
+	stow.Container
+
+	// PutIf writes the item only if it doesn't exist yet (ifNotExists) or if its Etag matches ifMatch (when not empty).
+	// It must return an error for which IsPreconditionFailed is true if the conditions don't hold.
+	PutIf(name string, r io.Reader, size int64, metadata map[string]interface{}, ifNotExists bool, ifMatch string) (
+		stow.Item, error)
 }
 
 // StowMetadata that will be returned
 type StowMetadata struct {
-	exists bool
-	size   int64
-	etag   string
+	exists       bool
+	size         int64
+	etag         string
+	userMetadata map[string]string
+	lastModified time.Time
 }
 
 func (s StowMetadata) Size() int64 {
@@ -121,6 +153,24 @@ func (s StowMetadata) Etag() string {
 	return s.etag
 }
 
+func (s StowMetadata) UserMetadata() map[string]string {
+	return s.userMetadata
+}
+
+func (s StowMetadata) ContentType() string {
+	return s.userMetadata[MetadataKeyContentType]
+}
+
+func (s StowMetadata) LastModified() time.Time {
+	return s.lastModified
+}
+
+// ContentMD5 returns the MD5 recorded in the user metadata or, failing that, the Etag if it's an MD5 (e.g. for objects
+// uploaded to S3 in a single part).
+func (s StowMetadata) ContentMD5() string {
+	return contentMD5(s.userMetadata, s.etag)
+}
+
 // Implements DataStore to talk to stow location store.
 type StowStore struct {
 	copyImpl
@@ -135,6 +185,7 @@ type StowStore struct {
 	dynamicContainerMap sync.Map
 	metrics             *stowMetrics
 	baseContainerFQN    DataReference
+	multipartCfg        MultipartUploadConfig
 }
 
 func (s *StowStore) CreateContainer(ctx context.Context, container string) (stow.Container, error) {
@@ -215,18 +266,26 @@ func (s *StowStore) Head(ctx context.Context, reference DataReference) (Metadata
 	t := s.metrics.HeadLatency.Start(ctx)
 	item, err := container.Item(k)
 	if err == nil {
-		if _, err = item.Metadata(); err != nil {
+		var md map[string]interface{}
+		var size int64
+		var etag string
+		var lastModified time.Time
+		if md, err = item.Metadata(); err != nil {
 			// Err will be caught below
-		} else if size, err := item.Size(); err != nil {
+		} else if size, err = item.Size(); err != nil {
 			// Err will be caught below
-		} else if etag, err := item.ETag(); err != nil {
+		} else if etag, err = item.ETag(); err != nil {
+			// Err will be caught below
+		} else if lastModified, err = item.LastMod(); err != nil {
 			// Err will be caught below
 		} else {
 			t.Stop()
 			return StowMetadata{
-				exists: true,
-				size:   size,
-				etag:   etag,
+				exists:       true,
+				size:         size,
+				etag:         etag,
+				userMetadata: toUserMetadata(md),
+				lastModified: lastModified,
 			}, nil
 		}
 	}
@@ -264,13 +323,117 @@ func (s *StowStore) ReadRaw(ctx context.Context, reference DataReference) (io.Re
 		return nil, err
 	}
 
+	if err = checkDownloadLimit(ctx, sizeBytes); err != nil {
+		return nil, err
+	}
+
+	return item.Open()
+}
+
+// ReadRawRange retrieves up to length bytes of the referenced object starting at offset. The download limit applies
+// to the size of the range rather than the size of the object.
+func (s *StowStore) ReadRawRange(ctx context.Context, reference DataReference, offset, length int64) (io.ReadCloser, error) {
+	_, c, k, err := reference.Split()
+	if err != nil {
+		s.metrics.BadReference.Inc(ctx)
+		return nil, err
+	}
+
+	container, err := s.getContainer(ctx, locationIDMain, c)
+	if err != nil {
+		return nil, err
+	}
+
+	t := s.metrics.ReadOpenLatency.Start(ctx)
+	item, err := container.Item(k)
+	if err != nil {
+		incFailureCounterForError(ctx, s.metrics.ReadFailure, err)
+		return nil, err
+	}
+	t.Stop()
+
+	sizeBytes, err := item.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := rangeBounds(sizeBytes, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkDownloadLimit(ctx, end-start); err != nil {
+		return nil, err
+	}
+
+	if start == end {
+		return ioutils.NewBytesReadCloser([]byte{}), nil
+	}
+
+	if ranger, ok := item.(stow.ItemRanger); ok {
+		// Stow ranges are inclusive of the end byte.
+		return ranger.OpenRange(uint64(start), uint64(end-1))
+	}
+
+	// The backend doesn't support ranged reads, skip to the start of the range and stop reading at its end.
+	rc, err := item.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.CopyN(ioutil.Discard, rc, start); err != nil {
+		if closeErr := rc.Close(); closeErr != nil {
+			logger.Warnf(ctx, "Failed to close reader [%v]. Error: %v", reference, closeErr)
+		}
+
+		return nil, errs.Wrapf(err, "failed to seek to offset [%v] of path [%v]", start, k)
+	}
+
+	return limitedReadCloser{
+		Reader: io.LimitReader(rc, end-start),
+		Closer: rc,
+	}, nil
+}
+
+// limitedReadCloser reads from a limited view of a reader while closing the original one.
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// skipDownloadLimitKey marks contexts of reads whose limits are enforced by the caller (e.g. per message of a protobuf
+// stream) rather than on the whole object.
+type skipDownloadLimitKey struct{}
+
+func withoutDownloadLimit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipDownloadLimitKey{}, true)
+}
+
+// withDownloadLimit restores the whole-object limit for reads with ctx. Layers that buffer whole objects (e.g. to
+// decrypt them) must stay within it even if their caller enforces its own limits as it reads.
+func withDownloadLimit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipDownloadLimitKey{}, false)
+}
+
+// skipsDownloadLimit gets a value indicating whether the caller reading with ctx enforces its own limits as it reads,
+// so the object must be streamed to it rather than buffered.
+func skipsDownloadLimit(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipDownloadLimitKey{}).(bool)
+	return skip
+}
+
+func checkDownloadLimit(ctx context.Context, sizeBytes int64) error {
+	if skipsDownloadLimit(ctx) {
+		return nil
+	}
+
 	if GetConfig().Limits.GetLimitMegabytes != 0 {
 		if sizeMbs := sizeBytes / MiB; sizeMbs > GetConfig().Limits.GetLimitMegabytes {
-			return nil, errors.Errorf(ErrExceedsLimit, "limit exceeded. %vmb > %vmb.", sizeMbs, GetConfig().Limits.GetLimitMegabytes)
+			return errors.Errorf(ErrExceedsLimit, "limit exceeded. %vmb > %vmb.", sizeMbs, GetConfig().Limits.GetLimitMegabytes)
 		}
 	}
 
-	return item.Open()
+	return nil
 }
 
 func (s *StowStore) WriteRaw(ctx context.Context, reference DataReference, size int64, opts Options, raw io.Reader) error {
@@ -286,7 +449,14 @@ func (s *StowStore) WriteRaw(ctx context.Context, reference DataReference, size
 	}
 
 	t := s.metrics.WriteLatency.Start(ctx)
-	_, err = container.Put(k, raw, size, opts.Metadata)
+	put := s.putFn(ctx, container, reference, opts)
+	_, err = put(k, raw, size, opts.Metadata)
+	if stow.IsNotSupported(errs.Cause(err)) && len(opts.Metadata) > 0 {
+		// Some backends (e.g. local) don't support metadata, and reject the write before reading any data.
+		logger.Debugf(ctx, "Metadata isn't supported by the store, writing [%v] without it.", k)
+		_, err = put(k, raw, size, nil)
+	}
+
 	if err != nil {
 		// If this error is due to the bucket not existing, first attempt to create it and retry the getContainer call.
 		if IsNotFound(err) || awsBucketIsNotFound(err) {
@@ -306,6 +476,115 @@ func (s *StowStore) WriteRaw(ctx context.Context, reference DataReference, size
 	return nil
 }
 
+// copyServerSide copies source to destination without streaming the object if both are in the same container and the
+// container implements CopyContainer. It returns false if the copy has to be streamed instead.
+func (s *StowStore) copyServerSide(ctx context.Context, source, destination DataReference, opts Options) (bool, error) {
+	if opts.hasWriteConditions() {
+		return false, nil
+	}
+
+	_, sourceContainer, sourceKey, err := source.Split()
+	if err != nil {
+		s.metrics.BadReference.Inc(ctx)
+		return false, err
+	}
+
+	_, destinationContainer, destinationKey, err := destination.Split()
+	if err != nil {
+		s.metrics.BadReference.Inc(ctx)
+		return false, err
+	}
+
+	if sourceContainer != destinationContainer {
+		return false, nil
+	}
+
+	container, err := s.getContainer(ctx, locationIDMain, sourceContainer)
+	if err != nil {
+		return false, err
+	}
+
+	copyContainer, ok := container.(CopyContainer)
+	if !ok {
+		return false, nil
+	}
+
+	t := s.metrics.WriteLatency.Start(ctx)
+	if _, err = copyContainer.CopyItem(sourceKey, destinationKey, opts.Metadata); err != nil {
+		if stow.IsNotSupported(errs.Cause(err)) {
+			return false, nil
+		}
+
+		incFailureCounterForError(ctx, s.metrics.WriteFailure, err)
+		return true, errs.Wrapf(err, "Failed to copy [%v] to [%v].", sourceKey, destinationKey)
+	}
+
+	t.Stop()
+	return true, nil
+}
+
+// putFn returns the function to write an item to the container, honoring the write conditions in opts. Containers
+// implementing ConditionalContainer check the conditions atomically. Otherwise, they're only checked on a best effort
+// basis with a Head right before writing, which doesn't detect concurrent writes in between.
+func (s *StowStore) putFn(ctx context.Context, container stow.Container, reference DataReference, opts Options) func(
+	name string, r io.Reader, size int64, metadata map[string]interface{}) (stow.Item, error) {
+
+	if !opts.hasWriteConditions() {
+		return container.Put
+	}
+
+	if conditionalContainer, ok := container.(ConditionalContainer); ok {
+		return func(name string, r io.Reader, size int64, metadata map[string]interface{}) (stow.Item, error) {
+			return conditionalContainer.PutIf(name, r, size, metadata, opts.IfNotExists, opts.IfMatch)
+		}
+	}
+
+	return func(name string, r io.Reader, size int64, metadata map[string]interface{}) (stow.Item, error) {
+		md, err := s.Head(ctx, reference)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = checkWriteConditions(reference, md, opts); err != nil {
+			return nil, err
+		}
+
+		return container.Put(name, r, size, metadata)
+	}
+}
+
+// OpenWriter returns a writer that streams data to the referenced location. If the container supports multipart
+// uploads, parts are uploaded in parallel as they fill up. Otherwise, data is spooled to a local temp file and written
+// in a single call on Close.
+func (s *StowStore) OpenWriter(ctx context.Context, reference DataReference, opts Options) (io.WriteCloser, error) {
+	_, c, k, err := reference.Split()
+	if err != nil {
+		s.metrics.BadReference.Inc(ctx)
+		return nil, err
+	}
+
+	container, err := s.getContainer(ctx, locationIDMain, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Conditional writes are checked by WriteRaw when the spooled data is written.
+	multipartContainer, ok := container.(MultipartContainer)
+	if !ok || opts.hasWriteConditions() {
+		return newSpoolingWriter(ctx, func(size int64, raw io.Reader) error {
+			return s.WriteRaw(ctx, reference, size, opts, raw)
+		})
+	}
+
+	upload, err := multipartContainer.InitiateMultipartUpload(k, opts.Metadata)
+	if err != nil {
+		incFailureCounterForError(ctx, s.metrics.WriteFailure, err)
+		return nil, errs.Wrapf(err, "failed to initiate multipart upload to path [%v]", k)
+	}
+
+	return newMultipartWriter(ctx, upload, s.multipartCfg), nil
+}
+
 // Delete removes the referenced data from the blob store.
 func (s *StowStore) Delete(ctx context.Context, reference DataReference) error {
 	_, c, k, err := reference.Split()
@@ -330,6 +609,54 @@ func (s *StowStore) Delete(ctx context.Context, reference DataReference) error {
 	return nil
 }
 
+// List retrieves up to limit references that start with the given prefix.
+func (s *StowStore) List(ctx context.Context, prefix DataReference, cursor Cursor, limit int) ([]DataReference, Cursor, error) {
+	if IsCursorEnd(cursor) {
+		return []DataReference{}, cursor, nil
+	}
+
+	if limit <= 0 {
+		return nil, cursor, fmt.Errorf("limit must be positive, found [%v]", limit)
+	}
+
+	scheme, c, k, err := prefix.Split()
+	if err != nil {
+		s.metrics.BadReference.Inc(ctx)
+		return nil, cursor, err
+	}
+
+	container, err := s.getContainer(ctx, locationIDMain, c)
+	if err != nil {
+		return nil, cursor, err
+	}
+
+	stowCursor := stow.CursorStart
+	if cursor.cursorState == AtCustomPosCursorState {
+		stowCursor = cursor.customPosition
+	}
+
+	t := s.metrics.ListLatency.Start(ctx)
+	items, nextStowCursor, err := container.Items(k, stowCursor, limit)
+	if err != nil {
+		incFailureCounterForError(ctx, s.metrics.ListFailure, err)
+		return nil, cursor, errs.Wrapf(err, "failed to list items with prefix %q", k)
+	}
+
+	t.Stop()
+
+	results := make([]DataReference, 0, len(items))
+	for _, item := range items {
+		// Names are relative to the container, unlike the IDs of some backends (e.g. absolute paths of local files).
+		results = append(results, DataReference(fmt.Sprintf("%s://%s/%s", scheme, c, item.Name())))
+	}
+
+	if stow.IsCursorEnd(nextStowCursor) {
+		return results, NewCursorAtEnd(), nil
+	}
+
+	return results, NewCursorFromCustomPosition(nextStowCursor), nil
+}
+
 func (s *StowStore) GetBaseContainerFQN(ctx context.Context) DataReference {
 	return s.baseContainerFQN
 }
@@ -428,6 +755,9 @@ func newStowMetrics(scope promutils.Scope) *stowMetrics {
 
 		DeleteFailure: labeled.NewCounter("delete_failure", "Indicates failure in removing/DELETE for a given reference", scope, labeled.EmitUnlabeledMetric, failureTypeOption),
 		DeleteLatency: labeled.NewStopWatch("delete", "Time to delete an object irrespective of size", time.Millisecond, scope, labeled.EmitUnlabeledMetric),
+
+		ListFailure: labeled.NewCounter("list_failure", "Indicates failure in listing items for a given prefix", scope, labeled.EmitUnlabeledMetric, failureTypeOption),
+		ListLatency: labeled.NewStopWatch("list", "Time to list a single page of items under a prefix", time.Millisecond, scope, labeled.EmitUnlabeledMetric),
 	}
 }
 
@@ -454,7 +784,8 @@ func newStowRawStore(_ context.Context, cfg *Config, metrics *dataStoreMetrics)
 		return nil, errs.Errorf("unsupported stow.kind [%s], add support in flytestdlib?", kind)
 	}
 
-	loc, err := stow.Dial(kind, cfgMap)
+	httpClient := newHTTPClient(cfg.DefaultHTTPClient)
+	loc, err := dialWithHTTPClient(kind, cfgMap, httpClient)
 	if err != nil {
 		return emptyStore, fmt.Errorf("unable to configure the storage for %s. Error: %v", kind, err)
 	}
@@ -463,13 +794,44 @@ func newStowRawStore(_ context.Context, cfg *Config, metrics *dataStoreMetrics)
 	if len(cfg.SignedURL.StowConfigOverride) > 0 {
 		var newCfg stow.ConfigMap = make(map[string]string, len(cfgMap))
 		MergeMaps(newCfg, cfgMap, cfg.SignedURL.StowConfigOverride)
-		signedURLLoc, err = stow.Dial(kind, newCfg)
+		signedURLLoc, err = dialWithHTTPClient(kind, newCfg, httpClient)
 		if err != nil {
 			return emptyStore, fmt.Errorf("unable to configure the storage for %s. Error: %v", kind, err)
 		}
 	}
 
-	return NewStowRawStore(fn(cfg.InitContainer), loc, signedURLLoc, cfg.MultiContainerEnabled, metrics)
+	store, err := NewStowRawStore(fn(cfg.InitContainer), loc, signedURLLoc, cfg.MultiContainerEnabled, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	store.multipartCfg = cfg.MultipartUpload
+	return store, nil
+}
+
+// dialWithHTTPClient dials a stow location whose S3 requests are sent through client, or the default client if it's nil.
+// S3 and GCS locations are wrapped so that their containers support multipart uploads (S3) and atomic conditional
+// writes. The S3 wrapper sends container requests through its own client since stow doesn't accept one.
+func dialWithHTTPClient(kind string, cfgMap stow.ConfigMap, client *http.Client) (stow.Location, error) {
+	if kind == google.Kind {
+		loc, err := stow.Dial(kind, cfgMap)
+		if err != nil {
+			return nil, err
+		}
+
+		return gcsLocation{Location: loc}, nil
+	}
+
+	if kind != s3.Kind {
+		return stow.Dial(kind, cfgMap)
+	}
+
+	loc, err := stow.Dial(s3.Kind, cfgMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return newS3Location(loc, cfgMap, client)
 }
 
 func legacyS3ConfigMap(cfg ConnectionConfig) stow.ConfigMap {
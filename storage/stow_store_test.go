@@ -10,6 +10,8 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,6 +25,7 @@ import (
 	"github.com/flyteorg/stow/s3"
 	"github.com/flyteorg/stow/swift"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/flyteorg/flytestdlib/config"
@@ -73,8 +76,28 @@ func (m mockStowContainer) Item(id string) (stow.Item, error) {
 	return nil, stow.ErrNotFound
 }
 
-func (mockStowContainer) Items(prefix, cursor string, count int) ([]stow.Item, string, error) {
-	return []stow.Item{}, "", nil
+func (m mockStowContainer) Items(prefix, cursor string, count int) ([]stow.Item, string, error) {
+	ids := make([]string, 0, len(m.items))
+	for id := range m.items {
+		if strings.HasPrefix(id, prefix) && id > cursor {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+	if len(ids) <= count {
+		cursor = stow.CursorStart
+	} else {
+		ids = ids[:count]
+		cursor = ids[count-1]
+	}
+
+	items := make([]stow.Item, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, m.items[id])
+	}
+
+	return items, cursor, nil
 }
 
 func (m mockStowContainer) RemoveItem(id string) error {
@@ -91,7 +114,12 @@ func (m *mockStowContainer) Put(name string, r io.Reader, size int64, metadata m
 	if m.putCB != nil {
 		return m.putCB(name, r, size, metadata)
 	}
-	item := mockStowItem{url: name, size: size}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	item := mockStowItem{url: name, size: size, content: content, metadata: metadata, lastMod: time.Now()}
 	m.items[name] = item
 	return item, nil
 }
@@ -104,8 +132,12 @@ func newMockStowContainer(id string) *mockStowContainer {
 }
 
 type mockStowItem struct {
-	url  string
-	size int64
+	url      string
+	size     int64
+	content  []byte
+	etag     string
+	metadata map[string]interface{}
+	lastMod  time.Time
 }
 
 func (m mockStowItem) ID() string {
@@ -129,20 +161,28 @@ func (m mockStowItem) Size() (int64, error) {
 	return m.size, nil
 }
 
-func (mockStowItem) Open() (io.ReadCloser, error) {
-	return ioutil.NopCloser(bytes.NewReader([]byte{})), nil
+func (m mockStowItem) Open() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(m.content)), nil
 }
 
-func (mockStowItem) ETag() (string, error) {
-	return "", nil
+func (m mockStowItem) ETag() (string, error) {
+	return m.etag, nil
 }
 
-func (mockStowItem) LastMod() (time.Time, error) {
-	return time.Now(), nil
+func (m mockStowItem) LastMod() (time.Time, error) {
+	if m.lastMod.IsZero() {
+		return time.Now(), nil
+	}
+
+	return m.lastMod, nil
 }
 
-func (mockStowItem) Metadata() (map[string]interface{}, error) {
-	return map[string]interface{}{}, nil
+func (m mockStowItem) Metadata() (map[string]interface{}, error) {
+	if m.metadata == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	return m.metadata, nil
 }
 
 func TestAwsBucketIsNotFound(t *testing.T) {
@@ -361,6 +401,71 @@ func TestStowStore_ReadRaw(t *testing.T) {
 	})
 }
 
+func TestStowStore_ReadRawRange(t *testing.T) {
+	const container = "container"
+	ctx := context.TODO()
+	fn := fQNFn["s3"]
+	GetConfig().Limits.GetLimitMegabytes = 2
+	defer func() {
+		GetConfig().Limits.GetLimitMegabytes = defaultConfig.Limits.GetLimitMegabytes
+	}()
+
+	s, err := NewStowRawStore(fn(container), &mockStowLoc{
+		ContainerCb: func(id string) (stow.Container, error) {
+			if id == container {
+				return newMockStowContainer(container), nil
+			}
+			return nil, fmt.Errorf("container is not supported")
+		},
+		CreateContainerCb: func(name string) (stow.Container, error) {
+			if name == container {
+				return newMockStowContainer(container), nil
+			}
+			return nil, fmt.Errorf("container is not supported")
+		},
+	}, nil, false, metrics)
+	assert.NoError(t, err)
+
+	data := []byte("hello world")
+	assert.NoError(t, s.WriteRaw(ctx, "s3://container/path", int64(len(data)), Options{}, bytes.NewReader(data)))
+
+	t.Run("Range", func(t *testing.T) {
+		rc, err := s.ReadRawRange(ctx, "s3://container/path", 6, 3)
+		assert.NoError(t, err)
+		raw, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, "wor", string(raw))
+		assert.NoError(t, rc.Close())
+	})
+
+	t.Run("Until the end", func(t *testing.T) {
+		rc, err := s.ReadRawRange(ctx, "s3://container/path", 6, -1)
+		assert.NoError(t, err)
+		raw, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.Equal(t, "world", string(raw))
+	})
+
+	t.Run("Invalid offset", func(t *testing.T) {
+		_, err := s.ReadRawRange(ctx, "s3://container/path", 20, 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("Limit applies per range", func(t *testing.T) {
+		big := writeTestFileWithSize(ctx, t, s, "s3://container/big", 3*MiB)
+		_, err := s.ReadRawRange(ctx, big, 0, MiB)
+		assert.NoError(t, err)
+
+		_, err = s.ReadRawRange(ctx, big, 0, -1)
+		assert.True(t, IsExceedsLimit(err))
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := s.ReadRawRange(ctx, "s3://container/missing", 0, 1)
+		assert.True(t, IsNotFound(err))
+	})
+}
+
 func TestNewLocalStore(t *testing.T) {
 	labeled.SetMetricKeys(contextutils.ProjectKey, contextutils.DomainKey, contextutils.WorkflowIDKey, contextutils.TaskIDKey)
 	t.Run("Valid config", func(t *testing.T) {
@@ -621,6 +726,131 @@ func TestStowStore_WriteRaw(t *testing.T) {
 		err = s.WriteRaw(context.TODO(), DataReference("s3://container/path"), 0, Options{}, bytes.NewReader([]byte{}))
 		assert.EqualError(t, err, "Failed to write data [0b] to path [path].: foo")
 	})
+	t.Run("drop unsupported metadata", func(t *testing.T) {
+		var written map[string]interface{}
+		s, err := NewStowRawStore(fn(container), &mockStowLoc{
+			ContainerCb: func(id string) (stow.Container, error) {
+				mockStowContainer := newMockStowContainer(container)
+				mockStowContainer.putCB = func(name string, r io.Reader, size int64, metadata map[string]interface{}) (stow.Item, error) {
+					if len(metadata) > 0 {
+						return nil, stow.NotSupported("metadata")
+					}
+
+					written = metadata
+					return mockStowItem{url: name}, nil
+				}
+				return mockStowContainer, nil
+			},
+		}, nil, false, metrics)
+		assert.NoError(t, err)
+		err = s.WriteRaw(context.TODO(), DataReference("s3://container/path"), 0, Options{
+			Metadata: map[string]interface{}{"key": "value"},
+		}, bytes.NewReader([]byte{}))
+		assert.NoError(t, err)
+		assert.Nil(t, written)
+	})
+}
+
+// conditionalMockStowContainer implements ConditionalContainer by recording the conditions it was called with.
+type conditionalMockStowContainer struct {
+	*mockStowContainer
+	ifNotExists bool
+	ifMatch     string
+}
+
+func (c *conditionalMockStowContainer) PutIf(name string, r io.Reader, size int64, metadata map[string]interface{},
+	ifNotExists bool, ifMatch string) (stow.Item, error) {
+	c.ifNotExists = ifNotExists
+	c.ifMatch = ifMatch
+	return c.Put(name, r, size, metadata)
+}
+
+func TestStowStore_ConditionalWrite(t *testing.T) {
+	labeled.SetMetricKeys(contextutils.ProjectKey, contextutils.DomainKey, contextutils.WorkflowIDKey, contextutils.TaskIDKey)
+	const container = "container"
+	write := func(s RawStore, data string, opts Options) error {
+		return s.WriteRaw(context.TODO(), "s3://container/path", int64(len(data)), opts, bytes.NewReader([]byte(data)))
+	}
+
+	t.Run("Checked by the store", func(t *testing.T) {
+		mockContainer := newMockStowContainer(container)
+		s, err := NewStowRawStore(fQNFn["s3"](container), &mockStowLoc{
+			ContainerCb: func(id string) (stow.Container, error) {
+				return mockContainer, nil
+			},
+		}, nil, false, metrics)
+		assert.NoError(t, err)
+
+		assert.NoError(t, write(s, "a", Options{IfNotExists: true}))
+		assert.True(t, IsPreconditionFailed(write(s, "b", Options{IfNotExists: true})))
+		assert.True(t, IsPreconditionFailed(write(s, "b", Options{IfMatch: "etag"})))
+
+		mockContainer.items["path"] = mockStowItem{url: "path", etag: "etag"}
+		assert.NoError(t, write(s, "b", Options{IfMatch: "etag"}))
+		assert.Equal(t, []byte("b"), mockContainer.items["path"].content)
+	})
+
+	t.Run("Checked by the container", func(t *testing.T) {
+		mockContainer := &conditionalMockStowContainer{mockStowContainer: newMockStowContainer(container)}
+		s, err := NewStowRawStore(fQNFn["s3"](container), &mockStowLoc{
+			ContainerCb: func(id string) (stow.Container, error) {
+				return mockContainer, nil
+			},
+		}, nil, false, metrics)
+		assert.NoError(t, err)
+
+		assert.NoError(t, write(s, "a", Options{IfMatch: "etag"}))
+		assert.Equal(t, "etag", mockContainer.ifMatch)
+		assert.False(t, mockContainer.ifNotExists)
+	})
+}
+
+func TestStowStore_Head(t *testing.T) {
+	labeled.SetMetricKeys(contextutils.ProjectKey, contextutils.DomainKey, contextutils.WorkflowIDKey, contextutils.TaskIDKey)
+	const container = "container"
+	mockContainer := newMockStowContainer(container)
+	s, err := NewStowRawStore(fQNFn["s3"](container), &mockStowLoc{
+		ContainerCb: func(id string) (stow.Container, error) {
+			return mockContainer, nil
+		},
+	}, nil, false, metrics)
+	assert.NoError(t, err)
+
+	t.Run("Not found", func(t *testing.T) {
+		md, err := s.Head(context.TODO(), "s3://container/missing")
+		assert.NoError(t, err)
+		assert.False(t, md.Exists())
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		lastMod := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+		mockContainer.items["path"] = mockStowItem{
+			url:     "path",
+			size:    5,
+			etag:    "5D41402ABC4B2A76B9719D911017C592",
+			lastMod: lastMod,
+			metadata: map[string]interface{}{
+				"Content-Type": "application/json",
+				"owner":        "someone",
+			},
+		}
+
+		md, err := s.Head(context.TODO(), "s3://container/path")
+		assert.NoError(t, err)
+		assert.True(t, md.Exists())
+		assert.Equal(t, int64(5), md.Size())
+		assert.Equal(t, "application/json", md.ContentType())
+		assert.Equal(t, map[string]string{"content-type": "application/json", "owner": "someone"}, md.UserMetadata())
+		assert.Equal(t, lastMod, md.LastModified())
+		assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", md.ContentMD5())
+	})
+
+	t.Run("Multipart Etag", func(t *testing.T) {
+		mockContainer.items["multipart"] = mockStowItem{url: "multipart", etag: "5d41402abc4b2a76b9719d911017c592-2"}
+		md, err := s.Head(context.TODO(), "s3://container/multipart")
+		assert.NoError(t, err)
+		assert.Empty(t, md.ContentMD5())
+	})
 }
 
 func TestStowStore_fQNFn(t *testing.T) {
@@ -790,6 +1020,133 @@ func TestStowStore_Delete(t *testing.T) {
 	})
 }
 
+func TestStowStore_OpenWriter(t *testing.T) {
+	const container = "container"
+	ctx := context.TODO()
+	fn := fQNFn["s3"]
+
+	s, err := NewStowRawStore(fn(container), &mockStowLoc{
+		ContainerCb: func(id string) (stow.Container, error) {
+			if id == container {
+				return newMockStowContainer(container), nil
+			}
+			return nil, fmt.Errorf("container is not supported")
+		},
+		CreateContainerCb: func(name string) (stow.Container, error) {
+			if name == container {
+				return newMockStowContainer(container), nil
+			}
+			return nil, fmt.Errorf("container is not supported")
+		},
+	}, nil, false, metrics)
+	assert.NoError(t, err)
+
+	t.Run("Spools when multipart is unsupported", func(t *testing.T) {
+		w, err := s.OpenWriter(ctx, "s3://container/path", Options{})
+		assert.NoError(t, err)
+		_, err = w.Write([]byte("hello"))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		metadata, err := s.Head(ctx, "s3://container/path")
+		assert.NoError(t, err)
+		assert.True(t, metadata.Exists())
+		assert.Equal(t, int64(5), metadata.Size())
+	})
+
+	t.Run("Unknown container", func(t *testing.T) {
+		_, err := s.OpenWriter(ctx, "s3://bad-container/path", Options{})
+		assert.Error(t, err)
+	})
+}
+
+func TestStowStore_List(t *testing.T) {
+	const container = "container"
+	ctx := context.TODO()
+	fn := fQNFn["s3"]
+
+	s, err := NewStowRawStore(fn(container), &mockStowLoc{
+		ContainerCb: func(id string) (stow.Container, error) {
+			if id == container {
+				return newMockStowContainer(container), nil
+			}
+			return nil, fmt.Errorf("container is not supported")
+		},
+		CreateContainerCb: func(name string) (stow.Container, error) {
+			if name == container {
+				return newMockStowContainer(container), nil
+			}
+			return nil, fmt.Errorf("container is not supported")
+		},
+	}, nil, false, metrics)
+	assert.NoError(t, err)
+
+	writeTestFile(ctx, t, s, "s3://container/a/1")
+	writeTestFile(ctx, t, s, "s3://container/a/2")
+	writeTestFile(ctx, t, s, "s3://container/a/3")
+	writeTestFile(ctx, t, s, "s3://container/b/1")
+
+	t.Run("Paginated", func(t *testing.T) {
+		refs, cursor, err := s.List(ctx, "s3://container/a", NewCursorAtStart(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"s3://container/a/1", "s3://container/a/2"}, refs)
+		assert.False(t, IsCursorEnd(cursor))
+
+		refs, cursor, err = s.List(ctx, "s3://container/a", cursor, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []DataReference{"s3://container/a/3"}, refs)
+		assert.True(t, IsCursorEnd(cursor))
+
+		refs, cursor, err = s.List(ctx, "s3://container/a", cursor, 2)
+		assert.NoError(t, err)
+		assert.Empty(t, refs)
+		assert.True(t, IsCursorEnd(cursor))
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		_, _, err := s.List(ctx, "s3://container/a", NewCursorAtStart(), 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown container", func(t *testing.T) {
+		_, _, err := s.List(ctx, "s3://bad-container/a", NewCursorAtStart(), 10)
+		assert.Error(t, err)
+		assert.True(t, errors.Is(err, stow.ErrNotFound))
+	})
+}
+
+func TestStowStore_ListLocal(t *testing.T) {
+	ctx := context.TODO()
+	s, err := newStowRawStore(ctx, &Config{
+		Stow: StowConfig{
+			Kind:   local.Kind,
+			Config: map[string]string{local.ConfigKeyPath: t.TempDir()},
+		},
+		InitContainer: "container",
+	}, metrics)
+	assert.NoError(t, err)
+
+	for _, ref := range []DataReference{"file://container/a/1", "file://container/a/2", "file://container/b/1"} {
+		assert.NoError(t, s.WriteRaw(ctx, ref, 1, Options{}, bytes.NewReader([]byte("a"))))
+	}
+
+	refs, cursor, err := s.List(ctx, "file://container/a", NewCursorAtStart(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []DataReference{"file://container/a/1", "file://container/a/2"}, refs)
+	assert.False(t, IsCursorEnd(cursor))
+
+	// The local backend counts all files of the container towards the limit, then filters them by prefix.
+	refs, cursor, err = s.List(ctx, "file://container/a", cursor, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, refs)
+	assert.True(t, IsCursorEnd(cursor))
+
+	// Listed references can be passed back to the store.
+	md, err := s.Head(ctx, "file://container/a/1")
+	assert.NoError(t, err)
+	assert.True(t, md.Exists())
+}
+
 func writeTestFile(ctx context.Context, t *testing.T, s *StowStore, path string) DataReference {
 	return writeTestFileWithSize(ctx, t, s, path, 0)
 }
@@ -806,3 +1163,75 @@ func writeTestFileWithSize(ctx context.Context, t *testing.T, s *StowStore, path
 
 	return reference
 }
+
+// copyMockStowContainer implements CopyContainer by copying items within the mock container.
+type copyMockStowContainer struct {
+	*mockStowContainer
+	notSupported bool
+	copies       int
+}
+
+func (c *copyMockStowContainer) CopyItem(source, destination string, metadata map[string]interface{}) (stow.Item, error) {
+	if c.notSupported {
+		return nil, stow.NotSupported("copy")
+	}
+
+	item, found := c.items[source]
+	if !found {
+		return nil, stow.ErrNotFound
+	}
+
+	c.copies++
+	item.url = destination
+	if metadata != nil {
+		item.metadata = metadata
+	}
+
+	c.items[destination] = item
+	return item, nil
+}
+
+func TestStowStore_CopyRaw(t *testing.T) {
+	labeled.SetMetricKeys(contextutils.ProjectKey, contextutils.DomainKey, contextutils.WorkflowIDKey, contextutils.TaskIDKey)
+	ctx := context.TODO()
+	newStore := func(t *testing.T) (*StowStore, *copyMockStowContainer) {
+		mockContainer := &copyMockStowContainer{mockStowContainer: newMockStowContainer("container")}
+		mockContainer.items["source"] = mockStowItem{url: "source", size: 5, content: []byte("hello")}
+		otherContainer := newMockStowContainer("other")
+		s, err := NewStowRawStore(fQNFn["s3"]("container"), &mockStowLoc{
+			ContainerCb: func(id string) (stow.Container, error) {
+				if id == "other" {
+					return otherContainer, nil
+				}
+
+				return mockContainer, nil
+			},
+		}, nil, true, metrics)
+		assert.NoError(t, err)
+		return s, mockContainer
+	}
+
+	t.Run("Server-side", func(t *testing.T) {
+		s, mockContainer := newStore(t)
+		serverSide := testutil.ToFloat64(metrics.copyMetrics.ServerSideCopies)
+		assert.NoError(t, s.CopyRaw(ctx, "s3://container/source", "s3://container/destination", Options{}))
+		assert.Equal(t, 1, mockContainer.copies)
+		assert.Equal(t, []byte("hello"), mockContainer.items["destination"].content)
+		assert.Equal(t, serverSide+1, testutil.ToFloat64(metrics.copyMetrics.ServerSideCopies))
+
+		err := s.CopyRaw(ctx, "s3://container/missing", "s3://container/destination", Options{})
+		assert.True(t, IsNotFound(err), err)
+	})
+
+	t.Run("Streamed", func(t *testing.T) {
+		s, mockContainer := newStore(t)
+		streamed := testutil.ToFloat64(metrics.copyMetrics.StreamedCopies)
+		assert.NoError(t, s.CopyRaw(ctx, "s3://container/source", "s3://other/destination", Options{}))
+		assert.NoError(t, s.CopyRaw(ctx, "s3://container/source", "s3://container/conditional", Options{IfNotExists: true}))
+		mockContainer.notSupported = true
+		assert.NoError(t, s.CopyRaw(ctx, "s3://container/source", "s3://container/destination", Options{}))
+		assert.Equal(t, 0, mockContainer.copies)
+		assert.Equal(t, []byte("hello"), mockContainer.items["destination"].content)
+		assert.Equal(t, streamed+3, testutil.ToFloat64(metrics.copyMetrics.StreamedCopies))
+	})
+}
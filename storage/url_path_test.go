@@ -15,6 +15,32 @@ func TestUrlPathConstructor_ConstructReference(t *testing.T) {
 		assert.Equal(t, "/hello/key1/key2/key3", r.String())
 	})
 
+	t.Run("escapes keys", func(t *testing.T) {
+		r, err := s.ConstructReference(context.TODO(), DataReference("s3://bucket/p"), "a b")
+		assert.NoError(t, err)
+		assert.Equal(t, "s3://bucket/p/a%20b", r.String())
+	})
+
+	t.Run("empty last key", func(t *testing.T) {
+		r, err := s.ConstructReference(context.TODO(), DataReference("s3://bucket/p"), "k", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "s3://bucket/p/k/", r.String())
+
+		r, err = s.ConstructReference(context.TODO(), DataReference("s3://bucket/p"))
+		assert.NoError(t, err)
+		assert.Equal(t, "s3://bucket/p/", r.String())
+	})
+
+	t.Run("resolves keys like relative URLs", func(t *testing.T) {
+		r, err := s.ConstructReference(context.TODO(), DataReference("s3://bucket/p"), "", "x")
+		assert.NoError(t, err)
+		assert.Equal(t, "s3://bucket/x", r.String())
+
+		r, err = s.ConstructReference(context.TODO(), DataReference("s3://bucket/p"), "a//b")
+		assert.NoError(t, err)
+		assert.Equal(t, "s3://bucket/p/a//b", r.String())
+	})
+
 	t.Run("failed to parse base path", func(t *testing.T) {
 		_, err := s.ConstructReference(context.TODO(), DataReference("*&^#&$@:%//"), "key1", "key2/", "key3")
 		assert.Error(t, err)
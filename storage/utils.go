@@ -2,7 +2,17 @@ package storage
 
 import (
 	"context"
+	"crypto/md5" // #nosec
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -17,6 +27,12 @@ import (
 var (
 	ErrExceedsLimit       stdErrs.ErrorCode = "LIMIT_EXCEEDED"
 	ErrFailedToWriteCache stdErrs.ErrorCode = "CACHE_WRITE_FAILED"
+	ErrFailedToEncrypt    stdErrs.ErrorCode = "ENCRYPTION_FAILED"
+	ErrFailedToDecrypt    stdErrs.ErrorCode = "DECRYPTION_FAILED"
+	ErrPreconditionFailed stdErrs.ErrorCode = "PRECONDITION_FAILED"
+	ErrHashMismatch       stdErrs.ErrorCode = "HASH_MISMATCH"
+	ErrChecksumMismatch   stdErrs.ErrorCode = "CHECKSUM_MISMATCH"
+	ErrInjectedFault      stdErrs.ErrorCode = "INJECTED_FAULT"
 )
 
 const (
@@ -58,6 +74,150 @@ func IsFailedWriteToCache(err error) bool {
 	return stdErrs.IsCausedBy(err, ErrFailedToWriteCache)
 }
 
+// IsFailedToDecrypt gets a value indicating whether the root cause of error is a failure to decrypt an object.
+func IsFailedToDecrypt(err error) bool {
+	return stdErrs.IsCausedBy(err, ErrFailedToDecrypt)
+}
+
+// IsPreconditionFailed gets a value indicating whether the root cause of error is a conditional write whose conditions
+// (see Options.IfNotExists and Options.IfMatch) didn't hold.
+func IsPreconditionFailed(err error) bool {
+	if stdErrs.IsCausedBy(err, ErrPreconditionFailed) {
+		return true
+	}
+
+	var statusErr interface{ StatusCode() int }
+	return errors.As(err, &statusErr) && statusErr.StatusCode() == http.StatusPreconditionFailed
+}
+
+// IsHashMismatch gets a value indicating whether the root cause of error is content read from a content-addressed
+// reference that doesn't match its hash.
+func IsHashMismatch(err error) bool {
+	return stdErrs.IsCausedBy(err, ErrHashMismatch)
+}
+
+// IsChecksumMismatch gets a value indicating whether the root cause of error is data read from an object that doesn't
+// match the checksum recorded when it was written.
+func IsChecksumMismatch(err error) bool {
+	return stdErrs.IsCausedBy(err, ErrChecksumMismatch)
+}
+
+// IsInjectedFault gets a value indicating whether the root cause of error is a fault injected by a
+// FaultInjectingRawStore.
+func IsInjectedFault(err error) bool {
+	return stdErrs.IsCausedBy(err, ErrInjectedFault)
+}
+
+// checkWriteConditions returns an ErrPreconditionFailed error if the write conditions in opts don't hold for the
+// object described by md.
+func checkWriteConditions(reference DataReference, md Metadata, opts Options) error {
+	if opts.IfNotExists && md.Exists() {
+		return stdErrs.Errorf(ErrPreconditionFailed, "[%v] already exists", reference)
+	}
+
+	if len(opts.IfMatch) > 0 && (!md.Exists() || md.Etag() != opts.IfMatch) {
+		return stdErrs.Errorf(ErrPreconditionFailed, "Etag of [%v] doesn't match [%v]", reference, opts.IfMatch)
+	}
+
+	return nil
+}
+
+// retryableAWSErrorCodes are the error codes returned by S3 (and compatible APIs) for throttled or transiently failed
+// requests.
+var retryableAWSErrorCodes = map[string]bool{
+	"RequestTimeout":          true,
+	"RequestTimeoutException": true,
+	"SlowDown":                true,
+	"Throttling":              true,
+	"ThrottlingException":     true,
+	"InternalError":           true,
+	"ServiceUnavailable":      true,
+}
+
+// IsRetryable gets a value indicating whether the error is transient and the failed operation can be retried. Errors
+// that won't change on retry (e.g. Not Found or limit exceeded) and context cancellation are never retryable. Injected
+// faults other than Not Found are retryable so that they exercise retries like transient backend errors.
+func IsRetryable(err error) bool {
+	if err == nil || IsNotFound(err) || IsExists(err) || IsExceedsLimit(err) || IsFailedToDecrypt(err) ||
+		IsPreconditionFailed(err) {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if IsInjectedFault(err) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		if code := statusErr.StatusCode(); code == http.StatusTooManyRequests || code >= http.StatusInternalServerError {
+			return true
+		}
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && retryableAWSErrorCodes[awsErr.Code()] {
+		return true
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+
+	return false
+}
+
+// toUserMetadata converts metadata reported by a store to user metadata, lower-casing keys.
+func toUserMetadata(md map[string]interface{}) map[string]string {
+	res := make(map[string]string, len(md))
+	for k, v := range md {
+		res[strings.ToLower(k)] = fmt.Sprint(v)
+	}
+
+	return res
+}
+
+// contentMD5 returns the MD5 recorded in the user metadata or, failing that, the etag if it's a plain MD5 as is the case
+// for objects uploaded to S3 in a single part.
+func contentMD5(userMetadata map[string]string, etag string) string {
+	if recorded, found := userMetadata[MetadataKeyContentMD5]; found {
+		return recorded
+	}
+
+	if len(etag) == hex.EncodedLen(md5.Size) {
+		if _, err := hex.DecodeString(etag); err == nil {
+			return strings.ToLower(etag)
+		}
+	}
+
+	return ""
+}
+
+// rangeBounds computes the [start, end) bounds of reading up to length bytes at offset of an object of the given size.
+// A negative length reads until the end of the object.
+func rangeBounds(size, offset, length int64) (start, end int64, err error) {
+	if offset < 0 || offset > size {
+		return 0, 0, fmt.Errorf("offset [%v] is out of range for an object of size [%v]", offset, size)
+	}
+
+	end = size
+	if length >= 0 && offset+length < size {
+		end = offset + length
+	}
+
+	return offset, end, nil
+}
+
 func MapStrings(mapper func(string) string, strings ...string) []string {
 	if strings == nil {
 		return []string{}
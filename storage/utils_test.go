@@ -1,14 +1,19 @@
 package storage
 
 import (
+	"context"
+	"io"
 	"os"
 	"syscall"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	flyteerrors "github.com/flyteorg/flytestdlib/errors"
 	"github.com/flyteorg/stow"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestIsNotFound(t *testing.T) {
@@ -48,6 +53,46 @@ func TestIsFailedWriteToCache(t *testing.T) {
 	assert.False(t, IsFailedWriteToCache(sysError))
 }
 
+func TestIsRetryable(t *testing.T) {
+	assert.False(t, IsRetryable(nil))
+	assert.False(t, IsRetryable(errors.Wrap(stow.ErrNotFound, "not found")))
+	assert.False(t, IsRetryable(flyteerrors.Errorf(ErrExceedsLimit, "too big")))
+	assert.False(t, IsRetryable(errors.Wrap(context.Canceled, "cancelled")))
+	assert.False(t, IsRetryable(errors.New("unknown")))
+	assert.False(t, IsRetryable(awserr.NewRequestFailure(awserr.New("AccessDenied", "denied", nil), 403, "id")))
+
+	assert.True(t, IsRetryable(errors.Wrap(io.ErrUnexpectedEOF, "read")))
+	assert.True(t, IsRetryable(&os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}))
+	assert.True(t, IsRetryable(errors.Wrap(awserr.New("SlowDown", "slow down", nil), "write")))
+	assert.True(t, IsRetryable(awserr.NewRequestFailure(awserr.New("Unknown", "unavailable", nil), 503, "id")))
+	assert.True(t, IsRetryable(status.Error(codes.Unavailable, "unavailable")))
+}
+
+func TestIsPreconditionFailed(t *testing.T) {
+	assert.True(t, IsPreconditionFailed(errors.Wrap(flyteerrors.Errorf(ErrPreconditionFailed, "exists"), "write")))
+	assert.True(t, IsPreconditionFailed(awserr.NewRequestFailure(awserr.New("PreconditionFailed", "failed", nil), 412, "id")))
+	assert.False(t, IsPreconditionFailed(flyteerrors.Errorf(ErrExceedsLimit, "too big")))
+	assert.False(t, IsRetryable(flyteerrors.Errorf(ErrPreconditionFailed, "exists")))
+}
+
+func TestCheckWriteConditions(t *testing.T) {
+	exists := MemoryMetadata{exists: true, etag: "etag"}
+	missing := MemoryMetadata{}
+	assert.NoError(t, checkWriteConditions("ref", exists, Options{}))
+	assert.NoError(t, checkWriteConditions("ref", missing, Options{IfNotExists: true}))
+	assert.True(t, IsPreconditionFailed(checkWriteConditions("ref", exists, Options{IfNotExists: true})))
+	assert.NoError(t, checkWriteConditions("ref", exists, Options{IfMatch: "etag"}))
+	assert.True(t, IsPreconditionFailed(checkWriteConditions("ref", exists, Options{IfMatch: "other"})))
+	assert.True(t, IsPreconditionFailed(checkWriteConditions("ref", missing, Options{IfMatch: "etag"})))
+}
+
+func TestContentMD5(t *testing.T) {
+	assert.Equal(t, "recorded", contentMD5(map[string]string{MetadataKeyContentMD5: "recorded"}, "5d41402abc4b2a76b9719d911017c592"))
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", contentMD5(nil, "5D41402ABC4B2A76B9719D911017C592"))
+	assert.Empty(t, contentMD5(nil, "5d41402abc4b2a76b9719d911017c592-3"))
+	assert.Empty(t, contentMD5(nil, "CJ6Vj6bU6vICEAE="))
+}
+
 func TestMapStrings(t *testing.T) {
 	t.Run("nothing", func(t *testing.T) {
 		assert.Equal(t, []string{}, MapStrings(func(s string) string {
@@ -73,3 +118,36 @@ func TestMapStrings(t *testing.T) {
 		}, "something", "somesome"))
 	})
 }
+
+func TestRangeBounds(t *testing.T) {
+	tests := []struct {
+		name          string
+		offset        int64
+		length        int64
+		expectedStart int64
+		expectedEnd   int64
+		expectedErr   bool
+	}{
+		{"whole object", 0, -1, 0, 10, false},
+		{"prefix", 0, 4, 0, 4, false},
+		{"middle", 2, 4, 2, 6, false},
+		{"past the end", 8, 4, 8, 10, false},
+		{"at the end", 10, 4, 10, 10, false},
+		{"negative offset", -1, 4, 0, 0, true},
+		{"offset past the end", 11, 4, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := rangeBounds(10, tt.offset, tt.length)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStart, start)
+			assert.Equal(t, tt.expectedEnd, end)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+// WireFormat defines how protobufs are serialized before being written.
+type WireFormat = string
+
+const (
+	// WireFormatBinary is the compact protobuf binary encoding.
+	WireFormatBinary WireFormat = "binary"
+	// WireFormatJSON is the canonical protobuf JSON mapping (protojson).
+	WireFormatJSON WireFormat = "json"
+	// WireFormatText is the human-readable protobuf text format (prototext).
+	WireFormatText WireFormat = "text"
+)
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeText = "text/x-protobuf"
+)
+
+type wireFormat struct {
+	// contentType is recorded in the metadata of written objects, unless it's empty.
+	contentType string
+	marshal     func(msg proto.Message) ([]byte, error)
+	unmarshal   func(raw []byte, msg proto.Message) error
+}
+
+var wireFormats = map[WireFormat]wireFormat{
+	WireFormatBinary: {
+		marshal:   proto.Marshal,
+		unmarshal: proto.Unmarshal,
+	},
+	WireFormatJSON: {
+		contentType: contentTypeJSON,
+		marshal: func(msg proto.Message) ([]byte, error) {
+			return protojson.MarshalOptions{Multiline: true}.Marshal(proto.MessageV2(msg))
+		},
+		unmarshal: func(raw []byte, msg proto.Message) error {
+			return protojson.Unmarshal(raw, proto.MessageV2(msg))
+		},
+	},
+	WireFormatText: {
+		contentType: contentTypeText,
+		marshal: func(msg proto.Message) ([]byte, error) {
+			return prototext.MarshalOptions{Multiline: true}.Marshal(proto.MessageV2(msg))
+		},
+		unmarshal: func(raw []byte, msg proto.Message) error {
+			return prototext.Unmarshal(raw, proto.MessageV2(msg))
+		},
+	},
+}
+
+func getWireFormat(format WireFormat) (wireFormat, error) {
+	if len(format) == 0 {
+		return wireFormats[WireFormatBinary], nil
+	}
+
+	f, found := wireFormats[format]
+	if !found {
+		return wireFormat{}, fmt.Errorf("unsupported wire format [%v]", format)
+	}
+
+	return f, nil
+}
+
+// unmarshalSniffed unmarshals raw into msg in the wire format its contents look like. The format is recorded as the
+// content type on write, but that can't be relied upon to read it back since not every backend stores metadata and
+// objects written before wire formats were recorded don't have one. Payloads that look like JSON or text but don't
+// parse as such are read as binary, and binary payloads that don't parse are read as text.
+func unmarshalSniffed(raw []byte, msg proto.Message) error {
+	var err error
+	for i, f := range sniffWireFormats(raw) {
+		msg.Reset()
+		if attemptErr := f.unmarshal(raw, msg); attemptErr == nil {
+			return nil
+		} else if i == 0 {
+			err = attemptErr
+		}
+	}
+
+	return err
+}
+
+// sniffWireFormats returns the wire formats raw may be in, most likely first.
+func sniffWireFormats(raw []byte) []wireFormat {
+	trimmed := bytes.TrimLeftFunc(raw, unicode.IsSpace)
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		return []wireFormat{wireFormats[WireFormatJSON], wireFormats[WireFormatBinary]}
+	case looksLikeText(raw):
+		return []wireFormat{wireFormats[WireFormatText], wireFormats[WireFormatBinary]}
+	default:
+		return []wireFormat{wireFormats[WireFormatBinary], wireFormats[WireFormatText]}
+	}
+}
+
+// looksLikeText returns true if raw is non-empty UTF-8 text without control characters other than whitespace.
+func looksLikeText(raw []byte) bool {
+	if len(raw) == 0 || !utf8.Valid(raw) {
+		return false
+	}
+
+	for _, r := range string(raw) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/flyteorg/stow/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flyteorg/flytestdlib/promutils"
+)
+
+func TestDefaultProtobufStore_WireFormat(t *testing.T) {
+	ctx := context.TODO()
+	rawStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	require.NoError(t, err)
+
+	binaryStore, err := newDefaultProtobufStoreFromConfig(rawStore, &Config{}, metrics.protoMetrics)
+	require.NoError(t, err)
+
+	for format, contentType := range map[WireFormat]string{
+		WireFormatBinary: "",
+		WireFormatJSON:   contentTypeJSON,
+		WireFormatText:   contentTypeText,
+	} {
+		t.Run(format, func(t *testing.T) {
+			s, err := newDefaultProtobufStoreFromConfig(rawStore, &Config{
+				Serialization: SerializationConfig{Format: format},
+			}, metrics.protoMetrics)
+			require.NoError(t, err)
+
+			ref := DataReference("mem://container/" + format)
+			assert.NoError(t, s.WriteProtobuf(ctx, ref, Options{}, &mockProtoMessage{X: 5}))
+
+			md, err := s.Head(ctx, ref)
+			assert.NoError(t, err)
+			assert.Equal(t, contentType, md.ContentType())
+
+			m := &mockProtoMessage{}
+			assert.NoError(t, binaryStore.ReadProtobuf(ctx, ref, m))
+			assert.Equal(t, int64(5), m.X)
+		})
+	}
+
+	t.Run("Readable JSON", func(t *testing.T) {
+		ref := DataReference("mem://container/readable")
+		assert.NoError(t, binaryStore.WriteProtobuf(ctx, ref, Options{WireFormat: WireFormatJSON}, &mockProtoMessage{X: 5}))
+
+		rc, err := binaryStore.ReadRaw(ctx, ref)
+		require.NoError(t, err)
+		raw, err := ioutil.ReadAll(rc)
+		assert.NoError(t, err)
+		assert.NoError(t, rc.Close())
+		assert.JSONEq(t, `{"x": "5"}`, string(raw))
+	})
+
+	t.Run("Per call override", func(t *testing.T) {
+		s, err := newDefaultProtobufStoreFromConfig(rawStore, &Config{
+			Serialization: SerializationConfig{Format: WireFormatJSON},
+		}, metrics.protoMetrics)
+		require.NoError(t, err)
+
+		ref := DataReference("mem://container/override")
+		assert.NoError(t, s.WriteProtobuf(ctx, ref, Options{
+			Metadata:   map[string]interface{}{"owner": "me"},
+			WireFormat: WireFormatText,
+		}, &mockProtoMessage{X: 7}))
+
+		md, err := s.Head(ctx, ref)
+		assert.NoError(t, err)
+		assert.Equal(t, contentTypeText, md.ContentType())
+		assert.Equal(t, "me", md.UserMetadata()["owner"])
+
+		m := &mockProtoMessage{}
+		assert.NoError(t, s.ReadProtobuf(ctx, ref, m))
+		assert.Equal(t, int64(7), m.X)
+	})
+
+	t.Run("No content type", func(t *testing.T) {
+		s, err := newDefaultProtobufStoreFromConfig(rawStore, &Config{
+			Serialization: SerializationConfig{Format: WireFormatJSON},
+		}, metrics.protoMetrics)
+		require.NoError(t, err)
+
+		for name, raw := range map[string][]byte{
+			"binary": {0x10, 0x05},
+			"json":   []byte(" \n{\"x\": \"5\"}"),
+			"text":   []byte("x: 5\n"),
+		} {
+			ref := DataReference("mem://container/legacy-" + name)
+			assert.NoError(t, rawStore.WriteRaw(ctx, ref, int64(len(raw)), Options{}, bytes.NewReader(raw)))
+			m := &mockProtoMessage{}
+			assert.NoError(t, s.ReadProtobuf(ctx, ref, m), name)
+			assert.Equal(t, int64(5), m.X, name)
+		}
+	})
+
+	t.Run("Sniffs the format", func(t *testing.T) {
+		for _, tc := range []struct {
+			raw      []byte
+			expected int64
+		}{
+			// Binary with a length-delimited field whose length is '{'.
+			{raw: append([]byte{0x10, 0x05, 0x1a, '{'}, bytes.Repeat([]byte("a"), '{')...), expected: 5},
+			// Binary with printable bytes only.
+			{raw: []byte{0x10, 'A'}, expected: 'A'},
+			{raw: []byte{}, expected: 0},
+			{raw: []byte("{}"), expected: 0},
+			{raw: []byte("x:7"), expected: 7},
+		} {
+			m := &mockProtoMessage{}
+			assert.NoError(t, unmarshalSniffed(tc.raw, m), string(tc.raw))
+			assert.Equal(t, tc.expected, m.X, string(tc.raw))
+		}
+
+		assert.Error(t, unmarshalSniffed([]byte("{not json"), &mockProtoMessage{}))
+	})
+
+	t.Run("Unsupported format", func(t *testing.T) {
+		_, err := NewDataStore(&Config{Type: TypeMemory, Serialization: SerializationConfig{Format: "yaml"}},
+			promutils.NewTestScope())
+		assert.Error(t, err)
+
+		err = binaryStore.WriteProtobuf(ctx, "mem://container/yaml", Options{WireFormat: "yaml"}, &mockProtoMessage{X: 5})
+		assert.Error(t, err)
+	})
+}
+
+func TestDefaultProtobufStore_WireFormatWithoutMetadata(t *testing.T) {
+	ctx := context.TODO()
+	s, err := NewDataStore(&Config{
+		Type: TypeLocal,
+		Stow: StowConfig{
+			Kind:   local.Kind,
+			Config: map[string]string{local.ConfigKeyPath: t.TempDir()},
+		},
+		InitContainer: "container",
+	}, promutils.NewTestScope())
+	require.NoError(t, err)
+
+	for _, format := range []WireFormat{WireFormatBinary, WireFormatJSON, WireFormatText} {
+		t.Run(format, func(t *testing.T) {
+			ref := DataReference("file://container/" + format)
+			assert.NoError(t, s.WriteProtobuf(ctx, ref, Options{WireFormat: format}, &mockProtoMessage{X: 5}))
+
+			md, err := s.Head(ctx, ref)
+			require.NoError(t, err)
+			assert.Empty(t, md.ContentType())
+
+			m := &mockProtoMessage{}
+			assert.NoError(t, s.ReadProtobuf(ctx, ref, m))
+			assert.Equal(t, int64(5), m.X)
+		})
+	}
+}
+
+func TestDefaultProtobufStore_WireFormatCached(t *testing.T) {
+	ctx := context.TODO()
+	memStore, err := NewInMemoryRawStore(ctx, &Config{}, metrics)
+	require.NoError(t, err)
+
+	backend := &headCountingStore{RawStore: memStore}
+	s := NewDefaultProtobufStoreWithMetrics(
+		newCachedRawStore(&Config{Cache: CachingConfig{MaxSizeMegabytes: 1}}, backend, metrics.cacheMetrics),
+		metrics.protoMetrics)
+	assert.NoError(t, s.WriteProtobuf(ctx, "mem://container/a", Options{WireFormat: WireFormatJSON},
+		&mockProtoMessage{X: 5}))
+
+	// The format is detected from the cached contents without looking up the content type.
+	m := &mockProtoMessage{}
+	assert.NoError(t, s.ReadProtobuf(ctx, "mem://container/a", m))
+	assert.Equal(t, int64(5), m.X)
+	assert.Equal(t, 0, backend.heads)
+}